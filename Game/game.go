@@ -2,6 +2,7 @@ package Game
 
 import (
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
@@ -30,12 +31,64 @@ var moveOrder = []byte{0, 4, 2, 6, 8, 1, 3, 5, 7}
 
 var RandSource = rand.New(rand.NewSource(time.Now().Unix()))
 
-var x = uint64(time.Now().Unix()) /*  time.Now().Unix() initial seed must be nonzero, don't use a static variable for the state if multithreaded */
+var x = Xorshift(time.Now().Unix()) /*  time.Now().Unix() initial seed must be nonzero, don't use a static variable for the state if multithreaded */
+var xorshiftSeed = uint64(x)
+
+// playouts counts the sequences started by NewXorshift since the last seed
+var playouts atomic.Uint64
+
+func init() {
+	populateMoves()
+}
+
+// Seed replaces the clock seeds, the move order and the random playouts then only depend on seed.
+// It must not be called while a game is searched
+func Seed(seed int64) {
+	RandSource.Seed(seed)
+	xorshiftSeed = uint64(seed)*0x9E3779B97F4A7C15 | 1
+	x = Xorshift(xorshiftSeed)
+	playouts.Store(0)
+	populateMoves()
+}
+
+// Xorshift is the state of a sequence of random playouts, searches running at the same time need their own
+type Xorshift uint64
+
+// NewXorshift starts a new sequence, after Seed the sequences are started again in the same order
+func NewXorshift() *Xorshift {
+	state := Xorshift(splitmix(xorshiftSeed+playouts.Add(1)*0x9E3779B97F4A7C15) | 1)
+	return &state
+}
+
+// NewXorshiftAt starts the sequence of the position, after Seed a position always gets the same sequence
+// no matter what else is searched at the same time
+func NewXorshiftAt(g *Game) *Xorshift {
+	z := xorshiftSeed
+	for _, board := range g.Board {
+		z = splitmix(z + uint64(board)*0x9E3779B97F4A7C15)
+	}
+	state := Xorshift(z | 1)
+	return &state
+}
+
+// splitmix is the finalizer of splitmix64, it keeps the sequences of one seed apart
+func splitmix(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// Next returns a random number below n
+func (x *Xorshift) Next(n byte) byte {
+	*x ^= *x >> 12
+	*x ^= *x << 25
+	*x ^= *x >> 27
+	return byte((uint64(*x) * 2685821657736338717) % uint64(n))
+}
+
+// Xorshift64star draws from the sequence shared by the package, it must not be used by searches in parallel
 func Xorshift64star(n byte) byte {
-	x ^= x >> 12
-	x ^= x << 25
-	x ^= x >> 27
-	return byte((x * 2685821657736338717) % uint64(n))
+	return x.Next(n)
 }
 
 type Game struct {
@@ -53,7 +106,7 @@ func (g *Game) GetMoves(executeMove func(byte, byte) bool) {
 			}
 		}
 	} else {
-		jointOverallBoard = (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+		jointOverallBoard := (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
 		for _, i := range MovesStorage[jointOverallBoard] {
 			for _, move := range MovesStorage[((g.Board[i] | (g.Board[i] >> 9)) & 0x1FF)] {
 				if executeMove(i, move) {
@@ -119,7 +172,6 @@ func NewGame() *Game {
 		OverallBoard:    0x0,
 		HeuristicScores: DefaultHeuristic(),
 	}
-	g.PopulateBoards()
 	return g
 }
 
@@ -200,9 +252,10 @@ func (g *Game) MakeMove(boardIndex byte, pos byte) {
 	}
 }
 
+// ValidMove checks that the square is empty and in the board to play, after a move to a finished board any open board can be played
 func (g *Game) ValidMove(boardIndex byte, pos byte) bool {
 	currentBoard := byte(g.Board[PlayerBoardIndex] >> 1)
-	return !g.IsBoardFinished(boardIndex) && (currentBoard == GlobalBoard || boardIndex == currentBoard) && g.Board[boardIndex]&(1<<pos) == 0 && g.Board[boardIndex]&(1<<(pos+9)) == 0
+	return boardIndex < boardLength && pos < boardLength && !g.IsBoardFinished(boardIndex) && (currentBoard >= boardLength || boardIndex == currentBoard) && g.Board[boardIndex]&(1<<pos) == 0 && g.Board[boardIndex]&(1<<(pos+9)) == 0
 }
 
 func (g *Game) IsBoardFinished(pos byte) bool {
@@ -212,22 +265,15 @@ func (g *Game) IsBoardFinished(pos byte) bool {
 var MovesStorage = [512][]byte{}
 var MovesLengthStorage = [512]byte{}
 
-var boardIndex byte
-var moveIndex byte
-var jointOverallBoard uint32
-var moves byte
-var currentMoves byte
-var board uint32
-var relativeMoveIndex byte
-
+// Len and GetMoves keep no state in package variables, games can be searched with minimax in parallel
 func (g *Game) Len() byte {
-	boardIndex = byte(g.Board[PlayerBoardIndex] >> 1)
+	boardIndex := byte(g.Board[PlayerBoardIndex] >> 1)
 	if boardIndex < 9 {
 		return MovesLengthStorage[(g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF]
 	}
 
-	moves = 0
-	jointOverallBoard = (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+	var moves byte = 0
+	jointOverallBoard := (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
 	for _, i := range MovesStorage[jointOverallBoard] {
 		// Check if the board is open
 		moves += MovesLengthStorage[(g.Board[i]|(g.Board[i]>>9))&0x1FF]
@@ -236,13 +282,20 @@ func (g *Game) Len() byte {
 	return moves
 }
 
+// MakeMoveRandUntilTerminal plays random moves with the sequence shared by the package until the game is over
 func (g *Game) MakeMoveRandUntilTerminal() {
+	g.RandomPlayout(&x)
+}
+
+// RandomPlayout plays random moves drawn from rng until the game is over, every step plays one legal move
+// of the player to move
+func (g *Game) RandomPlayout(rng *Xorshift) {
 	//for !g.IsTerminal() {
-	jointOverallBoard = (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+	jointOverallBoard := (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
 	for !(BoardCompletedStorage[g.OverallBoard&0x1FF] || BoardCompletedStorage[(g.OverallBoard>>9)&0x1FF] || jointOverallBoard == 0x1FF) {
-		boardIndex = byte(g.Board[PlayerBoardIndex] >> 1)
+		boardIndex := byte(g.Board[PlayerBoardIndex] >> 1)
 		// moveIndex = byte(RandSource.Intn(int(g.Len())))
-		moveIndex = Xorshift64star(g.Len())
+		moveIndex := rng.Next(g.Len())
 
 		if boardIndex < 9 {
 			g.MakeMove(boardIndex, MovesStorage[(g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF][moveIndex])
@@ -250,16 +303,16 @@ func (g *Game) MakeMoveRandUntilTerminal() {
 			continue
 		}
 
-		moves = 0
+		var moves byte = 0
 		for _, i := range MovesStorage[jointOverallBoard] {
-			board = (g.Board[i] | (g.Board[i] >> 9)) & 0x1FF
-			currentMoves = MovesLengthStorage[board]
+			board := (g.Board[i] | (g.Board[i] >> 9)) & 0x1FF
+			currentMoves := MovesLengthStorage[board]
 
 			if moves+currentMoves > moveIndex {
-				relativeMoveIndex = moveIndex - moves
-				g.MakeMove(i, MovesStorage[board][relativeMoveIndex])
+				g.MakeMove(i, MovesStorage[board][moveIndex-moves])
 				jointOverallBoard = (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
-				continue
+				// One move per turn, the next player picks from the new position
+				break
 			}
 			moves += currentMoves
 		}
@@ -0,0 +1,88 @@
+package Game
+
+import "testing"
+
+func TestValidMove(t *testing.T) {
+	game := NewGame()
+	game.MakeMove(4, 0)
+	if !game.ValidMove(0, 4) || game.ValidMove(1, 4) {
+		t.Fatal("only board 0 may be played after a move to square 0")
+	}
+
+	// Player 1 wins board 4 and player 2 sends it back there, player 1 may play any open board
+	for _, move := range [][2]byte{{0, 4}, {4, 1}, {1, 4}, {4, 2}, {2, 4}} {
+		game.MakeMove(move[0], move[1])
+	}
+	if !game.IsBoardFinished(4) {
+		t.Fatal("board 4 should be won")
+	}
+	if game.ValidMove(4, 5) {
+		t.Fatal("a finished board was playable")
+	}
+	if !game.ValidMove(3, 3) || !game.ValidMove(8, 0) {
+		t.Fatal("open boards are not playable after a move to a finished board")
+	}
+	if game.ValidMove(0, 4) || game.ValidMove(9, 0) || game.ValidMove(3, 9) {
+		t.Fatal("an occupied square or one outside the board was playable")
+	}
+}
+
+func TestSeedIsReproducible(t *testing.T) {
+	Seed(7)
+	moves := MovesStorage
+	playouts := []byte{Xorshift64star(81), Xorshift64star(81), Xorshift64star(81)}
+
+	Seed(7)
+	for i := range moves {
+		if string(moves[i]) != string(MovesStorage[i]) {
+			t.Fatalf("move order of board %d changed for the same seed", i)
+		}
+	}
+	for i, playout := range playouts {
+		if move := Xorshift64star(81); move != playout {
+			t.Fatalf("playout %d chose %d, want %d", i, move, playout)
+		}
+	}
+
+}
+
+func TestXorshiftAtDependsOnPosition(t *testing.T) {
+	Seed(7)
+	game := NewGame()
+	first := *NewXorshiftAt(game)
+	NewXorshift()
+	if again := *NewXorshiftAt(game); again != first {
+		t.Fatalf("the start position got the sequence %d, then %d", first, again)
+	}
+
+	game.MakeMove(8, 4)
+	if other := *NewXorshiftAt(game); other == first {
+		t.Fatal("two positions got the same sequence")
+	}
+	Seed(8)
+	if reseeded := *NewXorshiftAt(NewGame()); reseeded == first {
+		t.Fatal("two seeds gave the start position the same sequence")
+	}
+}
+
+func TestRandomPlayoutMakesOneMovePerStep(t *testing.T) {
+	for seed := Xorshift(1); seed <= 1000; seed++ {
+		playout, played := NewGame(), NewGame()
+		rng, replay := seed, seed
+		playout.RandomPlayout(&rng)
+
+		// The same draws picking from the legal moves, one at a time
+		for !played.IsTerminal() {
+			moveIndex := replay.Next(played.Len())
+			moves := [][2]byte{}
+			played.GetMoves(func(board byte, pos byte) bool {
+				moves = append(moves, [2]byte{board, pos})
+				return false
+			})
+			played.MakeMove(moves[moveIndex][0], moves[moveIndex][1])
+		}
+		if !playout.Compare(played) {
+			t.Fatalf("seed %d played %v, one move per step plays %v", seed, playout.Board, played.Board)
+		}
+	}
+}
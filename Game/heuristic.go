@@ -1,11 +1,35 @@
 package Game
 
-var BoardHeuristicCacheP1 = map[uint32]float64{}
-var BoardHeuristicCacheP2 = map[uint32]float64{}
+// boardStates is the amount of ways a local board can be filled
+const boardStates = 19683
+
+// ternary maps the 9 bit mask of one player to its base 3 digits
+var ternary = [512]uint16{}
+
+func init() {
+	for mask := 0; mask < 512; mask++ {
+		var digit uint16 = 1
+		for i := 0; i < boardLength; i++ {
+			if mask&(1<<i) > 0 {
+				ternary[mask] += digit
+			}
+			digit *= 3
+		}
+	}
+}
+
+// boardStateIndex is the base 3 encoding of a local board seen from player, 1 for own and 2 for enemy marks
+func boardStateIndex(player Player, board uint32) uint16 {
+	if player == Player1 {
+		return ternary[board&0x1FF] + 2*ternary[(board>>9)&0x1FF]
+	}
+	return ternary[(board>>9)&0x1FF] + 2*ternary[board&0x1FF]
+}
 
 type HeuristicScores struct {
-	BoardRating [9]float64
-	PosRating   [9]float64
+	// BoardRating and PosRating are derived from the corner, side and middle ratings by Normalise
+	BoardRating [9]float64 `json:"-"`
+	PosRating   [9]float64 `json:"-"`
 
 	BoardCornerRating                        float64
 	BoardSideRating                          float64
@@ -28,6 +52,9 @@ type HeuristicScores struct {
 	DrawBoardScorePlayerDiscountRating       float64
 	LocalBoardWinPlayedMovesDiscountRating   float64
 	OverallBoardWinPlayedMovesDiscountRating float64
+
+	// boardScores caches the local board rating of every board state for player 1, built by Normalise
+	boardScores []float64
 }
 
 func DefaultHeuristic() *HeuristicScores {
@@ -55,10 +82,24 @@ func DefaultHeuristic() *HeuristicScores {
 		OverallBoardWinPlayedMovesDiscountRating: 1.32,
 	}
 
+	h.Normalise()
+	return &h
+}
+
+// Normalise rebuilds the board and position ratings from the corner, side and middle ratings
+// and caches the rating of every local board, it has to be called after changing a rating
+func (h *HeuristicScores) Normalise() {
 	h.BoardRating = [9]float64{h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardMiddleRating}
 	h.PosRating = [9]float64{h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosMiddleRating}
 
-	return &h
+	h.boardScores = make([]float64, boardStates)
+	for state := 0; state < boardStates; state++ {
+		var board uint32 = 0
+		for i, digits := 0, state; i < boardLength; i, digits = i+1, digits/3 {
+			board = popBoardHelper(board, i, digits%3-1)
+		}
+		h.boardScores[state] = h.heuristicBoard(Player1, board, false)
+	}
 }
 
 func getOffset(player Player) (int, int) {
@@ -69,16 +110,13 @@ func getOffset(player Player) (int, int) {
 }
 
 func (g *Game) HeuristicBoard(player Player, board uint32, isOverallBoard bool) float64 {
-	if !isOverallBoard {
-		if player == Player1 {
-			if score, ok := BoardHeuristicCacheP1[board&0x3FFFF]; ok {
-				return score
-			}
-		} else if score, ok := BoardHeuristicCacheP2[board&0x3FFFF]; ok {
-			return score
-		}
+	if !isOverallBoard && g.HeuristicScores.boardScores != nil {
+		return g.HeuristicScores.boardScores[boardStateIndex(player, board)]
 	}
+	return g.HeuristicScores.heuristicBoard(player, board, isOverallBoard)
+}
 
+func (h *HeuristicScores) heuristicBoard(player Player, board uint32, isOverallBoard bool) float64 {
 	offset, enemyOffset := getOffset(player)
 	var score float64 = 0
 
@@ -92,25 +130,25 @@ func (g *Game) HeuristicBoard(player Player, board uint32, isOverallBoard bool)
 		// Give a discount on the amount of moves made in the board
 		// To incentivise a lower number of total moves
 		if !isOverallBoard {
-			score += g.HeuristicScores.WonBoardRating - float64(bitCount(playerBoard))*g.HeuristicScores.LocalBoardWinPlayedMovesDiscountRating
+			score += h.WonBoardRating - float64(bitCount(playerBoard))*h.LocalBoardWinPlayedMovesDiscountRating
 		} else {
-			score += g.HeuristicScores.WonBoardRating - float64(bitCount(playerBoard))*g.HeuristicScores.OverallBoardWinPlayedMovesDiscountRating
+			score += h.WonBoardRating - float64(bitCount(playerBoard))*h.OverallBoardWinPlayedMovesDiscountRating
 		}
 
 		// The board is a draw
 	} else if jointBoard == 0x1FF && !CheckCompleted(enemyBoard) {
 		// Give a reward for the amount of wasted enemy moves (or won moves
 		if !isOverallBoard {
-			score += float64(bitCount(enemyBoard)) * g.HeuristicScores.DrawBoardScoreEnemyDiscountRating
+			score += float64(bitCount(enemyBoard)) * h.DrawBoardScoreEnemyDiscountRating
 		} else {
-			score += float64(bitCount(playerBoard)) * g.HeuristicScores.DrawBoardScorePlayerDiscountRating
+			score += float64(bitCount(playerBoard)) * h.DrawBoardScorePlayerDiscountRating
 		}
 
 	} else {
 		// Calculate pos for items
 		for i := 0; i < boardLength; i++ {
 			if playerBoard&(0x1<<i) > 0 {
-				score += g.HeuristicScores.PosRating[i]
+				score += h.PosRating[i]
 			}
 		}
 
@@ -118,18 +156,18 @@ func (g *Game) HeuristicBoard(player Player, board uint32, isOverallBoard bool)
 		if !CheckCompleted(enemyBoard) {
 			// Check 2 joint items
 			if checkCloseWinningSequence(playerBoard, jointBoard) > 0 {
-				score += g.HeuristicScores.TwoInARowAdvantageRating
+				score += h.TwoInARowAdvantageRating
 			}
 
 			// Check 2 joint items
 			if checkCloseWinningSequence(enemyBoard, jointBoard) > 0 {
-				score -= g.HeuristicScores.EnemyTwoInARowLossRating
+				score -= h.EnemyTwoInARowLossRating
 			}
 
 			// The enemy has won a square
 		} else {
 			// Give a reward for enemy moves
-			score -= g.HeuristicScores.EnemyWonBoardLossRating - float64(bitCount(enemyBoard))*g.HeuristicScores.EnemyWonBoardDiscountRating
+			score -= h.EnemyWonBoardLossRating - float64(bitCount(enemyBoard))*h.EnemyWonBoardDiscountRating
 		}
 	}
 	return score
@@ -176,6 +214,48 @@ func (g *Game) HeuristicPlayer(player Player) float64 {
 	return score
 }
 
+// HeuristicBreakdown is HeuristicPlayer split into its terms, the terms add up to Total.
+// A finished game is only scored by Terminal
+type HeuristicBreakdown struct {
+	Total       float64    `json:"total"`
+	Terminal    float64    `json:"terminal"`
+	GlobalState float64    `json:"globalState"`
+	Boards      [9]float64 `json:"boards"`
+	Overall     float64    `json:"overall"`
+}
+
+// Breakdown computes HeuristicPlayer term by term, it is slower and meant for analysis
+func (g *Game) Breakdown(player Player) HeuristicBreakdown {
+	b := HeuristicBreakdown{}
+	var playerOffset, enemyOffset = getOffset(player)
+	playerBoard := (g.OverallBoard >> playerOffset) & 0x1FF
+	enemyBoard := (g.OverallBoard >> enemyOffset) & 0x1FF
+	jointBoard := (g.OverallBoard>>18)&0x1FF | playerBoard | enemyBoard
+
+	if CheckCompleted(playerBoard) || CheckCompleted(enemyBoard) || jointBoard == 0x1FF {
+		b.Terminal = g.HeuristicPlayer(player)
+		b.Total = b.Terminal
+		return b
+	}
+
+	if byte(g.Board[PlayerBoardIndex]>>1) == GlobalBoard {
+		b.GlobalState = g.HeuristicScores.GlobalStateRating
+		if byte(g.Board[PlayerBoardIndex]&0x1) != byte(player) {
+			b.GlobalState = -b.GlobalState
+		}
+	}
+	b.Total = b.GlobalState
+
+	for i := 0; i < boardLength; i++ {
+		b.Boards[i] = g.HeuristicBoard(player, g.Board[i], false) * g.HeuristicScores.BoardRating[i]
+		b.Total += b.Boards[i]
+	}
+
+	b.Overall = g.HeuristicBoard(player, g.OverallBoard, true) * g.HeuristicScores.OverallBoardMultiplierRating
+	b.Total += b.Overall
+	return b
+}
+
 func (g *Game) MovesMade() uint32 {
 	// Count how far the game has progressed
 	var movesPlayed uint32 = 0
@@ -194,7 +274,16 @@ func popBoardHelper(b uint32, pos int, player int) uint32 {
 	return b
 }
 
+// PopulateBoards shuffles the move order again, the moves are populated on start
 func (g *Game) PopulateBoards() {
+	populateMoves()
+}
+
+func populateMoves() {
+	// Every shuffle starts from the same order, the seed alone decides the result
+	order := make([]byte, len(moveOrder))
+	copy(order, moveOrder)
+
 	var board uint32 = 0
 	for i0 := 0; i0 < 3; i0++ {
 		for i1 := 0; i1 < 3; i1++ {
@@ -216,36 +305,17 @@ func (g *Game) PopulateBoards() {
 										board = popBoardHelper(board, 7, i7)
 										board = popBoardHelper(board, 8, i8)
 
-										// Check if board is valid
-										isValid := false
-										xBoard, oBoard, jointBoard := board&0x1FF, (board>>9)&0x1FF, uint16((board&0x1FF)|((board>>9)&0x1FF))
-
-										// Board can be valid only if either
-										xWin, oWin := CheckCompleted(xBoard), CheckCompleted(oBoard)
-										if xWin && !oWin {
-											isValid = true
-										} else if !xWin && oWin {
-											isValid = true
-										} else if !xWin && !oWin {
-											isValid = true
-										}
-
-										if isValid {
-											BoardHeuristicCacheP1[board] = g.HeuristicBoard(Player1, board, false)
-											BoardHeuristicCacheP2[board] = g.HeuristicBoard(Player2, board, false)
-										}
-
+										jointBoard := uint16((board & 0x1FF) | ((board >> 9) & 0x1FF))
 										MovesStorage[jointBoard] = []byte{}
-										RandSource.Shuffle(len(moveOrder), func(i, j int) {
-											moveOrder[i], moveOrder[j] = moveOrder[j], moveOrder[i]
+										RandSource.Shuffle(len(order), func(i, j int) {
+											order[i], order[j] = order[j], order[i]
 										})
-										for _, move := range moveOrder {
+										for _, move := range order {
 											if jointBoard&(0x1<<move) == 0 {
 												MovesStorage[jointBoard] = append(MovesStorage[jointBoard], move)
 											}
 										}
 										MovesLengthStorage[jointBoard] = byte(len(MovesStorage[jointBoard]))
-										BoardCompletedStorage[jointBoard] = CheckCompletedHelper(uint32(jointBoard))
 									}
 								}
 							}
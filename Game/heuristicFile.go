@@ -0,0 +1,196 @@
+package Game
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// legacyHeuristicFields were written by older tuners and are dropped when loading
+var legacyHeuristicFields = map[string]bool{
+	"BoardRatingMultiplierRating": true,
+}
+
+// derivedHeuristicFields are the arrays built by Normalise, they are only used for
+// ratings that are missing from the file. The indexes are the corner, side and middle
+var derivedHeuristicFields = map[string][3]string{
+	"BoardRating": {"BoardCornerRating", "BoardSideRating", "BoardMiddleRating"},
+	"PosRating":   {"PosCornerRating", "PosSideRating", "PosMiddleRating"},
+}
+
+// BestHeuristic selects the weight set with the highest fitness in LoadHeuristic
+const BestHeuristic = -1
+
+// Elite is a weight set written by the tuner, Fitness is 0 when the file does not record it
+type Elite struct {
+	Fitness   int
+	Heuristic *HeuristicScores
+}
+
+// MarshalJSON writes the fitness next to the ratings so the elite files stay one flat object per line
+func (e Elite) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Fitness int
+		*HeuristicScores
+	}{e.Fitness, e.Heuristic})
+}
+
+// ParseHeuristic decodes a single JSON weight set. Unknown fields and missing ratings are errors,
+// legacy fields are dropped and the derived arrays are rebuilt from the scalar ratings
+func ParseHeuristic(data []byte) (*HeuristicScores, error) {
+	elite, err := ParseElite(data)
+	return elite.Heuristic, err
+}
+
+// ParseElite decodes a weight set together with the fitness the tuner recorded for it
+func ParseElite(data []byte) (Elite, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Elite{}, err
+	}
+
+	elite := Elite{}
+	if raw, ok := fields["Fitness"]; ok {
+		delete(fields, "Fitness")
+		if err := json.Unmarshal(raw, &elite.Fitness); err != nil {
+			return Elite{}, fmt.Errorf("Fitness: %w", err)
+		}
+	}
+
+	for name := range legacyHeuristicFields {
+		delete(fields, name)
+	}
+
+	// Older files carry the arrays as well, the scalars take precedence when both exist
+	for name, scalars := range derivedHeuristicFields {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		delete(fields, name)
+
+		var ratings [9]float64
+		if err := json.Unmarshal(raw, &ratings); err != nil {
+			return Elite{}, fmt.Errorf("%s: %w", name, err)
+		}
+		if ratings[0] != ratings[2] || ratings[0] != ratings[4] || ratings[0] != ratings[6] || ratings[1] != ratings[3] || ratings[1] != ratings[5] || ratings[1] != ratings[7] {
+			return Elite{}, fmt.Errorf("%s is not symmetric: %v", name, ratings)
+		}
+		for i, index := range []int{0, 1, 8} {
+			if _, ok := fields[scalars[i]]; !ok {
+				fields[scalars[i]], _ = json.Marshal(ratings[index])
+			}
+		}
+	}
+
+	var missing []string
+	hType := reflect.TypeOf(HeuristicScores{})
+	for i := 0; i < hType.NumField(); i++ {
+		if field := hType.Field(i); field.IsExported() && field.Tag.Get("json") != "-" {
+			if _, ok := fields[field.Name]; !ok {
+				missing = append(missing, field.Name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return Elite{}, fmt.Errorf("missing ratings: %s", strings.Join(missing, ", "))
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return Elite{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	elite.Heuristic = &HeuristicScores{}
+	if err = decoder.Decode(elite.Heuristic); err != nil {
+		return Elite{}, err
+	}
+	elite.Heuristic.Normalise()
+	return elite, nil
+}
+
+// ReadElites parses a stream of weight sets, either a single JSON object or
+// one object per line as written to the elite files by the tuner
+func ReadElites(r io.Reader) ([]Elite, error) {
+	var elites []Elite
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return elites, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("weight set %d: %w", len(elites), err)
+		}
+
+		elite, err := ParseElite(raw)
+		if err != nil {
+			return nil, fmt.Errorf("weight set %d: %w", len(elites), err)
+		}
+		elites = append(elites, elite)
+	}
+}
+
+// LoadElites reads every weight set in a weights or elite file
+func LoadElites(path string) ([]Elite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	elites, err := ReadElites(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return elites, nil
+}
+
+// LoadHeuristics reads every weight set in a weights or elite file
+func LoadHeuristics(path string) ([]*HeuristicScores, error) {
+	elites, err := LoadElites(path)
+	if err != nil {
+		return nil, err
+	}
+
+	heuristics := make([]*HeuristicScores, len(elites))
+	for i, elite := range elites {
+		heuristics[i] = elite.Heuristic
+	}
+	return heuristics, nil
+}
+
+// LoadHeuristic reads the weight set at index of a weights or elite file, BestHeuristic selects
+// the highest fitness. Without recorded fitness the last set is the best, the tuner appends elites
+// as they improve
+func LoadHeuristic(path string, index int) (*HeuristicScores, error) {
+	elites, err := LoadElites(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(elites) == 0 {
+		return nil, fmt.Errorf("%s: no weight sets", path)
+	}
+
+	if index == BestHeuristic {
+		best := 0
+		for i, elite := range elites {
+			if elite.Fitness >= elites[best].Fitness {
+				best = i
+			}
+		}
+		return elites[best].Heuristic, nil
+	}
+
+	if index < 0 || index >= len(elites) {
+		return nil, fmt.Errorf("%s: weight set %d out of range, the file has %d", path, index, len(elites))
+	}
+	return elites[index].Heuristic, nil
+}
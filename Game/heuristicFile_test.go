@@ -0,0 +1,109 @@
+package Game
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeuristicRoundTrip(t *testing.T) {
+	data, err := json.Marshal(DefaultHeuristic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"BoardRating"`) {
+		t.Fatalf("derived ratings are written: %s", data)
+	}
+
+	h, err := ParseHeuristic(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h, DefaultHeuristic()) {
+		t.Fatalf("round trip changed the weights: %+v", h)
+	}
+}
+
+func TestParseHeuristicMigratesLegacyFields(t *testing.T) {
+	fields := map[string]interface{}{}
+	data, _ := json.Marshal(DefaultHeuristic())
+	_ = json.Unmarshal(data, &fields)
+
+	// Written by older tuners, the arrays disagree with the scalars which take precedence
+	fields["BoardRatingMultiplierRating"] = 1.36
+	fields["BoardRating"] = [9]float64{9, 9, 9, 9, 9, 9, 9, 9, 9}
+	fields["PosRating"] = [9]float64{3, 4, 3, 4, 3, 4, 3, 4, 5}
+	delete(fields, "PosCornerRating")
+	delete(fields, "PosSideRating")
+	delete(fields, "PosMiddleRating")
+	data, _ = json.Marshal(fields)
+
+	h, err := ParseHeuristic(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.BoardRating != DefaultHeuristic().BoardRating {
+		t.Errorf("board ratings not rebuilt from the scalars: %v", h.BoardRating)
+	}
+	if h.PosCornerRating != 3 || h.PosSideRating != 4 || h.PosMiddleRating != 5 || h.PosRating[8] != 5 {
+		t.Errorf("missing position ratings not taken from the array: %+v", h)
+	}
+}
+
+func TestParseHeuristicRejectsInvalid(t *testing.T) {
+	data, _ := json.Marshal(DefaultHeuristic())
+	valid := string(data)
+
+	invalid := map[string]string{
+		"unknown field":  strings.Replace(valid, "{", `{"SomethingRating":1,`, 1),
+		"missing rating": strings.Replace(valid, `"WonBoardRating":24,`, "", 1),
+		"asymmetric":     strings.Replace(strings.Replace(valid, `"BoardCornerRating":1.5,`, "", 1), "{", `{"BoardRating":[1,2,3,2,1,2,1,2,0],`, 1),
+		"not an object":  "[1, 2]",
+	}
+	for name, data := range invalid {
+		if _, err := ParseHeuristic([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLoadEliteFiles(t *testing.T) {
+	for path, count := range map[string]int{"../elitePop_747.txt": 140, "../elitePop_753.txt": 171} {
+		heuristics, err := LoadHeuristics(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(heuristics) != count {
+			t.Errorf("%s: loaded %d weight sets, want %d", path, len(heuristics), count)
+		}
+	}
+}
+
+func TestLoadHeuristicByIndexAndFitness(t *testing.T) {
+	path := t.TempDir() + "/elitePop.txt"
+	var lines []string
+	for i, fitness := range []int{700, 753, 720} {
+		h := DefaultHeuristic()
+		h.WonBoardRating = float64(i)
+		data, err := json.Marshal(Elite{Fitness: fitness, Heuristic: h})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(data))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if h, err := LoadHeuristic(path, 2); err != nil || h.WonBoardRating != 2 {
+		t.Fatalf("index 2 loaded %v, %v", h, err)
+	}
+	if h, err := LoadHeuristic(path, BestHeuristic); err != nil || h.WonBoardRating != 1 {
+		t.Fatalf("best loaded %v, %v", h, err)
+	}
+	if _, err := LoadHeuristic(path, 3); err == nil {
+		t.Fatalf("index past the end should fail")
+	}
+}
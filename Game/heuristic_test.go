@@ -0,0 +1,52 @@
+package Game
+
+import "testing"
+
+func TestBoardCacheMatchesRating(t *testing.T) {
+	h := DefaultHeuristic()
+	h.TwoInARowAdvantageRating = 17
+	h.PosCornerRating = 4
+	h.Normalise()
+
+	game := NewGame()
+	game.HeuristicScores = h
+	for state := 0; state < boardStates; state++ {
+		var board uint32 = 0
+		for i, digits := 0, state; i < boardLength; i, digits = i+1, digits/3 {
+			board = popBoardHelper(board, i, digits%3-1)
+		}
+
+		for _, player := range []Player{Player1, Player2} {
+			if cached, rated := game.HeuristicBoard(player, board, false), h.heuristicBoard(player, board, false); cached != rated {
+				t.Fatalf("board %x player %d: cached %f, rated %f", board, player, cached, rated)
+			}
+		}
+	}
+}
+
+func TestBreakdownAddsUpToHeuristic(t *testing.T) {
+	Seed(2)
+	for i := 0; i < 50; i++ {
+		game := NewGame()
+		for moves := 0; moves < i && !game.IsTerminal(); moves++ {
+			var moves [][2]byte
+			game.GetMoves(func(board byte, pos byte) bool {
+				moves = append(moves, [2]byte{board, pos})
+				return false
+			})
+			move := moves[RandSource.Intn(len(moves))]
+			game.MakeMove(move[0], move[1])
+		}
+
+		for _, player := range []Player{Player1, Player2} {
+			b := game.Breakdown(player)
+			sum := b.Terminal + b.GlobalState + b.Overall
+			for _, board := range b.Boards {
+				sum += board
+			}
+			if want := game.HeuristicPlayer(player); b.Total != want || sum-want > 1e-9 || want-sum > 1e-9 {
+				t.Fatalf("breakdown %+v does not add up to %f", b, want)
+			}
+		}
+	}
+}
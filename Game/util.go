@@ -2,6 +2,13 @@ package Game
 
 var BoardCompletedStorage = [512]bool{}
 
+// The completed boards are needed before the first game is created to rate the boards of the heuristics
+func init() {
+	for board := uint32(0); board < 512; board++ {
+		BoardCompletedStorage[board] = CheckCompletedHelper(board)
+	}
+}
+
 func CheckCompleted(test uint32) bool {
 	return BoardCompletedStorage[test]
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+// readOpenings reads one opening in move notation per line, empty lines and lines starting with # are skipped
+func readOpenings(reader io.Reader) ([][]match.Move, error) {
+	var openings [][]match.Move
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		moves, err := match.ParseMoves(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		openings = append(openings, moves)
+	}
+	return openings, scanner.Err()
+}
+
+// suite returns the openings of pairs games pairs, the suite is repeated when it is shorter.
+// Without a suite every pair gets a random opening of plies moves
+func suite(openings [][]match.Move, pairs, plies int, rng *rand.Rand) [][]match.Move {
+	games := make([][]match.Move, pairs)
+	for i := range games {
+		switch {
+		case len(openings) > 0:
+			games[i] = openings[i%len(openings)]
+		case plies > 0:
+			games[i] = match.RandomOpening(rng, plies)
+		}
+	}
+	return games
+}
+
+// result is the match from the view of the first engine, the time is counted per engine
+type result struct {
+	score    match.Score
+	time     [2]time.Duration
+	searches [2]int
+}
+
+// add counts a game the first engine played as player
+func (r *result) add(record *match.Record, player Game.Player) {
+	r.score.AddRecord(record, player)
+	opponent := Game.Player(1 - player)
+	r.time[0] += record.Time[player]
+	r.searches[0] += record.Searches[player]
+	r.time[1] += record.Time[opponent]
+	r.searches[1] += record.Searches[opponent]
+}
+
+// moveTime is the average search time per move of the engine
+func (r *result) moveTime(engine int) time.Duration {
+	if r.searches[engine] == 0 {
+		return 0
+	}
+	return r.time[engine] / time.Duration(r.searches[engine])
+}
+
+func (r *result) write(w io.Writer, names [2]string) {
+	diff, margin := r.score.Elo()
+	fmt.Fprintf(w, "%s vs %s\n", names[0], names[1])
+	fmt.Fprintf(w, "games %d\tW/D/L %d/%d/%d\tscore %.3f\n", r.score.Games(), r.score.Wins, r.score.Draws, r.score.Losses, r.score.Mean())
+	if math.IsInf(margin, 0) || math.IsNaN(margin) {
+		fmt.Fprintf(w, "elo %+.1f, the error is unbounded, play more games\n", diff)
+	} else {
+		fmt.Fprintf(w, "elo %+.1f ± %.1f\n", diff, margin)
+	}
+	for engine, name := range names {
+		fmt.Fprintf(w, "%s\t%d moves\t%.1fms per move\n", name, r.searches[engine], float64(r.moveTime(engine))/float64(time.Millisecond))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+func TestReadOpenings(t *testing.T) {
+	openings, err := readOpenings(strings.NewReader("# suite\n84 40\n\n80\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(openings) != 2 || len(openings[0]) != 2 || openings[1][0] != (match.Move{Board: 8}) {
+		t.Fatalf("read %v", openings)
+	}
+	if _, err = readOpenings(strings.NewReader("84 30\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("illegal opening gave %v", err)
+	}
+
+	pairs := suite(openings, 5, 2, rand.New(rand.NewSource(1)))
+	if len(pairs) != 5 || len(pairs[4]) != 2 {
+		t.Fatalf("suite of 5 pairs is %v", pairs)
+	}
+	if random := suite(nil, 3, 0, nil); len(random) != 3 || random[0] != nil {
+		t.Fatalf("no plies gave %v", random)
+	}
+}
+
+func TestResultCountsBothEngines(t *testing.T) {
+	bots := [2]*match.Bot{{Name: "a", Heuristic: Game.DefaultHeuristic(), Depth: 2}, {Name: "b", Heuristic: Game.DefaultHeuristic(), Depth: 3}}
+	r := result{}
+	match.PlayPairs(bots[0], bots[1], [][]match.Move{nil}, 2, func(record *match.Record, player Game.Player) bool {
+		if record.Players[player] != "a" {
+			t.Errorf("engine a played %s", record.Players[player])
+		}
+		r.add(record, player)
+		return false
+	})
+
+	if r.score.Games() != 2 || r.searches[0] == 0 || r.searches[1] == 0 || r.moveTime(0) <= 0 {
+		t.Fatalf("result %+v", r)
+	}
+	if empty := (&result{}); empty.moveTime(1) != time.Duration(0) {
+		t.Fatal("no moves have a move time")
+	}
+
+	out := strings.Builder{}
+	r.write(&out, [2]string{"a", "b"})
+	if !strings.Contains(out.String(), "W/D/L") || !strings.Contains(out.String(), "per move") {
+		t.Fatalf("report %q", out.String())
+	}
+}
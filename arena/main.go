@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/timing"
+)
+
+func main() {
+	engine1 := flag.String("engine1", "mtd", "spec of the first engine, see match.ParseBot, e.g. mtd:depth=7,time=100ms,heuristic=elitePop.txt")
+	engine2 := flag.String("engine2", "mcts", "spec of the second engine, e.g. mcts:selection=uct2,rounds=20000,time=0s")
+	games := flag.Int("games", 100, "games to play, every opening is played twice with the colours swapped")
+	openingsPath := flag.String("openings", "", "opening suite with the moves of one opening per line, it is repeated when shorter than the match")
+	openingPlies := flag.Int("opening-plies", 2, "moves of the random openings without a suite, 0 plays the start position")
+	threads := flag.Int("threads", 4, "games played at the same time")
+	recordsPath := flag.String("records", "", "file every finished game is appended to in the record format")
+	clock := timing.Control{}
+	flag.DurationVar(&clock.Total, "clock", 0, "time of each engine for a game, the engines then allocate their moves from it and lose when it runs out")
+	flag.DurationVar(&clock.Increment, "increment", 0, "time added to the clock after every move")
+	seed := flag.Int64("seed", 0, "seed of the openings and playouts, 0 picks one from the clock")
+
+	test := sprt{}
+	useSPRT := flag.Bool("sprt", false, "stop once H0 or H1 is accepted, -games is then the most games played. "+
+		"The exit status is 0 when H1 is accepted, 1 when H0 is accepted and 3 when the games ran out")
+	flag.Float64Var(&test.Elo0, "elo0", 0, "Elo difference of H0")
+	flag.Float64Var(&test.Elo1, "elo1", 5, "Elo difference of H1")
+	flag.Float64Var(&test.Alpha, "alpha", 0.05, "chance to accept H1 when H0 holds")
+	flag.Float64Var(&test.Beta, "beta", 0.05, "chance to accept H0 when H1 holds")
+	flag.Parse()
+
+	if *games < 2 || *threads < 1 || *openingPlies < 0 || clock.Total < 0 || clock.Increment < 0 {
+		log.Fatalln("invalid settings, see -help")
+	}
+	if *useSPRT {
+		if err := test.validate(); err != nil {
+			log.Fatalln("sprt:", err)
+		}
+	}
+	bots := [2]*match.Bot{}
+	for i, spec := range []string{*engine1, *engine2} {
+		var err error
+		if bots[i], err = match.ParseBot(spec); err != nil {
+			log.Fatalln(err)
+		}
+		bots[i].Clock = clock
+	}
+
+	var openings [][]match.Move
+	if *openingsPath != "" {
+		f, err := os.Open(*openingsPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		openings, err = readOpenings(f)
+		_ = f.Close()
+		if err != nil {
+			log.Fatalln(*openingsPath+":", err)
+		}
+		if len(openings) == 0 {
+			log.Fatalln(*openingsPath, "has no openings")
+		}
+	}
+
+	var records *os.File
+	if *recordsPath != "" {
+		var err error
+		if records, err = os.OpenFile(*recordsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			log.Fatalln(err)
+		}
+		defer records.Close()
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	Game.Seed(*seed)
+	fmt.Println("seed", *seed)
+
+	r := result{}
+	verdict := CONTINUE
+	pairs := suite(openings, (*games+1)/2, *openingPlies, rand.New(rand.NewSource(*seed)))
+	match.PlayPairs(bots[0], bots[1], pairs, *threads, func(record *match.Record, player Game.Player) bool {
+		r.add(record, player)
+		if records != nil {
+			if err := record.Write(records); err != nil {
+				log.Fatalln("records:", err)
+			}
+		}
+		if !*useSPRT {
+			fmt.Printf("%d\t%s\t%s\n", r.score.Games(), record.Result(), r.score)
+			return false
+		}
+
+		verdict = test.decide(r.score)
+		fmt.Printf("%d\t%s\t%s\t%s\n", r.score.Games(), record.Result(), r.score, test.progress(r.score))
+		return verdict != CONTINUE
+	})
+
+	fmt.Println()
+	r.write(os.Stdout, [2]string{bots[0].Name, bots[1].Name})
+	if !*useSPRT {
+		return
+	}
+
+	fmt.Println("sprt", verdict, test.progress(r.score))
+	switch verdict {
+	case ACCEPT_H0:
+		os.Exit(1)
+	case CONTINUE:
+		os.Exit(3)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+type decision byte
+
+const (
+	CONTINUE  decision = 0
+	ACCEPT_H0 decision = 1
+	ACCEPT_H1 decision = 2
+)
+
+func (d decision) String() string {
+	switch d {
+	case ACCEPT_H0:
+		return "H0 accepted"
+	case ACCEPT_H1:
+		return "H1 accepted"
+	}
+	return "inconclusive"
+}
+
+// sprt tests H0, the first engine is Elo0 stronger, against H1, it is Elo1 stronger.
+// Alpha is the chance to accept H1 when H0 holds and Beta the chance to accept H0 when H1 holds
+type sprt struct {
+	Elo0  float64
+	Elo1  float64
+	Alpha float64
+	Beta  float64
+}
+
+func (s sprt) validate() error {
+	switch {
+	case s.Elo0 >= s.Elo1:
+		return fmt.Errorf("elo0 must be below elo1")
+	case s.Alpha <= 0 || s.Alpha >= 0.5 || s.Beta <= 0 || s.Beta >= 0.5:
+		return fmt.Errorf("alpha and beta must be within (0, 0.5)")
+	}
+	return nil
+}
+
+// bounds are the log likelihood ratios that accept H0 and H1
+func (s sprt) bounds() (float64, float64) {
+	return math.Log(s.Beta / (1 - s.Alpha)), math.Log((1 - s.Beta) / s.Alpha)
+}
+
+// expectedScore is the mean score of a player elo stronger than its opponent
+func expectedScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// llr is the log likelihood ratio of the score, the mean score is approximated by a normal distribution
+// with the variance of the played games so draws narrow the test
+func (s sprt) llr(score match.Score) float64 {
+	games := float64(score.Games())
+	if games == 0 {
+		return 0
+	}
+
+	mean := score.Mean()
+	variance := (float64(score.Wins)*(1-mean)*(1-mean) + float64(score.Draws)*(0.5-mean)*(0.5-mean) + float64(score.Losses)*mean*mean) / games
+	// All games ended the same way, the variance is unknown until another result comes up
+	if variance == 0 {
+		return 0
+	}
+
+	s0, s1 := expectedScore(s.Elo0), expectedScore(s.Elo1)
+	return games * (s1 - s0) * (2*mean - s0 - s1) / (2 * variance)
+}
+
+func (s sprt) decide(score match.Score) decision {
+	lower, upper := s.bounds()
+	switch llr := s.llr(score); {
+	case llr <= lower:
+		return ACCEPT_H0
+	case llr >= upper:
+		return ACCEPT_H1
+	}
+	return CONTINUE
+}
+
+func (s sprt) progress(score match.Score) string {
+	lower, upper := s.bounds()
+	return fmt.Sprintf("LLR %.2f (%.2f, %.2f) [%g, %g]", s.llr(score), lower, upper, s.Elo0, s.Elo1)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+func TestSPRTDecides(t *testing.T) {
+	test := sprt{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05}
+	if err := test.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	lower, upper := test.bounds()
+	if lower >= 0 || upper <= 0 || lower != -upper {
+		t.Fatalf("bounds (%f, %f) are not symmetric around 0", lower, upper)
+	}
+	if d := test.decide(match.Score{Wins: 3, Losses: 2}); d != CONTINUE {
+		t.Fatalf("5 games gave %s", d)
+	}
+	if d := test.decide(match.Score{Draws: 100}); d != CONTINUE {
+		t.Fatalf("only draws gave %s", d)
+	}
+	if d := test.decide(match.Score{Wins: 600, Draws: 200, Losses: 400}); d != ACCEPT_H1 {
+		t.Fatalf("a score of 0.58 gave %s", d)
+	}
+	if d := test.decide(match.Score{Wins: 400, Draws: 200, Losses: 600}); d != ACCEPT_H0 {
+		t.Fatalf("a score of 0.42 gave %s", d)
+	}
+
+	// The score halfway between the hypotheses favours neither
+	mean := (expectedScore(0) + expectedScore(10)) / 2
+	if llr := test.llr(match.Score{Wins: int(mean * 10000), Losses: 10000 - int(mean*10000)}); llr > 0.5 || llr < -0.5 {
+		t.Fatalf("llr %f halfway between the hypotheses", llr)
+	}
+
+	for _, invalid := range []sprt{{Elo0: 5, Elo1: 5, Alpha: 0.05, Beta: 0.05}, {Elo1: 5, Alpha: 0, Beta: 0.05}} {
+		if invalid.validate() == nil {
+			t.Fatalf("%+v was accepted", invalid)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// localPackage is a package of the module, it is copied into the bundle
+type localPackage struct {
+	path  string
+	dir   string
+	files []*ast.File
+	types *types.Package
+	info  *types.Info
+}
+
+// bundler inlines the module packages a main package imports, only the standard library stays imported
+type bundler struct {
+	fset   *token.FileSet
+	root   string
+	module string
+	std    types.Importer
+
+	packages map[string]*localPackage
+	// order lists the packages with every dependency before the packages importing it, the main package last
+	order []*localPackage
+
+	names   map[types.Object]string
+	renames map[types.Object]int
+	imports map[string]string
+}
+
+func newBundler(root string, module string) *bundler {
+	fset := token.NewFileSet()
+	return &bundler{
+		fset:     fset,
+		root:     root,
+		module:   module,
+		std:      importer.ForCompiler(fset, "source", nil),
+		packages: map[string]*localPackage{},
+		names:    map[types.Object]string{},
+		renames:  map[types.Object]int{},
+		imports:  map[string]string{},
+	}
+}
+
+// findModule returns the root and path of the module dir is in
+func findModule(dir string) (string, string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "module" {
+					return dir, strings.Trim(fields[1], `"`), nil
+				}
+			}
+			return "", "", fmt.Errorf("%s: no module path", filepath.Join(dir, "go.mod"))
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", fmt.Errorf("no go.mod above %s", dir)
+		}
+		dir = parent
+	}
+}
+
+// Bundle returns the main package in dir and the module packages it depends on as a single gofmt-ed file
+func Bundle(dir string) ([]byte, error) {
+	root, module, err := findModule(dir)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+
+	b := newBundler(root, module)
+	main, err := b.load(path.Join(module, filepath.ToSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	if main.types.Name() != "main" {
+		return nil, fmt.Errorf("%s is package %s, not main", main.path, main.types.Name())
+	}
+
+	kept, declOf := b.reachable()
+	b.rename(kept, declOf)
+	return b.write(main, kept)
+}
+
+func (b *bundler) isLocal(importPath string) bool {
+	return importPath == b.module || strings.HasPrefix(importPath, b.module+"/")
+}
+
+// Import resolves the imports while type checking, module packages are loaded from source
+func (b *bundler) Import(importPath string) (*types.Package, error) {
+	if b.isLocal(importPath) {
+		p, err := b.load(importPath)
+		if err != nil {
+			return nil, err
+		}
+		return p.types, nil
+	}
+	if first, _, _ := strings.Cut(importPath, "/"); strings.Contains(first, ".") {
+		return nil, fmt.Errorf("%s is outside the module and the standard library", importPath)
+	}
+	return b.std.Import(importPath)
+}
+
+func (b *bundler) load(importPath string) (*localPackage, error) {
+	if p, ok := b.packages[importPath]; ok {
+		if p.types == nil {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		return p, nil
+	}
+
+	dir := filepath.Join(b.root, filepath.FromSlash(strings.TrimPrefix(strings.TrimPrefix(importPath, b.module), "/")))
+	p := &localPackage{path: importPath, dir: dir}
+	b.packages[importPath] = p
+
+	pkg, err := build.Default.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkg.CgoFiles) > 0 {
+		return nil, fmt.Errorf("%s uses cgo", importPath)
+	}
+	for _, name := range pkg.GoFiles {
+		f, err := parser.ParseFile(b.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range f.Imports {
+			if spec.Name != nil && spec.Name.Name == "." {
+				return nil, fmt.Errorf("%s: dot imports can't be bundled", b.fset.Position(spec.Pos()))
+			}
+		}
+		p.files = append(p.files, f)
+	}
+
+	p.info = &types.Info{
+		Defs:   map[*ast.Ident]types.Object{},
+		Uses:   map[*ast.Ident]types.Object{},
+		Types:  map[ast.Expr]types.TypeAndValue{},
+		Scopes: map[ast.Node]*types.Scope{},
+	}
+	config := types.Config{Importer: b}
+	if p.types, err = config.Check(importPath, b.fset, p.files, p.info); err != nil {
+		return nil, err
+	}
+
+	// The imports were loaded while checking, they come first
+	b.order = append(b.order, p)
+	return p, nil
+}
+
+// isPackageLevel reports if obj is declared at the top level of a module package, methods and fields are not
+func (b *bundler) isPackageLevel(obj types.Object) bool {
+	return obj != nil && obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() && b.isLocal(obj.Pkg().Path())
+}
+
+// candidate returns the n-th name tried for obj, the name itself and then prefixed by its package
+func candidate(obj types.Object, n int) string {
+	if n == 0 {
+		return obj.Name()
+	}
+	name := []rune(obj.Name())
+	name[0] = unicode.ToUpper(name[0])
+	prefixed := obj.Pkg().Name() + string(name)
+	if n > 1 {
+		prefixed += strconv.Itoa(n)
+	}
+	return prefixed
+}
+
+// rename gives every kept top level name of the bundle a unique name. The main package keeps its names,
+// the dependencies keep theirs unless another package or a builtin already uses it
+func (b *bundler) rename(kept map[ast.Decl]bool, declOf map[types.Object]ast.Decl) {
+	taken := map[string]bool{}
+	for _, p := range b.order {
+		isMain := p.types.Name() == "main"
+		for _, name := range p.types.Scope().Names() {
+			obj := p.types.Scope().Lookup(name)
+			if isMain {
+				b.names[obj] = name
+				taken[name] = true
+			}
+		}
+	}
+
+	assign := func(obj types.Object) {
+		for taken[candidate(obj, b.renames[obj])] || types.Universe.Lookup(candidate(obj, b.renames[obj])) != nil {
+			b.renames[obj]++
+		}
+		b.names[obj] = candidate(obj, b.renames[obj])
+		taken[b.names[obj]] = true
+	}
+	for _, p := range b.order {
+		if p.types.Name() == "main" {
+			continue
+		}
+		for _, name := range p.types.Scope().Names() {
+			if obj := p.types.Scope().Lookup(name); kept[declOf[obj]] {
+				assign(obj)
+			}
+		}
+	}
+
+	// A qualified name turns into a plain one, a local declaration of the same name would hide it
+	for shadowed := true; shadowed; {
+		shadowed = false
+		for _, p := range b.order {
+			for _, f := range p.files {
+				ast.Inspect(f, func(n ast.Node) bool {
+					sel, ok := n.(*ast.SelectorExpr)
+					if !ok || !b.isQualified(p, sel) {
+						return true
+					}
+
+					obj := p.info.Uses[sel.Sel]
+					if _, ok := b.names[obj]; !ok {
+						return false
+					}
+					scope, found := p.info.Scopes[f].Innermost(sel.Pos()).LookupParent(b.names[obj], sel.Pos())
+					if found != nil && found != obj && scope != p.types.Scope() && scope != p.info.Scopes[f] && scope != types.Universe {
+						delete(taken, b.names[obj])
+						b.renames[obj]++
+						assign(obj)
+						shadowed = true
+					}
+					return false
+				})
+			}
+		}
+	}
+
+	// The standard library imports are named after the package unless the name is taken
+	var paths []string
+	for _, p := range b.order {
+		for _, imported := range p.types.Imports() {
+			if _, ok := b.imports[imported.Path()]; !ok && !b.isLocal(imported.Path()) {
+				b.imports[imported.Path()] = ""
+				paths = append(paths, imported.Path())
+			}
+		}
+	}
+	sort.Strings(paths)
+	for _, importPath := range paths {
+		name := importName(importPath, b)
+		for n := 2; taken[name]; n++ {
+			name = importName(importPath, b) + strconv.Itoa(n)
+		}
+		b.imports[importPath] = name
+		taken[name] = true
+	}
+}
+
+func importName(importPath string, b *bundler) string {
+	if importPath == "unsafe" {
+		return "unsafe"
+	}
+	pkg, err := b.std.Import(importPath)
+	if err != nil {
+		return path.Base(importPath)
+	}
+	return pkg.Name()
+}
+
+// isQualified reports if sel refers to a declaration of another module package
+func (b *bundler) isQualified(p *localPackage, sel *ast.SelectorExpr) bool {
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	pkgName, ok := p.info.Uses[x].(*types.PkgName)
+	return ok && b.isLocal(pkgName.Imported().Path())
+}
+
+type edit struct {
+	start int
+	end   int
+	text  string
+}
+
+// write prints the bundle, the declarations nothing refers to are left out
+func (b *bundler) write(main *localPackage, kept map[ast.Decl]bool) ([]byte, error) {
+	var body bytes.Buffer
+	used := map[string]bool{}
+	for _, p := range b.order {
+		for _, f := range p.files {
+			text, err := b.writeFile(p, f, kept, used)
+			if err != nil {
+				return nil, err
+			}
+			body.Write(text)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by bundle from %s; DO NOT EDIT.\n\n", main.path)
+	for _, f := range main.files {
+		if f.Doc != nil {
+			for _, c := range f.Doc.List {
+				fmt.Fprintln(&out, c.Text)
+			}
+			break
+		}
+	}
+	out.WriteString("package main\n\n")
+
+	var paths []string
+	for importPath := range used {
+		paths = append(paths, importPath)
+	}
+	sort.Strings(paths)
+	out.WriteString("import (\n")
+	for _, importPath := range paths {
+		if name := b.imports[importPath]; name != importName(importPath, b) {
+			fmt.Fprintf(&out, "\t%s %q\n", name, importPath)
+		} else {
+			fmt.Fprintf(&out, "\t%q\n", importPath)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("bundle does not parse: %w", err)
+	}
+	return source, b.check(source)
+}
+
+// check type checks the bundle, a name that changed meaning fails here rather than in the build
+func (b *bundler) check(source []byte) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "bundle.go", source, 0)
+	if err != nil {
+		return err
+	}
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err = config.Check("main", fset, []*ast.File{f}, nil); err != nil {
+		return fmt.Errorf("bundle does not type check: %w", err)
+	}
+	return nil
+}
+
+// writeFile returns the declarations of f that are kept with the names of the bundle
+func (b *bundler) writeFile(p *localPackage, f *ast.File, kept map[ast.Decl]bool, used map[string]bool) ([]byte, error) {
+	file := b.fset.File(f.Pos())
+	source, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	offset := func(pos token.Pos) int { return file.Offset(pos) }
+
+	// The package clause and everything above it belong to the file
+	edits := []edit{{0, offset(f.Name.End()), ""}}
+	for _, decl := range f.Decls {
+		start := decl.Pos()
+		if doc := declDoc(decl); doc != nil {
+			start = doc.Pos()
+		}
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			for _, spec := range gen.Specs {
+				if spec := spec.(*ast.ImportSpec); spec.Name != nil && spec.Name.Name == "_" {
+					used[strings.Trim(spec.Path.Value, `"`)] = true
+				}
+			}
+			edits = append(edits, edit{offset(start), offset(decl.End()), ""})
+			continue
+		}
+		if !kept[decl] {
+			edits = append(edits, edit{offset(start), offset(decl.End()), ""})
+			continue
+		}
+
+		ast.Inspect(decl, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				x, ok := n.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				pkgName, ok := p.info.Uses[x].(*types.PkgName)
+				if !ok {
+					return true
+				}
+				if b.isLocal(pkgName.Imported().Path()) {
+					edits = append(edits, edit{offset(n.Pos()), offset(n.End()), b.names[p.info.Uses[n.Sel]]})
+					return false
+				}
+
+				name := b.imports[pkgName.Imported().Path()]
+				used[pkgName.Imported().Path()] = true
+				if x.Name != name {
+					edits = append(edits, edit{offset(x.Pos()), offset(x.End()), name})
+				}
+				return false
+			case *ast.Ident:
+				obj := p.info.Uses[n]
+				if obj == nil {
+					obj = p.info.Defs[n]
+				}
+				// init functions are not in the package scope and keep their name
+				if name, ok := b.names[obj]; ok && name != n.Name {
+					edits = append(edits, edit{offset(n.Pos()), offset(n.End()), name})
+				}
+			}
+			return true
+		})
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	position := 0
+	for _, e := range edits {
+		if e.start < position {
+			return nil, fmt.Errorf("%s: overlapping rewrites", file.Position(file.Pos(e.start)))
+		}
+		out.Write(source[position:e.start])
+		out.WriteString(e.text)
+		position = e.end
+	}
+	out.Write(source[position:])
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		return decl.Doc
+	case *ast.GenDecl:
+		return decl.Doc
+	}
+	return nil
+}
+
+// reachable returns the declarations the main package needs and the declaration of every top level name.
+// Everything of the main package, the init functions and the variables initialised by a call are kept,
+// with the declarations they refer to and the methods of kept types
+func (b *bundler) reachable() (map[ast.Decl]bool, map[types.Object]ast.Decl) {
+	declOf := map[types.Object]ast.Decl{}
+	methods := map[types.Object][]ast.Decl{}
+	owner := map[ast.Decl]*localPackage{}
+	var queue []ast.Decl
+
+	for _, p := range b.order {
+		isMain := p.types.Name() == "main"
+		for _, f := range p.files {
+			for _, decl := range f.Decls {
+				owner[decl] = p
+				root := isMain
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					if decl.Recv != nil {
+						recv := p.info.Types[decl.Recv.List[0].Type].Type
+						if ptr, ok := recv.(*types.Pointer); ok {
+							recv = ptr.Elem()
+						}
+						if named, ok := recv.(*types.Named); ok {
+							methods[named.Obj()] = append(methods[named.Obj()], decl)
+						}
+						continue
+					}
+					root = root || decl.Name.Name == "init"
+					declOf[p.info.Defs[decl.Name]] = decl
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						switch spec := spec.(type) {
+						case *ast.TypeSpec:
+							declOf[p.info.Defs[spec.Name]] = decl
+						case *ast.ValueSpec:
+							for _, name := range spec.Names {
+								declOf[p.info.Defs[name]] = decl
+								root = root || name.Name == "_"
+							}
+							for _, value := range spec.Values {
+								root = root || hasCall(p.info, value)
+							}
+						}
+					}
+				}
+				if root {
+					queue = append(queue, decl)
+				}
+			}
+		}
+	}
+
+	kept := map[ast.Decl]bool{}
+	for len(queue) > 0 {
+		decl := queue[0]
+		queue = queue[1:]
+		if kept[decl] {
+			continue
+		}
+		kept[decl] = true
+
+		p := owner[decl]
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if obj := p.info.Uses[id]; b.isPackageLevel(obj) {
+					queue = append(queue, declOf[obj])
+				}
+				if obj := p.info.Defs[id]; obj != nil {
+					queue = append(queue, methods[obj]...)
+				}
+			}
+			return true
+		})
+	}
+	return kept, declOf
+}
+
+// hasCall reports if evaluating expr calls a function, conversions don't count
+func hasCall(info *types.Info, expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		if call, ok := n.(*ast.CallExpr); ok && !info.Types[call.Fun].IsType() {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCodinGameBundles fails when a bot changed without go generate
+func TestCodinGameBundles(t *testing.T) {
+	for _, bot := range []string{"minimax", "mcts"} {
+		dir := filepath.Join("..", "codinggame", bot)
+		source, err := Bundle(filepath.Join(dir, "bot"))
+		if err != nil {
+			t.Fatal(bot, err)
+		}
+		generated, err := os.ReadFile(filepath.Join(dir, "minimax_bot.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(source, generated) {
+			t.Errorf("codinggame/%s/minimax_bot.go is out of date, run go generate ./codinggame/...", bot)
+		}
+		if len(source) > codinGameLimit {
+			t.Errorf("the %s bot has %d characters", bot, len(source))
+		}
+	}
+}
+
+func writeModule(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	files["go.mod"] = "module example.com/bundled\n\ngo 1.20\n"
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBundleRenames(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"a/a.go": `package a
+
+import "math/rand"
+
+type Node struct{ Value int }
+
+const inf = 1
+
+func New() *Node { return &Node{Value: rand.Intn(1) + inf} }
+
+func unused() int { return 0 }
+`,
+		"b/b.go": `package b
+
+import (
+	"crypto/rand"
+
+	"example.com/bundled/a"
+)
+
+type Node struct{ Child *a.Node }
+
+const inf = 2
+
+func New() *Node {
+	var buf [1]byte
+	rand.Read(buf[:])
+	return &Node{Child: a.New()}
+}
+
+func Depth(n *Node) int { return inf }
+`,
+		"cmd/main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/bundled/b"
+)
+
+var rand = 3
+
+func main() {
+	Depth := 0
+	fmt.Println(Depth, b.Depth(b.New()), rand)
+}
+`,
+	})
+
+	source, err := Bundle(filepath.Join(root, "cmd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(source)
+	for _, want := range []string{
+		"type Node struct{ Value int }",
+		"type bNode struct{ Child *Node }",
+		"const bInf = 2",
+		"func bDepth(n *bNode) int { return bInf }",
+		`rand2 "crypto/rand"`,
+		`rand3 "math/rand"`,
+		"bDepth(bNew())",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("bundle is missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "unused") {
+		t.Errorf("bundle keeps a declaration nothing refers to:\n%s", text)
+	}
+}
+
+func TestBundleRejectsExternalPackages(t *testing.T) {
+	root := writeModule(t, map[string]string{
+		"main.go": "package main\n\nimport _ \"example.org/other\"\n\nfunc main() {}\n",
+	})
+	if _, err := Bundle(root); err == nil || !strings.Contains(err.Error(), "outside the module") {
+		t.Errorf("external import bundled, error %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// codinGameLimit is the most characters CodinGame accepts for a submission
+const codinGameLimit = 100000
+
+// bundle writes a main package and the packages of the module it imports as one package main file,
+// the way CodinGame takes a submission:
+//
+//	go run ./bundle -o codinggame/minimax/minimax_bot.go ./codinggame/minimax/bot
+//
+// Declarations of the dependencies are renamed with their package as prefix when the name is taken,
+// declarations nothing refers to are left out
+func main() {
+	output := flag.String("o", "", "file the bundle is written to, empty writes to stdout")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: bundle [-o file] <main package dir>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	source, err := Bundle(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(source) > codinGameLimit {
+		log.Printf("the bundle has %d characters, CodinGame takes at most %d\n", len(source), codinGameLimit)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(source)
+	} else {
+		err = os.WriteFile(*output, source, 0644)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
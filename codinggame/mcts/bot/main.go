@@ -0,0 +1,86 @@
+//go:generate go run ../../../bundle -o ../minimax_bot.go .
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/codinggame/protocol"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+	"github.com/FabianPetersen/UltimateTicTacToe/timing"
+)
+
+// CodinGame allows firstTurnTime for the first turn of a bot and turnTime for the others. Overhead is kept back
+// for reading the turn and writing the move, which wait for the pondering to stop. The first turn also keeps back
+// the start of the process
+const (
+	firstTurnTime = time.Second
+	turnTime      = 100 * time.Millisecond
+	overhead      = 25 * time.Millisecond
+	startup       = 100 * time.Millisecond
+)
+
+// The CodinGame MCTS bot, minimax_bot.go in the parent directory is this package bundled by go generate.
+// The tree is kept for the whole game and searched during the turn of the opponent, the reply continues below it
+func main() {
+	// The first move may be played on any board
+	game := protocol.NewGame()
+	mcts := gmcts.NewMCTS(game, gmcts.DefaultConfig())
+	control := timing.Control{MoveTime: firstTurnTime, Overhead: startup}
+	var ponder *minimax.Budget
+	var pondered chan struct{}
+	// The next turn is read while the bot ponders, with a single P reading waits until the search is preempted
+	runtime.GOMAXPROCS(2)
+	in := bufio.NewReader(os.Stdin)
+	for {
+		turn, err := protocol.ReadTurn(in)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		start := time.Now()
+		if ponder != nil {
+			ponder.Stop()
+			<-pondered
+			fmt.Fprintf(os.Stderr, "Pondered %d rounds\n", ponder.Nodes)
+		}
+		if turn.OpponentRow >= 0 {
+			boardIndex, moveIndex := protocol.Move(turn.OpponentRow, turn.OpponentCol)
+			fmt.Fprintf(os.Stderr, "Opp move BoardIndex %d MoveIndex %d \n", boardIndex, moveIndex)
+			game.MakeMove(boardIndex, moveIndex)
+			mcts.Advance(boardIndex, moveIndex)
+		}
+		if refereeOnly, gameOnly := turn.Mismatches(game); len(refereeOnly) > 0 || len(gameOnly) > 0 {
+			fmt.Fprintf(os.Stderr, "Valid actions differ, only the referee allows %v, only the game allows %v\n", refereeOnly, gameOnly)
+		}
+
+		budget := control.Budget(0, game)
+		budget.Start = start
+		mcts.SearchBudget(budget)
+		moveIndex, boardIndex := mcts.BestAction()
+		boardIndex, moveIndex, legal := turn.Legal(boardIndex, moveIndex)
+		if !legal {
+			fmt.Fprintln(os.Stderr, "The search played a move the referee does not allow, playing the first valid action")
+		}
+		fmt.Fprintf(os.Stderr, "Our move BoardIndex %d MoveIndex %d \n", boardIndex, moveIndex)
+		fmt.Fprintln(os.Stderr, time.Since(start))
+		game.MakeMove(boardIndex, moveIndex)
+		control = timing.Control{MoveTime: turnTime, Overhead: overhead}
+
+		row, col := protocol.Action(boardIndex, moveIndex)
+		fmt.Printf("%d %d\n", row, col)
+
+		// Search every reply of the opponent until the next turn
+		mcts.Advance(boardIndex, moveIndex)
+		ponder, pondered = minimax.NewPonderBudget(), make(chan struct{})
+		go func(budget *minimax.Budget, done chan struct{}) {
+			defer close(done)
+			mcts.SearchBudget(budget)
+		}(ponder, pondered)
+	}
+}
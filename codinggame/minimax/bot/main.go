@@ -0,0 +1,133 @@
+//go:generate go run ../../../bundle -o ../minimax_bot.go .
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/codinggame/protocol"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+	"github.com/FabianPetersen/UltimateTicTacToe/mtd"
+	"github.com/FabianPetersen/UltimateTicTacToe/timing"
+)
+
+// CodinGame allows firstTurnTime for the first turn of a bot and turnTime for the others. Overhead is kept back
+// for reading the turn and writing the move, which wait for the pondering to stop. The first turn also keeps back
+// the start of the process
+const (
+	firstTurnTime = time.Second
+	turnTime      = 100 * time.Millisecond
+	overhead      = 25 * time.Millisecond
+	startup       = 100 * time.Millisecond
+)
+
+// pondering is the search of the expected reply of the opponent during its turn
+type pondering struct {
+	budget   *minimax.Budget
+	done     chan struct{}
+	expected [2]byte
+	answer   [2]byte
+}
+
+// ponder searches the position after the expected reply of the principal variation, nil without one
+func ponder(game *Game.Game) *pondering {
+	boards, moves := minimax.PrincipalVariation(&minimax.TranspositionTable, game, 1)
+	if len(moves) == 0 {
+		return nil
+	}
+	state := game.Copy()
+	state.HeuristicScores = game.HeuristicScores
+	state.MakeMove(boards[0], moves[0])
+	if state.IsTerminal() {
+		return nil
+	}
+
+	p := &pondering{budget: minimax.NewPonderBudget(), done: make(chan struct{}), expected: [2]byte{boards[0], moves[0]}}
+	go func() {
+		defer close(p.done)
+		p.answer[1], p.answer[0] = mtd.IterativeDeepeningBudget(&minimax.TranspositionTable, &state, 20, p.budget, nil)
+	}()
+	return p
+}
+
+// The CodinGame minimax bot, minimax_bot.go in the parent directory is this package bundled by go generate.
+// During the turn of the opponent it searches the expected reply, after another reply the table is reused
+func main() {
+	heuristicPath := flag.String("heuristic", "", "weights or elite file, CodinGame runs without it")
+	heuristicIndex := flag.Int("heuristic-index", Game.BestHeuristic, "weight set in the heuristic file, -1 selects the highest fitness")
+	flag.Parse()
+
+	// The first move may be played on any board
+	game := protocol.NewGame()
+	if *heuristicPath != "" {
+		h, err := Game.LoadHeuristic(*heuristicPath, *heuristicIndex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		game.HeuristicScores = h
+	}
+	control := timing.Control{MoveTime: firstTurnTime, Overhead: startup}
+	var pondered *pondering
+	// The next turn is read while the bot ponders, with a single P reading waits until the search is preempted
+	runtime.GOMAXPROCS(2)
+	in := bufio.NewReader(os.Stdin)
+	for {
+		turn, err := protocol.ReadTurn(in)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		start := time.Now()
+		var opponent [2]byte
+		if turn.OpponentRow >= 0 {
+			boardIndex, moveIndex := protocol.Move(turn.OpponentRow, turn.OpponentCol)
+			fmt.Fprintf(os.Stderr, "Opp move BoardIndex %d MoveIndex %d \n", boardIndex, moveIndex)
+			game.MakeMove(boardIndex, moveIndex)
+			opponent = [2]byte{boardIndex, moveIndex}
+		}
+		if refereeOnly, gameOnly := turn.Mismatches(game); len(refereeOnly) > 0 || len(gameOnly) > 0 {
+			fmt.Fprintf(os.Stderr, "Valid actions differ, only the referee allows %v, only the game allows %v\n", refereeOnly, gameOnly)
+		}
+
+		budget := control.Budget(0, game)
+		budget.Start = start
+		var moveIndex, boardIndex byte = 255, 255
+		if pondered != nil {
+			if pondered.expected == opponent {
+				// The search of the expected reply continues on the time of the turn
+				fmt.Fprintln(os.Stderr, "Ponder hit")
+				pondered.budget.PonderHit(budget)
+				<-pondered.done
+				boardIndex, moveIndex = pondered.answer[0], pondered.answer[1]
+			} else {
+				pondered.budget.Stop()
+				<-pondered.done
+			}
+			pondered = nil
+		}
+		if !game.ValidMove(boardIndex, moveIndex) {
+			moveIndex, boardIndex = mtd.IterativeDeepeningBudget(&minimax.TranspositionTable, game, 20, budget, nil)
+		}
+		boardIndex, moveIndex, legal := turn.Legal(boardIndex, moveIndex)
+		if !legal {
+			fmt.Fprintln(os.Stderr, "The search played a move the referee does not allow, playing the first valid action")
+		}
+		fmt.Fprintf(os.Stderr, "Our move BoardIndex %d MoveIndex %d \n", boardIndex, moveIndex)
+		fmt.Fprintln(os.Stderr, time.Since(start))
+		game.MakeMove(boardIndex, moveIndex)
+		control = timing.Control{MoveTime: turnTime, Overhead: overhead}
+
+		row, col := protocol.Action(boardIndex, moveIndex)
+		fmt.Printf("%d %d\n", row, col)
+		if !game.IsTerminal() {
+			pondered = ponder(game)
+		}
+	}
+}
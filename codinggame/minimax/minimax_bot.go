@@ -1,54 +1,325 @@
+// Code generated by bundle from github.com/FabianPetersen/UltimateTicTacToe/codinggame/minimax/bot; DO NOT EDIT.
+
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"math"
+	"math/rand"
 	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-var boards = [][2]byte{
-	{0, 0},
-	{1, 0},
-	{2, 0},
+/*
+ last 3 byte (player 1, player 2, draw) = (31, 30, 29)
+ Second 9 byte = (9 - 17)
+ First 9 byte = (0 - 8)
 
-	{2, 1},
-	{2, 2},
+	// 0 1 2
+    // 7 8 3
+    // 6 5 4
+*/
 
-	{1, 2},
-	{0, 2},
-	{0, 1},
-	{1, 1},
+type Player byte
+type GameHash *[10]uint32
+
+const Player1 Player = 0
+const Player2 Player = 1
+const Draw Player = 2
+const boardLength = 9
+const GlobalBoard byte = 0xF0
+const PlayerBoardIndex = 9
+
+/*	corner -> middle -> side */
+var moveOrder = []byte{0, 4, 2, 6, 8, 1, 3, 5, 7}
+
+var RandSource = rand.New(rand.NewSource(time.Now().Unix()))
+
+var x = Xorshift(time.Now().Unix()) /*  time.Now().Unix() initial seed must be nonzero, don't use a static variable for the state if multithreaded */
+
+func init() {
+	populateMoves()
 }
 
-func translateOwnMove(board byte, move byte) (byte, byte) {
-	start := boards[board]
-	moveOffset := boards[move]
+// Xorshift is the state of a sequence of random playouts, searches running at the same time need their own
+type Xorshift uint64
+
+// Next returns a random number below n
+func (x *Xorshift) Next(n byte) byte {
+	*x ^= *x >> 12
+	*x ^= *x << 25
+	*x ^= *x >> 27
+	return byte((uint64(*x) * 2685821657736338717) % uint64(n))
+}
 
-	return (start[0] * 3) + moveOffset[0], (start[1] * 3) + moveOffset[1]
+type Game struct {
+	Board           [boardLength + 1]uint32
+	OverallBoard    uint32
+	HeuristicScores *HeuristicScores
 }
 
-func translateOppMove(x byte, y byte) (byte, byte) {
-	for boardIndex := 0; boardIndex < len(boards); boardIndex++ {
-		board := boards[boardIndex]
-		if x/3 == board[0] && y/3 == board[1] {
-			for moveIndex := 0; moveIndex < len(boards); moveIndex++ {
-				move := boards[moveIndex]
-				if x%3 == move[0] && y%3 == move[1] {
-					return byte(boardIndex), byte(moveIndex)
+func (g *Game) GetMoves(executeMove func(byte, byte) bool) {
+	currentBoard := byte(g.Board[PlayerBoardIndex] >> 1)
+	if currentBoard < 9 {
+		for _, move := range MovesStorage[((g.Board[currentBoard] | (g.Board[currentBoard] >> 9)) & 0x1FF)] {
+			if executeMove(currentBoard, move) {
+				return
+			}
+		}
+	} else {
+		jointOverallBoard := (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+		for _, i := range MovesStorage[jointOverallBoard] {
+			for _, move := range MovesStorage[((g.Board[i] | (g.Board[i] >> 9)) & 0x1FF)] {
+				if executeMove(i, move) {
+					return
 				}
 			}
 		}
 	}
-	return 0, 0
 }
 
-var BoardHeuristicCacheP1 = map[uint32]float64{}
-var BoardHeuristicCacheP2 = map[uint32]float64{}
+func (g *Game) Hash() GameHash {
+	return &g.Board
+}
+
+func (g *Game) Compare(c *Game) bool {
+	for i := 0; i < boardLength+1; i++ {
+		if g.Board[i] != c.Board[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsTerminal returns true if this game state is a terminal state
+func (g *Game) IsTerminal() bool {
+	return BoardCompletedStorage[g.OverallBoard&0x1FF] || BoardCompletedStorage[(g.OverallBoard>>9)&0x1FF] || ((g.OverallBoard>>18)|(g.OverallBoard>>9)|g.OverallBoard)&0x1FF == 0x1FF
+}
+
+func (g *Game) WinningPlayer() Player {
+	if CheckCompleted(g.OverallBoard & 0x1FF) {
+		return Player1
+
+	} else if CheckCompleted((g.OverallBoard >> 9) & 0x1FF) {
+		return Player2
+
+	} else if ((g.OverallBoard>>18)|(g.OverallBoard>>9)|g.OverallBoard)&0x1FF == 0x1FF {
+		p1 := bitCount(g.OverallBoard & 0x1FF)
+		p2 := bitCount((g.OverallBoard >> 9) & 0x1FF)
+		if p1 > p2 {
+			return Player1
+		} else if p2 > p1 {
+			return Player2
+		}
+	}
+	return Draw
+}
+
+func NewGame() *Game {
+	g := &Game{
+		Board: [boardLength + 1]uint32{
+			0,
+			0,
+			0,
+			0,
+			0,
+			0,
+			0,
+			0,
+			0,
+			0x11,
+		},
+		OverallBoard:    0x0,
+		HeuristicScores: DefaultHeuristic(),
+	}
+	return g
+}
+
+func (g *Game) Copy() Game {
+	return Game{
+		Board: [boardLength + 1]uint32{
+			g.Board[0],
+			g.Board[1],
+			g.Board[2],
+			g.Board[3],
+			g.Board[4],
+			g.Board[5],
+			g.Board[6],
+			g.Board[7],
+			g.Board[8],
+			g.Board[9],
+		},
+		OverallBoard: g.OverallBoard,
+	}
+}
+
+func (g *Game) Rotate(rotateBy uint32) {
+	g.Board[rotateBy%8], g.Board[(rotateBy+1)%8], g.Board[(rotateBy+2)%8], g.Board[(rotateBy+3)%8], g.Board[(rotateBy+4)%8], g.Board[(rotateBy+5)%8], g.Board[(rotateBy+6)%8], g.Board[(rotateBy+7)%8] = g.Board[(rotateBy+6)%8], g.Board[(rotateBy+7)%8], g.Board[rotateBy%8], g.Board[(rotateBy+1)%8], g.Board[(rotateBy+2)%8], g.Board[(rotateBy+3)%8], g.Board[(rotateBy+4)%8], g.Board[(rotateBy+5)%8]
+	for i := 0; i < boardLength; i++ {
+		g.Board[i] = g.Board[i]&0xe0020100 | rotl(g.Board[i], rotateBy) | (rotl(g.Board[i]>>9, rotateBy) << 9)
+	}
+	g.OverallBoard = g.OverallBoard&0xe4020100 | rotl(g.OverallBoard, rotateBy) | (rotl(g.OverallBoard>>9, rotateBy) << 9) | (rotl(g.OverallBoard>>18, rotateBy) << 18)
+
+	// Change current board
+	currentBoard := byte(g.Board[PlayerBoardIndex] >> 1)
+	if currentBoard != 8 && currentBoard < 9 {
+		g.Board[PlayerBoardIndex] = (((uint32(currentBoard) + rotateBy) % 8) << 1) | (g.Board[PlayerBoardIndex] & 0x1)
+	}
+}
+
+func (g *Game) Invert() {
+	for i := 0; i < boardLength; i++ {
+		g.Board[i] = (g.Board[i]&0x1FF)<<9 | (g.Board[i]>>9)&0x1FF
+	}
+	g.OverallBoard = g.OverallBoard&0xFFFC0000 | (g.OverallBoard&0x1FF)<<9 | (g.OverallBoard>>9)&0x1FF
+
+	// Change player
+	g.Board[PlayerBoardIndex] = (g.Board[PlayerBoardIndex] & 0x1FE) | ((g.Board[PlayerBoardIndex] & 0x1) ^ 0x1)
+}
+
+func (g *Game) UnMakeMove(lastPos byte, lastBoard byte, prevBoard byte) {
+	// Unset move
+	if Player(g.Board[PlayerBoardIndex]&0x1) == Player2 {
+		g.Board[lastBoard] &^= 1 << lastPos
+
+	} else {
+		g.Board[lastBoard] &^= 1 << (lastPos + 9)
+	}
+
+	// Reset win
+	g.OverallBoard &^= 0x1<<lastBoard | 0x1<<(lastBoard+9) | 0x1<<(lastBoard+18)
+	g.Board[PlayerBoardIndex] = uint32(prevBoard)<<1 | ((g.Board[PlayerBoardIndex] & 0x1) ^ 0x1)
+}
+
+func (g *Game) MakeMove(boardIndex byte, pos byte) {
+	p := byte(g.Board[PlayerBoardIndex] & 0x1)
+	g.Board[boardIndex] |= 1 << (pos + 9*p)
+	if BoardCompletedStorage[(g.Board[boardIndex]>>(9*p))&0x1FF] {
+		g.OverallBoard |= 1 << (boardIndex + (9 * p))
+	}
+
+	// Draw
+	if (g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF == 0x1FF {
+		g.OverallBoard |= 1 << (boardIndex + 18)
+	}
+
+	// Only switch board is a space is available
+	g.Board[PlayerBoardIndex] = uint32(pos)<<1 | ((g.Board[PlayerBoardIndex] & 0x1) ^ 0x1)
+
+	// If board is finished
+	if g.OverallBoard&(0x1<<pos|0x1<<(pos+9)|0x1<<(pos+18)) != 0 {
+		g.Board[PlayerBoardIndex] |= 0x100
+	}
+}
+
+// ValidMove checks that the square is empty and in the board to play, after a move to a finished board any open board can be played
+func (g *Game) ValidMove(boardIndex byte, pos byte) bool {
+	currentBoard := byte(g.Board[PlayerBoardIndex] >> 1)
+	return boardIndex < boardLength && pos < boardLength && !g.IsBoardFinished(boardIndex) && (currentBoard >= boardLength || boardIndex == currentBoard) && g.Board[boardIndex]&(1<<pos) == 0 && g.Board[boardIndex]&(1<<(pos+9)) == 0
+}
+
+func (g *Game) IsBoardFinished(pos byte) bool {
+	return g.OverallBoard&(0x1<<pos|0x1<<(pos+9)|0x1<<(pos+18)) != 0
+}
+
+var MovesStorage = [512][]byte{}
+var MovesLengthStorage = [512]byte{}
+
+// Len and GetMoves keep no state in package variables, games can be searched with minimax in parallel
+func (g *Game) Len() byte {
+	boardIndex := byte(g.Board[PlayerBoardIndex] >> 1)
+	if boardIndex < 9 {
+		return MovesLengthStorage[(g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF]
+	}
+
+	var moves byte = 0
+	jointOverallBoard := (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+	for _, i := range MovesStorage[jointOverallBoard] {
+		// Check if the board is open
+		moves += MovesLengthStorage[(g.Board[i]|(g.Board[i]>>9))&0x1FF]
+	}
+
+	return moves
+}
+
+// MakeMoveRandUntilTerminal plays random moves with the sequence shared by the package until the game is over
+func (g *Game) MakeMoveRandUntilTerminal() {
+	g.RandomPlayout(&x)
+}
+
+// RandomPlayout plays random moves drawn from rng until the game is over, every step plays one legal move
+// of the player to move
+func (g *Game) RandomPlayout(rng *Xorshift) {
+	//for !g.IsTerminal() {
+	jointOverallBoard := (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+	for !(BoardCompletedStorage[g.OverallBoard&0x1FF] || BoardCompletedStorage[(g.OverallBoard>>9)&0x1FF] || jointOverallBoard == 0x1FF) {
+		boardIndex := byte(g.Board[PlayerBoardIndex] >> 1)
+		// moveIndex = byte(RandSource.Intn(int(g.Len())))
+		moveIndex := rng.Next(g.Len())
+
+		if boardIndex < 9 {
+			g.MakeMove(boardIndex, MovesStorage[(g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF][moveIndex])
+			jointOverallBoard = (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+			continue
+		}
+
+		var moves byte = 0
+		for _, i := range MovesStorage[jointOverallBoard] {
+			board := (g.Board[i] | (g.Board[i] >> 9)) & 0x1FF
+			currentMoves := MovesLengthStorage[board]
+
+			if moves+currentMoves > moveIndex {
+				g.MakeMove(i, MovesStorage[board][moveIndex-moves])
+				jointOverallBoard = (g.OverallBoard>>9 | g.OverallBoard | g.OverallBoard>>18) & 0x1FF
+				// One move per turn, the next player picks from the new position
+				break
+			}
+			moves += currentMoves
+		}
+	}
+}
+
+// boardStates is the amount of ways a local board can be filled
+const boardStates = 19683
+
+// ternary maps the 9 bit mask of one player to its base 3 digits
+var ternary = [512]uint16{}
+
+func init() {
+	for mask := 0; mask < 512; mask++ {
+		var digit uint16 = 1
+		for i := 0; i < boardLength; i++ {
+			if mask&(1<<i) > 0 {
+				ternary[mask] += digit
+			}
+			digit *= 3
+		}
+	}
+}
+
+// boardStateIndex is the base 3 encoding of a local board seen from player, 1 for own and 2 for enemy marks
+func boardStateIndex(player Player, board uint32) uint16 {
+	if player == Player1 {
+		return ternary[board&0x1FF] + 2*ternary[(board>>9)&0x1FF]
+	}
+	return ternary[(board>>9)&0x1FF] + 2*ternary[board&0x1FF]
+}
 
 type HeuristicScores struct {
-	BoardRating [9]float64
-	PosRating   [9]float64
+	// BoardRating and PosRating are derived from the corner, side and middle ratings by Normalise
+	BoardRating [9]float64 `json:"-"`
+	PosRating   [9]float64 `json:"-"`
 
 	BoardCornerRating                        float64
 	BoardSideRating                          float64
@@ -71,6 +342,9 @@ type HeuristicScores struct {
 	DrawBoardScorePlayerDiscountRating       float64
 	LocalBoardWinPlayedMovesDiscountRating   float64
 	OverallBoardWinPlayedMovesDiscountRating float64
+
+	// boardScores caches the local board rating of every board state for player 1, built by Normalise
+	boardScores []float64
 }
 
 func DefaultHeuristic() *HeuristicScores {
@@ -98,10 +372,24 @@ func DefaultHeuristic() *HeuristicScores {
 		OverallBoardWinPlayedMovesDiscountRating: 1.32,
 	}
 
+	h.Normalise()
+	return &h
+}
+
+// Normalise rebuilds the board and position ratings from the corner, side and middle ratings
+// and caches the rating of every local board, it has to be called after changing a rating
+func (h *HeuristicScores) Normalise() {
 	h.BoardRating = [9]float64{h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardMiddleRating}
 	h.PosRating = [9]float64{h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosMiddleRating}
 
-	return &h
+	h.boardScores = make([]float64, boardStates)
+	for state := 0; state < boardStates; state++ {
+		var board uint32 = 0
+		for i, digits := 0, state; i < boardLength; i, digits = i+1, digits/3 {
+			board = popBoardHelper(board, i, digits%3-1)
+		}
+		h.boardScores[state] = h.heuristicBoard(Player1, board, false)
+	}
 }
 
 func getOffset(player Player) (int, int) {
@@ -112,16 +400,13 @@ func getOffset(player Player) (int, int) {
 }
 
 func (g *Game) HeuristicBoard(player Player, board uint32, isOverallBoard bool) float64 {
-	if !isOverallBoard {
-		if player == Player1 {
-			if score, ok := BoardHeuristicCacheP1[board&0x3FFFF]; ok {
-				return score
-			}
-		} else if score, ok := BoardHeuristicCacheP2[board&0x3FFFF]; ok {
-			return score
-		}
+	if !isOverallBoard && g.HeuristicScores.boardScores != nil {
+		return g.HeuristicScores.boardScores[boardStateIndex(player, board)]
 	}
+	return g.HeuristicScores.heuristicBoard(player, board, isOverallBoard)
+}
 
+func (h *HeuristicScores) heuristicBoard(player Player, board uint32, isOverallBoard bool) float64 {
 	offset, enemyOffset := getOffset(player)
 	var score float64 = 0
 
@@ -135,25 +420,25 @@ func (g *Game) HeuristicBoard(player Player, board uint32, isOverallBoard bool)
 		// Give a discount on the amount of moves made in the board
 		// To incentivise a lower number of total moves
 		if !isOverallBoard {
-			score += g.HeuristicScores.WonBoardRating - float64(bitCount(playerBoard))*g.HeuristicScores.LocalBoardWinPlayedMovesDiscountRating
+			score += h.WonBoardRating - float64(bitCount(playerBoard))*h.LocalBoardWinPlayedMovesDiscountRating
 		} else {
-			score += g.HeuristicScores.WonBoardRating - float64(bitCount(playerBoard))*g.HeuristicScores.OverallBoardWinPlayedMovesDiscountRating
+			score += h.WonBoardRating - float64(bitCount(playerBoard))*h.OverallBoardWinPlayedMovesDiscountRating
 		}
 
 		// The board is a draw
 	} else if jointBoard == 0x1FF && !CheckCompleted(enemyBoard) {
 		// Give a reward for the amount of wasted enemy moves (or won moves
 		if !isOverallBoard {
-			score += float64(bitCount(enemyBoard)) * g.HeuristicScores.DrawBoardScoreEnemyDiscountRating
+			score += float64(bitCount(enemyBoard)) * h.DrawBoardScoreEnemyDiscountRating
 		} else {
-			score += float64(bitCount(playerBoard)) * g.HeuristicScores.DrawBoardScorePlayerDiscountRating
+			score += float64(bitCount(playerBoard)) * h.DrawBoardScorePlayerDiscountRating
 		}
 
 	} else {
 		// Calculate pos for items
 		for i := 0; i < boardLength; i++ {
 			if playerBoard&(0x1<<i) > 0 {
-				score += g.HeuristicScores.PosRating[i]
+				score += h.PosRating[i]
 			}
 		}
 
@@ -161,18 +446,18 @@ func (g *Game) HeuristicBoard(player Player, board uint32, isOverallBoard bool)
 		if !CheckCompleted(enemyBoard) {
 			// Check 2 joint items
 			if checkCloseWinningSequence(playerBoard, jointBoard) > 0 {
-				score += g.HeuristicScores.TwoInARowAdvantageRating
+				score += h.TwoInARowAdvantageRating
 			}
 
 			// Check 2 joint items
 			if checkCloseWinningSequence(enemyBoard, jointBoard) > 0 {
-				score -= g.HeuristicScores.EnemyTwoInARowLossRating
+				score -= h.EnemyTwoInARowLossRating
 			}
 
 			// The enemy has won a square
 		} else {
 			// Give a reward for enemy moves
-			score -= g.HeuristicScores.EnemyWonBoardLossRating - float64(bitCount(enemyBoard))*g.HeuristicScores.EnemyWonBoardDiscountRating
+			score -= h.EnemyWonBoardLossRating - float64(bitCount(enemyBoard))*h.EnemyWonBoardDiscountRating
 		}
 	}
 	return score
@@ -219,6 +504,48 @@ func (g *Game) HeuristicPlayer(player Player) float64 {
 	return score
 }
 
+// HeuristicBreakdown is HeuristicPlayer split into its terms, the terms add up to Total.
+// A finished game is only scored by Terminal
+type HeuristicBreakdown struct {
+	Total       float64    `json:"total"`
+	Terminal    float64    `json:"terminal"`
+	GlobalState float64    `json:"globalState"`
+	Boards      [9]float64 `json:"boards"`
+	Overall     float64    `json:"overall"`
+}
+
+// Breakdown computes HeuristicPlayer term by term, it is slower and meant for analysis
+func (g *Game) Breakdown(player Player) HeuristicBreakdown {
+	b := HeuristicBreakdown{}
+	var playerOffset, enemyOffset = getOffset(player)
+	playerBoard := (g.OverallBoard >> playerOffset) & 0x1FF
+	enemyBoard := (g.OverallBoard >> enemyOffset) & 0x1FF
+	jointBoard := (g.OverallBoard>>18)&0x1FF | playerBoard | enemyBoard
+
+	if CheckCompleted(playerBoard) || CheckCompleted(enemyBoard) || jointBoard == 0x1FF {
+		b.Terminal = g.HeuristicPlayer(player)
+		b.Total = b.Terminal
+		return b
+	}
+
+	if byte(g.Board[PlayerBoardIndex]>>1) == GlobalBoard {
+		b.GlobalState = g.HeuristicScores.GlobalStateRating
+		if byte(g.Board[PlayerBoardIndex]&0x1) != byte(player) {
+			b.GlobalState = -b.GlobalState
+		}
+	}
+	b.Total = b.GlobalState
+
+	for i := 0; i < boardLength; i++ {
+		b.Boards[i] = g.HeuristicBoard(player, g.Board[i], false) * g.HeuristicScores.BoardRating[i]
+		b.Total += b.Boards[i]
+	}
+
+	b.Overall = g.HeuristicBoard(player, g.OverallBoard, true) * g.HeuristicScores.OverallBoardMultiplierRating
+	b.Total += b.Overall
+	return b
+}
+
 func (g *Game) MovesMade() uint32 {
 	// Count how far the game has progressed
 	var movesPlayed uint32 = 0
@@ -237,7 +564,16 @@ func popBoardHelper(b uint32, pos int, player int) uint32 {
 	return b
 }
 
+// PopulateBoards shuffles the move order again, the moves are populated on start
 func (g *Game) PopulateBoards() {
+	populateMoves()
+}
+
+func populateMoves() {
+	// Every shuffle starts from the same order, the seed alone decides the result
+	order := make([]byte, len(moveOrder))
+	copy(order, moveOrder)
+
 	var board uint32 = 0
 	for i0 := 0; i0 < 3; i0++ {
 		for i1 := 0; i1 < 3; i1++ {
@@ -259,33 +595,17 @@ func (g *Game) PopulateBoards() {
 										board = popBoardHelper(board, 7, i7)
 										board = popBoardHelper(board, 8, i8)
 
-										// Check if board is valid
-										isValid := false
-										xBoard, oBoard, jointBoard := board&0x1FF, (board>>9)&0x1FF, uint16((board&0x1FF)|((board>>9)&0x1FF))
-
-										// Board can be valid only if either
-										xWin, oWin := CheckCompleted(xBoard), CheckCompleted(oBoard)
-										if xWin && !oWin {
-											isValid = true
-										} else if !xWin && oWin {
-											isValid = true
-										} else if !xWin && !oWin {
-											isValid = true
-										}
-
-										if isValid {
-											BoardHeuristicCacheP1[board] = g.HeuristicBoard(Player1, board, false)
-											BoardHeuristicCacheP2[board] = g.HeuristicBoard(Player2, board, false)
-										}
-
+										jointBoard := uint16((board & 0x1FF) | ((board >> 9) & 0x1FF))
 										MovesStorage[jointBoard] = []byte{}
-										for _, move := range moveOrder {
+										RandSource.Shuffle(len(order), func(i, j int) {
+											order[i], order[j] = order[j], order[i]
+										})
+										for _, move := range order {
 											if jointBoard&(0x1<<move) == 0 {
 												MovesStorage[jointBoard] = append(MovesStorage[jointBoard], move)
 											}
 										}
 										MovesLengthStorage[jointBoard] = byte(len(MovesStorage[jointBoard]))
-										BoardCompletedStorage[jointBoard] = CheckCompletedHelper(uint32(jointBoard))
 									}
 								}
 							}
@@ -297,14 +617,184 @@ func (g *Game) PopulateBoards() {
 	}
 }
 
-var BoardCompletedStorage = [512]bool{}
+// legacyHeuristicFields were written by older tuners and are dropped when loading
+var legacyHeuristicFields = map[string]bool{
+	"BoardRatingMultiplierRating": true,
+}
 
-func CheckCompleted(test uint32) bool {
-	return BoardCompletedStorage[test]
+// derivedHeuristicFields are the arrays built by Normalise, they are only used for
+// ratings that are missing from the file. The indexes are the corner, side and middle
+var derivedHeuristicFields = map[string][3]string{
+	"BoardRating": {"BoardCornerRating", "BoardSideRating", "BoardMiddleRating"},
+	"PosRating":   {"PosCornerRating", "PosSideRating", "PosMiddleRating"},
 }
 
-func CheckCompletedHelper(test uint32) bool {
-	if test&0x100 > 0 && ((test&0x111) == 0x111 || (test&0x144) == 0x144 || (test&0x188) == 0x188 || (test&0x122) == 0x122) {
+// BestHeuristic selects the weight set with the highest fitness in LoadHeuristic
+const BestHeuristic = -1
+
+// Elite is a weight set written by the tuner, Fitness is 0 when the file does not record it
+type Elite struct {
+	Fitness   int
+	Heuristic *HeuristicScores
+}
+
+// MarshalJSON writes the fitness next to the ratings so the elite files stay one flat object per line
+func (e Elite) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Fitness int
+		*HeuristicScores
+	}{e.Fitness, e.Heuristic})
+}
+
+// ParseElite decodes a weight set together with the fitness the tuner recorded for it
+func ParseElite(data []byte) (Elite, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return Elite{}, err
+	}
+
+	elite := Elite{}
+	if raw, ok := fields["Fitness"]; ok {
+		delete(fields, "Fitness")
+		if err := json.Unmarshal(raw, &elite.Fitness); err != nil {
+			return Elite{}, fmt.Errorf("Fitness: %w", err)
+		}
+	}
+
+	for name := range legacyHeuristicFields {
+		delete(fields, name)
+	}
+
+	// Older files carry the arrays as well, the scalars take precedence when both exist
+	for name, scalars := range derivedHeuristicFields {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		delete(fields, name)
+
+		var ratings [9]float64
+		if err := json.Unmarshal(raw, &ratings); err != nil {
+			return Elite{}, fmt.Errorf("%s: %w", name, err)
+		}
+		if ratings[0] != ratings[2] || ratings[0] != ratings[4] || ratings[0] != ratings[6] || ratings[1] != ratings[3] || ratings[1] != ratings[5] || ratings[1] != ratings[7] {
+			return Elite{}, fmt.Errorf("%s is not symmetric: %v", name, ratings)
+		}
+		for i, index := range []int{0, 1, 8} {
+			if _, ok := fields[scalars[i]]; !ok {
+				fields[scalars[i]], _ = json.Marshal(ratings[index])
+			}
+		}
+	}
+
+	var missing []string
+	hType := reflect.TypeOf(HeuristicScores{})
+	for i := 0; i < hType.NumField(); i++ {
+		if field := hType.Field(i); field.IsExported() && field.Tag.Get("json") != "-" {
+			if _, ok := fields[field.Name]; !ok {
+				missing = append(missing, field.Name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return Elite{}, fmt.Errorf("missing ratings: %s", strings.Join(missing, ", "))
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return Elite{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	elite.Heuristic = &HeuristicScores{}
+	if err = decoder.Decode(elite.Heuristic); err != nil {
+		return Elite{}, err
+	}
+	elite.Heuristic.Normalise()
+	return elite, nil
+}
+
+// ReadElites parses a stream of weight sets, either a single JSON object or
+// one object per line as written to the elite files by the tuner
+func ReadElites(r io.Reader) ([]Elite, error) {
+	var elites []Elite
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			return elites, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("weight set %d: %w", len(elites), err)
+		}
+
+		elite, err := ParseElite(raw)
+		if err != nil {
+			return nil, fmt.Errorf("weight set %d: %w", len(elites), err)
+		}
+		elites = append(elites, elite)
+	}
+}
+
+// LoadElites reads every weight set in a weights or elite file
+func LoadElites(path string) ([]Elite, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	elites, err := ReadElites(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return elites, nil
+}
+
+// LoadHeuristic reads the weight set at index of a weights or elite file, BestHeuristic selects
+// the highest fitness. Without recorded fitness the last set is the best, the tuner appends elites
+// as they improve
+func LoadHeuristic(path string, index int) (*HeuristicScores, error) {
+	elites, err := LoadElites(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(elites) == 0 {
+		return nil, fmt.Errorf("%s: no weight sets", path)
+	}
+
+	if index == BestHeuristic {
+		best := 0
+		for i, elite := range elites {
+			if elite.Fitness >= elites[best].Fitness {
+				best = i
+			}
+		}
+		return elites[best].Heuristic, nil
+	}
+
+	if index < 0 || index >= len(elites) {
+		return nil, fmt.Errorf("%s: weight set %d out of range, the file has %d", path, index, len(elites))
+	}
+	return elites[index].Heuristic, nil
+}
+
+var BoardCompletedStorage = [512]bool{}
+
+// The completed boards are needed before the first game is created to rate the boards of the heuristics
+func init() {
+	for board := uint32(0); board < 512; board++ {
+		BoardCompletedStorage[board] = CheckCompletedHelper(board)
+	}
+}
+
+func CheckCompleted(test uint32) bool {
+	return BoardCompletedStorage[test]
+}
+
+func CheckCompletedHelper(test uint32) bool {
+	if test&0x100 > 0 && ((test&0x111) == 0x111 || (test&0x144) == 0x144 || (test&0x188) == 0x188 || (test&0x122) == 0x122) {
 		return true
 	}
 
@@ -342,281 +832,216 @@ func rotl(x uint32, by uint32) uint32 {
 	return (x<<by | x>>(8-by)) & 0xFF
 }
 
-type Player byte
-type GameHash *[10]uint32
-
-const Player1 Player = 0
-const Player2 Player = 1
-const Draw Player = 2
-const boardLength = 9
-const GlobalBoard byte = 0xF0
-const PlayerBoardIndex = 9
+// squares holds the column and row of the boards and of the squares within a board, in the order of the game
+var squares = [9][2]int{
+	{0, 0},
+	{1, 0},
+	{2, 0},
 
-/*	corner -> middle -> side */
-var moveOrder = []byte{0, 4, 2, 6, 8, 1, 3, 5, 7}
+	{2, 1},
+	{2, 2},
 
-var x = uint64(time.Now().Unix()) /* initial seed must be nonzero, don't use a static variable for the state if multithreaded */
-func xorshift64star(n byte) byte {
-	x ^= x >> 12
-	x ^= x << 25
-	x ^= x >> 27
-	return byte((x * 2685821657736338717) % uint64(n))
+	{1, 2},
+	{0, 2},
+	{0, 1},
+	{1, 1},
 }
 
-type Game struct {
-	Board           [boardLength + 1]uint32
-	OverallBoard    uint32
-	HeuristicScores *HeuristicScores
+// Action returns the row and column the referee expects for a move. The game is played transposed,
+// which is a symmetry of the game, so the replies only have to agree with Move
+func Action(board byte, pos byte) (int, int) {
+	start := squares[board]
+	offset := squares[pos]
+	return start[0]*3 + offset[0], start[1]*3 + offset[1]
 }
 
-func (g *Game) GetMoves(executeMove func(byte, byte) bool) {
-	currentBoard := byte(g.Board[PlayerBoardIndex] >> 1)
-	if currentBoard != GlobalBoard {
-		for _, move := range MovesStorage[((g.Board[currentBoard] | (g.Board[currentBoard] >> 9)) & 0x1FF)] {
-			if executeMove(currentBoard, move) {
-				return
-			}
+// Move returns the board and square of a row and column sent by the referee
+func Move(row int, col int) (byte, byte) {
+	for board, start := range squares {
+		if row/3 != start[0] || col/3 != start[1] {
+			continue
 		}
-	} else {
-		for _, i := range moveOrder {
-			// Check if the board is open
-			if g.OverallBoard&((0x1<<i)|(0x1<<(i+9))|(0x1<<(i+18))) == 0 {
-				for _, move := range MovesStorage[((g.Board[i] | (g.Board[i] >> 9)) & 0x1FF)] {
-					if executeMove(i, move) {
-						return
-					}
-				}
+		for pos, offset := range squares {
+			if row%3 == offset[0] && col%3 == offset[1] {
+				return byte(board), byte(pos)
 			}
 		}
 	}
+	return 0, 0
 }
 
-func (g *Game) Hash() GameHash {
-	return &g.Board
+// NewGame is the start of a CodinGame game, unlike Game.NewGame the first move may be played on any board
+func protocolNewGame() *Game {
+	game := NewGame()
+	game.Board[PlayerBoardIndex] |= 0x100
+	return game
 }
 
-func (g *Game) Compare(c *Game) bool {
-	for i := 0; i < boardLength+1; i++ {
-		if g.Board[i] != c.Board[i] {
-			return false
-		}
-	}
-
-	return true
-}
+// Turn is what the referee sends before every move
+type Turn struct {
+	// OpponentRow and OpponentCol are the last move of the opponent, -1 before the first move of the game
+	OpponentRow int
+	OpponentCol int
 
-// IsTerminal returns true if this game state is a terminal state
-func (g *Game) IsTerminal() bool {
-	return CheckCompleted(g.OverallBoard&0x1FF) || CheckCompleted((g.OverallBoard>>9)&0x1FF) || ((g.OverallBoard>>18)|(g.OverallBoard>>9)|g.OverallBoard)&0x1FF == 0x1FF
+	// Actions are the row and column of every valid move
+	Actions [][2]int
 }
 
-func (g *Game) WinningPlayer() Player {
-	if CheckCompleted(g.OverallBoard & 0x1FF) {
-		return Player1
-
-	} else if CheckCompleted((g.OverallBoard >> 9) & 0x1FF) {
-		return Player2
-
-	} else if ((g.OverallBoard>>18)|(g.OverallBoard>>9)|g.OverallBoard)&0x1FF == 0x1FF {
-		p1 := bitCount(g.OverallBoard & 0x1FF)
-		p2 := bitCount((g.OverallBoard >> 9) & 0x1FF)
-		if p1 > p2 {
-			return Player1
-		} else if p2 > p1 {
-			return Player2
-		}
+// ReadTurn reads the last move of the opponent, the number of valid actions and the actions
+func ReadTurn(r io.Reader) (Turn, error) {
+	turn := Turn{}
+	var count int
+	if _, err := fmt.Fscan(r, &turn.OpponentRow, &turn.OpponentCol, &count); err != nil {
+		return turn, err
 	}
-	return Draw
-}
-
-func NewGame() *Game {
-	g := &Game{
-		Board: [boardLength + 1]uint32{
-			0,
-			0,
-			0,
-			0,
-			0,
-			0,
-			0,
-			0,
-			0,
-			0x11,
-		},
-		OverallBoard:    0x0,
-		HeuristicScores: DefaultHeuristic(),
+	turn.Actions = make([][2]int, count)
+	for i := range turn.Actions {
+		if _, err := fmt.Fscan(r, &turn.Actions[i][0], &turn.Actions[i][1]); err != nil {
+			return turn, err
+		}
 	}
-	g.PopulateBoards()
-	return g
+	return turn, nil
 }
 
-func (g *Game) Copy() Game {
-	return Game{
-		Board: [boardLength + 1]uint32{
-			g.Board[0],
-			g.Board[1],
-			g.Board[2],
-			g.Board[3],
-			g.Board[4],
-			g.Board[5],
-			g.Board[6],
-			g.Board[7],
-			g.Board[8],
-			g.Board[9],
-		},
-		OverallBoard: g.OverallBoard,
+// Allows reports if the move is one of the valid actions
+func (t Turn) Allows(board byte, pos byte) bool {
+	row, col := Action(board, pos)
+	for _, action := range t.Actions {
+		if action == [2]int{row, col} {
+			return true
+		}
 	}
+	return false
 }
 
-func (g *Game) Rotate(rotateBy uint32) {
-	g.Board[rotateBy%8], g.Board[(rotateBy+1)%8], g.Board[(rotateBy+2)%8], g.Board[(rotateBy+3)%8], g.Board[(rotateBy+4)%8], g.Board[(rotateBy+5)%8], g.Board[(rotateBy+6)%8], g.Board[(rotateBy+7)%8] = g.Board[(rotateBy+6)%8], g.Board[(rotateBy+7)%8], g.Board[rotateBy%8], g.Board[(rotateBy+1)%8], g.Board[(rotateBy+2)%8], g.Board[(rotateBy+3)%8], g.Board[(rotateBy+4)%8], g.Board[(rotateBy+5)%8]
-	for i := 0; i < boardLength; i++ {
-		g.Board[i] = g.Board[i]&0xe0020100 | rotl(g.Board[i], rotateBy) | (rotl(g.Board[i]>>9, rotateBy) << 9)
-	}
-	g.OverallBoard = g.OverallBoard&0xe4020100 | rotl(g.OverallBoard, rotateBy) | (rotl(g.OverallBoard>>9, rotateBy) << 9) | (rotl(g.OverallBoard>>18, rotateBy) << 18)
-
-	// Change current board
-	currentBoard := byte(g.Board[PlayerBoardIndex] >> 1)
-	if currentBoard != 8 && currentBoard != GlobalBoard {
-		g.Board[PlayerBoardIndex] = (((uint32(currentBoard) + rotateBy) % 8) << 1) | (g.Board[PlayerBoardIndex] & 0x1)
+// Mismatches returns the valid actions the game does not allow and the moves of the game the referee does not allow,
+// both as row and column. They are empty unless the game lost track of the referee
+func (t Turn) Mismatches(game *Game) (refereeOnly [][2]int, gameOnly [][2]int) {
+	for _, action := range t.Actions {
+		if board, pos := Move(action[0], action[1]); !game.ValidMove(board, pos) {
+			refereeOnly = append(refereeOnly, action)
+		}
 	}
+	game.GetMoves(func(board byte, pos byte) bool {
+		if !t.Allows(board, pos) {
+			row, col := Action(board, pos)
+			gameOnly = append(gameOnly, [2]int{row, col})
+		}
+		return false
+	})
+	return refereeOnly, gameOnly
 }
 
-func (g *Game) Invert() {
-	for i := 0; i < boardLength; i++ {
-		g.Board[i] = (g.Board[i]&0x1FF)<<9 | (g.Board[i]>>9)&0x1FF
+// Legal returns the move if the referee allows it, otherwise the first valid action and false
+func (t Turn) Legal(board byte, pos byte) (byte, byte, bool) {
+	if t.Allows(board, pos) || len(t.Actions) == 0 {
+		return board, pos, true
 	}
-	g.OverallBoard = g.OverallBoard&0xFFFC0000 | (g.OverallBoard&0x1FF)<<9 | (g.OverallBoard>>9)&0x1FF
-
-	// Change player
-	g.Board[PlayerBoardIndex] = (g.Board[PlayerBoardIndex] & 0x1FE) | ((g.Board[PlayerBoardIndex] & 0x1) ^ 0x1)
+	board, pos = Move(t.Actions[0][0], t.Actions[0][1])
+	return board, pos, false
 }
 
-func (g *Game) UnMakeMove(lastPos byte, lastBoard byte, prevBoard byte) {
-	// Unset move
-	if Player(g.Board[PlayerBoardIndex]&0x1) == Player2 {
-		g.Board[lastBoard] &^= 1 << lastPos
-
-	} else {
-		g.Board[lastBoard] &^= 1 << (lastPos + 9)
-	}
-
-	// Reset win
-	g.OverallBoard &^= 0x1<<lastBoard | 0x1<<(lastBoard+9) | 0x1<<(lastBoard+18)
-	g.Board[PlayerBoardIndex] = uint32(prevBoard)<<1 | ((g.Board[PlayerBoardIndex] & 0x1) ^ 0x1)
+// Budget ends a search after MaxTime, after MaxNodes searched positions unless it is 0, or once Stop is called.
+// Iterative searches start no new depth after SoftTime unless it is 0. Only Stop and PonderHit may be called
+// while the search runs
+type Budget struct {
+	Start     time.Time
+	MaxTime   time.Duration
+	SoftTime  time.Duration
+	MaxNodes  uint64
+	Nodes     uint64
+	stopped   atomic.Bool
+	pondering atomic.Bool
+	outer     *Budget
 }
 
-func (g *Game) MakeMove(boardIndex byte, pos byte) {
-	if Player(g.Board[PlayerBoardIndex]&0x1) == Player1 {
-		g.Board[boardIndex] |= 1 << pos
-
-		// Player 1 - WIN
-		if CheckCompleted(g.Board[boardIndex] & 0x1FF) {
-			g.OverallBoard |= 1 << boardIndex
-		}
-
-	} else {
-		g.Board[boardIndex] |= 1 << (pos + 9)
-
-		// Player 2 - WIN
-		if CheckCompleted((g.Board[boardIndex] >> 9) & 0x1FF) {
-			g.OverallBoard |= 1 << (boardIndex + 9)
-		}
-	}
-
-	// Draw
-	if (g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF == 0x1FF {
-		g.OverallBoard |= 1 << (boardIndex + 18)
-	}
-
-	// Only switch board is a space is available
-	if g.IsBoardFinished(pos) {
-		g.Board[PlayerBoardIndex] = uint32(GlobalBoard)<<1 | ((g.Board[PlayerBoardIndex] & 0x1) ^ 0x1)
-	} else {
-		g.Board[PlayerBoardIndex] = uint32(pos)<<1 | ((g.Board[PlayerBoardIndex] & 0x1) ^ 0x1)
-	}
+// NewBudget starts a budget of maxTime now
+func NewBudget(maxTime time.Duration) *Budget {
+	return &Budget{Start: time.Now(), MaxTime: maxTime}
 }
 
-func (g *Game) ValidMove(boardIndex byte, pos byte) bool {
-	currentBoard := byte(g.Board[PlayerBoardIndex] >> 1)
-	return !g.IsBoardFinished(boardIndex) && (currentBoard == GlobalBoard || boardIndex == currentBoard) && g.Board[boardIndex]&(1<<pos) == 0 && g.Board[boardIndex]&(1<<(pos+9)) == 0
+// NewPonderBudget starts a budget for searching during the turn of the opponent, it has no limits until PonderHit
+func NewPonderBudget() *Budget {
+	b := &Budget{Start: time.Now()}
+	b.pondering.Store(true)
+	return b
 }
 
-func (g *Game) IsBoardFinished(pos byte) bool {
-	return g.OverallBoard&(0x1<<pos|0x1<<(pos+9)|0x1<<(pos+18)) > 0
+// Stop ends the search from another goroutine
+func (b *Budget) Stop() {
+	b.stopped.Store(true)
 }
 
-var MovesStorage = [512][]byte{}
-var MovesLengthStorage = [512]byte{}
-
-func (g *Game) Len() byte {
-	boardIndex := g.Board[PlayerBoardIndex] >> 1
-	if boardIndex < 9 {
-		return MovesLengthStorage[(g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF]
+// PonderHit ends the pondering from another goroutine, the search continues on the times and nodes of limits.
+// The times count from the start of limits and the nodes searched while pondering count towards MaxNodes
+func (b *Budget) PonderHit(limits *Budget) {
+	if !b.pondering.Load() {
+		return
 	}
-
-	var moves byte = 0
-	for _, i := range moveOrder {
-		// Check if the board is open
-		if g.OverallBoard&((0x1<<i)|(0x1<<(i+9))|(0x1<<(i+18))) == 0 {
-			moves += MovesLengthStorage[(g.Board[i]|(g.Board[i]>>9))&0x1FF]
-		}
+	// The limits are only read by the search once it sees the end of the pondering, Start is read by reports
+	// during the pondering so the times are moved instead
+	pondered := limits.Start.Sub(b.Start)
+	b.MaxTime = extend(limits.MaxTime, pondered)
+	if limits.SoftTime > 0 {
+		b.SoftTime = extend(limits.SoftTime, pondered)
 	}
-
-	return moves
+	b.MaxNodes = limits.MaxNodes
+	b.pondering.Store(false)
 }
 
-func (g *Game) MakeMoveRandUntilTerminal() {
-	for !g.IsTerminal() {
-		boardIndex := byte(g.Board[PlayerBoardIndex] >> 1)
-		// moveIndex := byte(randSource.Intn(int(g.Len())))
-		moveIndex := xorshift64star(g.Len())
-
-		if boardIndex < 9 {
-			g.MakeMove(boardIndex, MovesStorage[(g.Board[boardIndex]|(g.Board[boardIndex]>>9))&0x1FF][moveIndex])
-			continue
-		}
-
-		var moves byte = 0
-		for _, i := range moveOrder {
-			// Check if the board is open
-			if g.OverallBoard&((0x1<<i)|(0x1<<(i+9))|(0x1<<(i+18))) == 0 {
-				currentMoves := MovesLengthStorage[(g.Board[i]|(g.Board[i]>>9))&0x1FF]
-
-				if moves+currentMoves > moveIndex {
-					relaiveMoveIndex := moveIndex - moves
-					g.MakeMove(i, MovesStorage[(g.Board[i]|(g.Board[i]>>9))&0x1FF][relaiveMoveIndex])
-					continue
-				}
-				moves += currentMoves
-			}
-		}
+// extend adds the time before a limit, a limit beyond the longest duration has no limit
+func extend(limit time.Duration, before time.Duration) time.Duration {
+	if limit > math.MaxInt64-before {
+		return math.MaxInt64
 	}
+	return limit + before
 }
 
-type Storage struct {
-	nodeStore map[[10]uint32]*Node
+// Pondering reports if the search runs during the turn of the opponent
+func (b *Budget) Pondering() bool {
+	return b.pondering.Load()
 }
 
-func (storage *Storage) Count() int {
-	return len(storage.nodeStore)
-}
-
-func (storage *Storage) Get(hash GameHash) (*Node, bool) {
-	node, exists := storage.nodeStore[*hash]
-	return node, exists
+func (b *Budget) Exhausted() bool {
+	if b.outer != nil && b.outer.Exhausted() {
+		return true
+	}
+	if b.pondering.Load() {
+		return b.stopped.Load()
+	}
+	return b.stopped.Load() || (b.MaxNodes > 0 && b.Nodes >= b.MaxNodes) || time.Since(b.Start) > b.MaxTime
 }
 
-func (storage *Storage) Set(hash GameHash, node *Node) {
-	storage.nodeStore[*hash] = node
+// Deepen reports if an iterative search may start the next depth
+func (b *Budget) Deepen() bool {
+	if b.pondering.Load() {
+		return !b.stopped.Load()
+	}
+	return !b.Exhausted() && (b.SoftTime == 0 || time.Since(b.Start) < b.SoftTime)
 }
 
-func (storage *Storage) Reset() {
-	storage.nodeStore = make(map[[10]uint32]*Node, 150000)
+// Iteration is a finished depth of an iterative deepening search, the score is for the player to move
+type Iteration struct {
+	Depth byte
+	Score float64
+	Board byte
+	Move  byte
 }
 
-func NewStorage() Storage {
-	return Storage{}
+// PrincipalVariation follows the best moves stored for the positions after state, at most length moves.
+// Only positions stored in the orientation they are reached are followed
+func PrincipalVariation(table *Storage, state *Game, length int) (boards []byte, moves []byte) {
+	s := state.Copy()
+	for len(moves) < length && !s.IsTerminal() {
+		n, exists := table.Get(s.Hash())
+		if !exists || !s.ValidMove(n.bestBoard, n.bestMove) {
+			break
+		}
+		boards = append(boards, n.bestBoard)
+		moves = append(moves, n.bestMove)
+		s.MakeMove(n.bestBoard, n.bestMove)
+	}
+	return boards, moves
 }
 
 var TranspositionTable = NewStorage()
@@ -638,7 +1063,7 @@ type Node struct {
 	flag       Flag
 }
 
-func NewNode(state *Game) (*Node, bool) {
+func NewNode(table *Storage, state *Game) (*Node, bool) {
 	// Rotate and invert board to check if it already exists in cache
 	var oldNode *Node = nil
 	var exists bool = false
@@ -648,7 +1073,7 @@ func NewNode(state *Game) (*Node, bool) {
 			for r := 0; r < 4; r++ {
 				// Check if the board exists in the cache
 				if !cacheExists && !exists {
-					if oldNode, exists = TranspositionTable.Get(state.Hash()); exists {
+					if oldNode, exists = table.Get(state.Hash()); exists {
 						cacheExists = true
 					}
 				}
@@ -661,11 +1086,19 @@ func NewNode(state *Game) (*Node, bool) {
 	return oldNode, cacheExists
 }
 
-const inf float64 = 10000
+const inf float64 = 100000
+
+// Search is an alpha-beta search storing its bounds in table, a nil table searches without caching.
+// Every searched position counts as a node of the budget
+func Search(table *Storage, state *Game, alpha float64, beta float64, depth byte, maxPlayer Player, budget *Budget) (float64, byte, byte) {
+	budget.Nodes++
 
-func Search(state *Game, alpha float64, beta float64, depth byte, maxPlayer Player, start *time.Time, maxDuration *time.Duration) (float64, byte, byte) {
 	// Restore the values from the last node
-	n, cached := NewNode(state)
+	var n *Node
+	var cached bool
+	if table != nil {
+		n, cached = NewNode(table, state)
+	}
 	if cached && n.depth >= depth {
 		if n.flag == EXACT {
 			return n.lowerBound, n.bestMove, n.bestBoard
@@ -684,7 +1117,7 @@ func Search(state *Game, alpha float64, beta float64, depth byte, maxPlayer Play
 	var currentBestMove byte = 0
 	var currentBestBoard byte = 0
 	var prevBoard = byte(state.Board[PlayerBoardIndex] >> 1)
-	if depth == 0 || state.IsTerminal() || time.Since(*start) > *maxDuration {
+	if depth == 0 || state.IsTerminal() || budget.Exhausted() {
 		return state.HeuristicPlayer(maxPlayer), 0, 0
 
 		// This is a max node
@@ -693,7 +1126,7 @@ func Search(state *Game, alpha float64, beta float64, depth byte, maxPlayer Play
 		a := alpha
 		state.GetMoves(func(boardIndex byte, move byte) bool {
 			state.MakeMove(boardIndex, move)
-			searchValue, _, _ := Search(state, a, beta, depth-1, maxPlayer, start, maxDuration)
+			searchValue, _, _ := Search(table, state, a, beta, depth-1, maxPlayer, budget)
 			state.UnMakeMove(move, boardIndex, prevBoard)
 
 			if searchValue >= value {
@@ -710,7 +1143,7 @@ func Search(state *Game, alpha float64, beta float64, depth byte, maxPlayer Play
 		b := beta
 		state.GetMoves(func(boardIndex byte, move byte) bool {
 			state.MakeMove(boardIndex, move)
-			searchValue, _, _ := Search(state, alpha, b, depth-1, maxPlayer, start, maxDuration)
+			searchValue, _, _ := Search(table, state, alpha, b, depth-1, maxPlayer, budget)
 			state.UnMakeMove(move, boardIndex, prevBoard)
 			if searchValue <= value {
 				value = searchValue
@@ -731,13 +1164,13 @@ func Search(state *Game, alpha float64, beta float64, depth byte, maxPlayer Play
 		}
 	}
 
-	/* Traditional transposition table storing of bounds */
-	/* Fail low result implies an upper bound */
+	// Traditional transposition table storing of bounds
+	// Fail low result implies an upper bound
 	if value <= alpha {
 		n.upperBound = value
 		n.flag = UPPER_BOUND
 	}
-	/* Found an exact minimax value – will not occur if called with zero window */
+	// Found an exact minimax value – will not occur if called with zero window
 	if value > alpha && value < beta {
 		n.lowerBound = value
 		n.upperBound = value
@@ -745,7 +1178,7 @@ func Search(state *Game, alpha float64, beta float64, depth byte, maxPlayer Play
 		n.bestBoard = currentBestBoard
 		n.flag = EXACT
 	}
-	/* Fail high result implies a lower bound */
+	// Fail high result implies a lower bound
 	if value >= beta {
 		n.lowerBound = value
 		n.bestMove = currentBestMove
@@ -753,28 +1186,55 @@ func Search(state *Game, alpha float64, beta float64, depth byte, maxPlayer Play
 		n.flag = LOWER_BOUND
 	}
 	n.depth = depth
-	if !cached {
-		TranspositionTable.Set(state.Hash(), n)
+	if !cached && table != nil {
+		table.Set(state.Hash(), n)
 	}
 
 	return value, n.bestMove, n.bestBoard
 }
 
-func mtdF(state *Game, start *time.Time, maxDuration *time.Duration, f float64, d byte, maxPlayer Player) (float64, byte, byte) {
+type Storage struct {
+	nodeStore map[[10]uint32]*Node
+}
+
+func (storage *Storage) Count() int {
+	return len(storage.nodeStore)
+}
+
+func (storage *Storage) Get(hash GameHash) (*Node, bool) {
+	node, exists := storage.nodeStore[*hash]
+	return node, exists
+}
+
+func (storage *Storage) Set(hash GameHash, node *Node) {
+	storage.nodeStore[*hash] = node
+}
+
+func (storage *Storage) Reset() {
+	storage.nodeStore = make(map[[10]uint32]*Node, 150000)
+}
+
+func NewStorage() Storage {
+	return Storage{nodeStore: make(map[[10]uint32]*Node, 150000)}
+}
+
+const mtdInf float64 = 100000
+
+func mtdF(table *Storage, state *Game, budget *Budget, f float64, d byte, maxPlayer Player) (float64, byte, byte) {
 	g := f
-	lowerBound, upperBound := -inf, inf
-	beta := -inf
+	lowerBound, upperBound := -mtdInf, mtdInf
+	beta := -mtdInf
 	var bestMove byte = 253
 	var bestBoard byte = 253
 	var nBestMove, nBestBoard = byte(0), byte(0)
-	for lowerBound < upperBound && time.Since(*start) < *maxDuration {
+	for lowerBound < upperBound && !budget.Exhausted() {
 		if g == lowerBound {
 			beta = g + 1
 		} else {
 			beta = g
 		}
 
-		g, nBestMove, nBestBoard = Search(state, beta-1, beta, d, maxPlayer, start, maxDuration)
+		g, nBestMove, nBestBoard = Search(table, state, beta-1, beta, d, maxPlayer, budget)
 		if nBestBoard < 200 && nBestMove < 200 {
 			bestMove = nBestMove
 			bestBoard = nBestBoard
@@ -790,63 +1250,262 @@ func mtdF(state *Game, start *time.Time, maxDuration *time.Duration, f float64,
 	return g, bestMove, bestBoard
 }
 
-func IterativeDeepeningTime(state *Game, maxDepth byte, maxTime time.Duration) (byte, byte) {
+// IterativeDeepeningBudget searches until the budget is exhausted, report is called after every finished depth unless it is nil
+func IterativeDeepeningBudget(table *Storage, state *Game, maxDepth byte, budget *Budget, report func(Iteration)) (byte, byte) {
 	// Start the guess at the current heuristic
 	var maxPlayer = Player(state.Board[PlayerBoardIndex] & 0x1)
 	var firstGuess = state.HeuristicPlayer(maxPlayer)
 
 	var bestMove byte = 255
 	var bestBoard byte = 255
-	var d byte = 0
-	// HeuristicStorage.Reset()
-	TranspositionTable.Reset()
-	start := time.Now()
-	for ; time.Since(start) < maxTime && d < maxDepth; d++ {
-		firstGuess, bestMove, bestBoard = mtdF(state, &start, &maxTime, firstGuess, d, maxPlayer)
-	}
-	fmt.Fprintf(os.Stderr, "Stored nodes, %d Depth %d \n", TranspositionTable.Count(), d)
+	// A depth 0 search has no move
+	var d byte = 1
+	// Game.HeuristicStorage.Reset()
+	// minimax.TranspositionTable.Reset()
+	for ; budget.Deepen() && d < maxDepth; d++ {
+		firstGuess, bestMove, bestBoard = mtdF(table, state, budget, firstGuess, d, maxPlayer)
+		if report != nil && !budget.Exhausted() {
+			report(Iteration{Depth: d, Score: firstGuess, Board: bestBoard, Move: bestMove})
+		}
+	}
+	// fmt.Fprintf(os.Stderr, "Stored nodes, %d Depth %d \n", minimax.TranspositionTable.Count(), d)
 	return bestMove, bestBoard
 }
 
-/**
- * Auto-generated code below aims at helping you parse
- * the standard input according to the problem statement.
- **/
+const (
+	// NoTimeLimit is the allocation without a time control
+	NoTimeLimit = time.Duration(math.MaxInt64)
 
-func main() {
-	game := NewGame()
-	first := true
+	// MinTime is the least time of a move, a move with a single legal reply gets no more
+	MinTime = time.Millisecond
 
-	for {
-		var opponentRow, opponentCol int
-		fmt.Scan(&opponentRow, &opponentCol)
-		if opponentRow >= 0 {
-			boardIndex, moveIndex := translateOppMove(byte(opponentRow), byte(opponentCol))
-			fmt.Fprintf(os.Stderr, "Opp move BoardIndex %d MoveIndex %d \n", moveIndex, boardIndex)
-			game.MakeMove(boardIndex, moveIndex)
-			first = false
+	// expectedPlies is the length of a typical game, the moves left are estimated from it
+	expectedPlies = 50
+
+	// minMovesLeft keeps time back for the end of a game that takes longer than expected
+	minMovesLeft = 8
+
+	// maxStretch is how far a search may go beyond its target to finish a depth, in targets
+	maxStretch = 3
+)
+
+// Control is the time a player has for a game. Total is the time for all moves and Increment is added after every
+// move, MoveTime caps every move. Without a Total every move may take MoveTime, without both a move has no limit
+type Control struct {
+	Total     time.Duration
+	Increment time.Duration
+	MoveTime  time.Duration
+
+	// Overhead is kept back from every move for the time it takes the move to reach the opponent
+	Overhead time.Duration
+}
+
+// Complexity is what the allocation of a move looks at besides the clock
+type Complexity struct {
+	// Moves are the legal moves
+	Moves int
+
+	// SendsAnywhere counts the moves after which the opponent may play any open board
+	SendsAnywhere int
+}
+
+// Measure returns the complexity of the position for the player to move
+func Measure(game *Game) Complexity {
+	c := Complexity{}
+	game.GetMoves(func(board byte, pos byte) bool {
+		c.Moves++
+		// The square decides the board of the opponent, it may also be the board just finished
+		state := game.Copy()
+		state.MakeMove(board, pos)
+		if state.IsBoardFinished(pos) {
+			c.SendsAnywhere++
 		}
+		return false
+	})
+	return c
+}
 
-		var validActionCount int
-		fmt.Scan(&validActionCount)
+// factor scales the time of a move by its complexity, an average position with nine moves takes 1.
+// A board holds at most nine moves, so the choice of any open board shows in the moves
+func (c Complexity) factor() float64 {
+	moves := c.Moves
+	if moves > 27 {
+		moves = 27
+	}
+	f := 0.5 + float64(moves)/18
+	if c.SendsAnywhere > 0 {
+		// A mistake gives the opponent the choice of every board
+		f *= 1.25
+	}
+	return f
+}
 
-		for i := 0; i < validActionCount; i++ {
-			var row, col int
-			fmt.Scan(&row, &col)
+// Allocate returns the time of the next move with remaining on the clock. Iterative searches start no new depth
+// after soft and every search ends at hard
+func (c Control) Allocate(remaining time.Duration, game *Game) (soft time.Duration, hard time.Duration) {
+	complexity := Measure(game)
+	if complexity.Moves <= 1 {
+		return MinTime, MinTime
+	}
+
+	if c.Total == 0 {
+		if c.MoveTime == 0 {
+			return NoTimeLimit, NoTimeLimit
 		}
+		hard = atLeast(c.MoveTime - c.Overhead)
+		return hard, hard
+	}
+
+	available := remaining - c.Overhead
+	movesLeft := (expectedPlies - int(game.MovesMade()) + 1) / 2
+	if movesLeft < minMovesLeft {
+		movesLeft = minMovesLeft
+	}
+	target := available/time.Duration(movesLeft) + c.Increment*3/4
+	soft = time.Duration(float64(target) * complexity.factor())
+
+	// The clock must survive the hard limit even when the next moves take as long
+	hard = maxStretch * soft
+	if hard > available/4 {
+		hard = available / 4
+	}
+	if c.MoveTime > 0 && hard > c.MoveTime-c.Overhead {
+		hard = c.MoveTime - c.Overhead
+	}
+	hard = atLeast(hard)
+	if soft > hard {
+		soft = hard
+	}
+	return atLeast(soft), hard
+}
 
-		moveIndex, boardIndex := byte(8), byte(8)
-		if !first {
-			start := time.Now()
-			moveIndex, boardIndex = IterativeDeepeningTime(game, 20, 93*time.Millisecond)
-			fmt.Fprintf(os.Stderr, "Our move BoardIndex %d MoveIndex %d \n", moveIndex, boardIndex)
-			fmt.Fprintln(os.Stderr, time.Since(start))
+func atLeast(d time.Duration) time.Duration {
+	if d < MinTime {
+		return MinTime
+	}
+	return d
+}
+
+// Budget allocates the next move and returns the budget of its search, the move starts now.
+// Stop of the budget is the emergency stop, the search returns its best move at once
+func (c Control) Budget(remaining time.Duration, game *Game) *Budget {
+	soft, hard := c.Allocate(remaining, game)
+	budget := NewBudget(hard)
+	budget.SoftTime = soft
+	return budget
+}
+
+// CodinGame allows firstTurnTime for the first turn of a bot and turnTime for the others. Overhead is kept back
+// for reading the turn and writing the move, which wait for the pondering to stop. The first turn also keeps back
+// the start of the process
+const (
+	firstTurnTime = time.Second
+	turnTime      = 100 * time.Millisecond
+	overhead      = 25 * time.Millisecond
+	startup       = 100 * time.Millisecond
+)
+
+// pondering is the search of the expected reply of the opponent during its turn
+type pondering struct {
+	budget   *Budget
+	done     chan struct{}
+	expected [2]byte
+	answer   [2]byte
+}
+
+// ponder searches the position after the expected reply of the principal variation, nil without one
+func ponder(game *Game) *pondering {
+	boards, moves := PrincipalVariation(&TranspositionTable, game, 1)
+	if len(moves) == 0 {
+		return nil
+	}
+	state := game.Copy()
+	state.HeuristicScores = game.HeuristicScores
+	state.MakeMove(boards[0], moves[0])
+	if state.IsTerminal() {
+		return nil
+	}
+
+	p := &pondering{budget: NewPonderBudget(), done: make(chan struct{}), expected: [2]byte{boards[0], moves[0]}}
+	go func() {
+		defer close(p.done)
+		p.answer[1], p.answer[0] = IterativeDeepeningBudget(&TranspositionTable, &state, 20, p.budget, nil)
+	}()
+	return p
+}
+
+// The CodinGame minimax bot, minimax_bot.go in the parent directory is this package bundled by go generate.
+// During the turn of the opponent it searches the expected reply, after another reply the table is reused
+func main() {
+	heuristicPath := flag.String("heuristic", "", "weights or elite file, CodinGame runs without it")
+	heuristicIndex := flag.Int("heuristic-index", BestHeuristic, "weight set in the heuristic file, -1 selects the highest fitness")
+	flag.Parse()
+
+	// The first move may be played on any board
+	game := protocolNewGame()
+	if *heuristicPath != "" {
+		h, err := LoadHeuristic(*heuristicPath, *heuristicIndex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		first = false
+		game.HeuristicScores = h
+	}
+	control := Control{MoveTime: firstTurnTime, Overhead: startup}
+	var pondered *pondering
+	// The next turn is read while the bot ponders, with a single P reading waits until the search is preempted
+	runtime.GOMAXPROCS(2)
+	in := bufio.NewReader(os.Stdin)
+	for {
+		turn, err := ReadTurn(in)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		start := time.Now()
+		var opponent [2]byte
+		if turn.OpponentRow >= 0 {
+			boardIndex, moveIndex := Move(turn.OpponentRow, turn.OpponentCol)
+			fmt.Fprintf(os.Stderr, "Opp move BoardIndex %d MoveIndex %d \n", boardIndex, moveIndex)
+			game.MakeMove(boardIndex, moveIndex)
+			opponent = [2]byte{boardIndex, moveIndex}
+		}
+		if refereeOnly, gameOnly := turn.Mismatches(game); len(refereeOnly) > 0 || len(gameOnly) > 0 {
+			fmt.Fprintf(os.Stderr, "Valid actions differ, only the referee allows %v, only the game allows %v\n", refereeOnly, gameOnly)
+		}
+
+		budget := control.Budget(0, game)
+		budget.Start = start
+		var moveIndex, boardIndex byte = 255, 255
+		if pondered != nil {
+			if pondered.expected == opponent {
+				// The search of the expected reply continues on the time of the turn
+				fmt.Fprintln(os.Stderr, "Ponder hit")
+				pondered.budget.PonderHit(budget)
+				<-pondered.done
+				boardIndex, moveIndex = pondered.answer[0], pondered.answer[1]
+			} else {
+				pondered.budget.Stop()
+				<-pondered.done
+			}
+			pondered = nil
+		}
+		if !game.ValidMove(boardIndex, moveIndex) {
+			moveIndex, boardIndex = IterativeDeepeningBudget(&TranspositionTable, game, 20, budget, nil)
+		}
+		boardIndex, moveIndex, legal := turn.Legal(boardIndex, moveIndex)
+		if !legal {
+			fmt.Fprintln(os.Stderr, "The search played a move the referee does not allow, playing the first valid action")
+		}
+		fmt.Fprintf(os.Stderr, "Our move BoardIndex %d MoveIndex %d \n", boardIndex, moveIndex)
+		fmt.Fprintln(os.Stderr, time.Since(start))
 		game.MakeMove(boardIndex, moveIndex)
+		control = Control{MoveTime: turnTime, Overhead: overhead}
 
-		// fmt.Fprintln(os.Stderr, "Debug messages...")
-		x, y := translateOwnMove(boardIndex, moveIndex)
-		fmt.Printf("%d %d\n", x, y) // Write action to stdout
+		row, col := Action(boardIndex, moveIndex)
+		fmt.Printf("%d %d\n", row, col)
+		if !game.IsTerminal() {
+			pondered = ponder(game)
+		}
 	}
 }
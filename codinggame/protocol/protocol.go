@@ -0,0 +1,118 @@
+// Package protocol translates the moves of the CodinGame Ultimate Tic-Tac-Toe referee
+package protocol
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+// squares holds the column and row of the boards and of the squares within a board, in the order of the game
+var squares = [9][2]int{
+	{0, 0},
+	{1, 0},
+	{2, 0},
+
+	{2, 1},
+	{2, 2},
+
+	{1, 2},
+	{0, 2},
+	{0, 1},
+	{1, 1},
+}
+
+// Action returns the row and column the referee expects for a move. The game is played transposed,
+// which is a symmetry of the game, so the replies only have to agree with Move
+func Action(board byte, pos byte) (int, int) {
+	start := squares[board]
+	offset := squares[pos]
+	return start[0]*3 + offset[0], start[1]*3 + offset[1]
+}
+
+// Move returns the board and square of a row and column sent by the referee
+func Move(row int, col int) (byte, byte) {
+	for board, start := range squares {
+		if row/3 != start[0] || col/3 != start[1] {
+			continue
+		}
+		for pos, offset := range squares {
+			if row%3 == offset[0] && col%3 == offset[1] {
+				return byte(board), byte(pos)
+			}
+		}
+	}
+	return 0, 0
+}
+
+// NewGame is the start of a CodinGame game, unlike Game.NewGame the first move may be played on any board
+func NewGame() *Game.Game {
+	game := Game.NewGame()
+	game.Board[Game.PlayerBoardIndex] |= 0x100
+	return game
+}
+
+// Turn is what the referee sends before every move
+type Turn struct {
+	// OpponentRow and OpponentCol are the last move of the opponent, -1 before the first move of the game
+	OpponentRow int
+	OpponentCol int
+
+	// Actions are the row and column of every valid move
+	Actions [][2]int
+}
+
+// ReadTurn reads the last move of the opponent, the number of valid actions and the actions
+func ReadTurn(r io.Reader) (Turn, error) {
+	turn := Turn{}
+	var count int
+	if _, err := fmt.Fscan(r, &turn.OpponentRow, &turn.OpponentCol, &count); err != nil {
+		return turn, err
+	}
+	turn.Actions = make([][2]int, count)
+	for i := range turn.Actions {
+		if _, err := fmt.Fscan(r, &turn.Actions[i][0], &turn.Actions[i][1]); err != nil {
+			return turn, err
+		}
+	}
+	return turn, nil
+}
+
+// Allows reports if the move is one of the valid actions
+func (t Turn) Allows(board byte, pos byte) bool {
+	row, col := Action(board, pos)
+	for _, action := range t.Actions {
+		if action == [2]int{row, col} {
+			return true
+		}
+	}
+	return false
+}
+
+// Mismatches returns the valid actions the game does not allow and the moves of the game the referee does not allow,
+// both as row and column. They are empty unless the game lost track of the referee
+func (t Turn) Mismatches(game *Game.Game) (refereeOnly [][2]int, gameOnly [][2]int) {
+	for _, action := range t.Actions {
+		if board, pos := Move(action[0], action[1]); !game.ValidMove(board, pos) {
+			refereeOnly = append(refereeOnly, action)
+		}
+	}
+	game.GetMoves(func(board byte, pos byte) bool {
+		if !t.Allows(board, pos) {
+			row, col := Action(board, pos)
+			gameOnly = append(gameOnly, [2]int{row, col})
+		}
+		return false
+	})
+	return refereeOnly, gameOnly
+}
+
+// Legal returns the move if the referee allows it, otherwise the first valid action and false
+func (t Turn) Legal(board byte, pos byte) (byte, byte, bool) {
+	if t.Allows(board, pos) || len(t.Actions) == 0 {
+		return board, pos, true
+	}
+	board, pos = Move(t.Actions[0][0], t.Actions[0][1])
+	return board, pos, false
+}
@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestActionMove(t *testing.T) {
+	seen := map[[2]int]bool{}
+	for board := byte(0); board < 9; board++ {
+		for pos := byte(0); pos < 9; pos++ {
+			row, col := Action(board, pos)
+			if row < 0 || row > 8 || col < 0 || col > 8 || seen[[2]int{row, col}] {
+				t.Fatalf("move %d%d is sent as %d %d", board, pos, row, col)
+			}
+			seen[[2]int{row, col}] = true
+
+			if b, p := Move(row, col); b != board || p != pos {
+				t.Errorf("%d %d is read as %d%d, want %d%d", row, col, b, p, board, pos)
+			}
+		}
+	}
+
+	if row, col := Action(8, 8); row != 4 || col != 4 {
+		t.Errorf("the centre is sent as %d %d", row, col)
+	}
+}
+
+func TestTurn(t *testing.T) {
+	turn, err := ReadTurn(strings.NewReader("4 4\n3\n3 3\n3 4\n3 5\n"))
+	if err != nil || turn.OpponentRow != 4 || turn.OpponentCol != 4 || len(turn.Actions) != 3 || turn.Actions[2] != [2]int{3, 5} {
+		t.Fatalf("read %v, %v", turn, err)
+	}
+	if _, err = ReadTurn(strings.NewReader("-1 -1\n2\n0 0\n")); err == nil {
+		t.Errorf("a turn missing an action was read")
+	}
+
+	game := NewGame()
+	game.MakeMove(8, 8)
+	// Only row 3 of the middle board, the game also allows the other five squares
+	refereeOnly, gameOnly := turn.Mismatches(game)
+	if len(refereeOnly) != 0 || len(gameOnly) != 5 {
+		t.Errorf("mismatches %v %v", refereeOnly, gameOnly)
+	}
+
+	if board, pos, legal := turn.Legal(8, 7); !legal || board != 8 || pos != 7 {
+		t.Errorf("allowed move 87 was replaced by %d%d", board, pos)
+	}
+	if board, pos, legal := turn.Legal(0, 0); legal || board != 8 || pos != 0 {
+		t.Errorf("illegal move 00 was replaced by %d%d", board, pos)
+	}
+}
+
+func TestNewGame(t *testing.T) {
+	moves := 0
+	NewGame().GetMoves(func(board byte, pos byte) bool {
+		moves++
+		return false
+	})
+	if moves != 81 {
+		t.Errorf("%d moves at the start", moves)
+	}
+}
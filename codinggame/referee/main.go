@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+// referee plays two bot binaries against each other over the stdin and stdout protocol of the CodinGame referee.
+// Every turn the bot to move reads the row and column of the last move of its opponent, -1 -1 on the first turn
+// of the game, the number of valid actions and one row and column per line. It answers with a row and column.
+// The bots start again for every game and take turns to move first, a late answer or an illegal move loses
+func main() {
+	bot1 := flag.String("bot1", "", "command line of the first bot, e.g. ./minimax_bot")
+	bot2 := flag.String("bot2", "", "command line of the second bot")
+	games := flag.Int("games", 2, "games to play, the bots move first in turns")
+	recordsPath := flag.String("records", "", "file every finished game is appended to in the record format")
+	showStderr := flag.Bool("stderr", false, "pass the stderr of the bots through")
+	limit := limits{}
+	flag.DurationVar(&limit.first, "first-turn-time", time.Second, "response time of the first turn of a bot")
+	flag.DurationVar(&limit.turn, "turn-time", 100*time.Millisecond, "response time of the later turns")
+	flag.Parse()
+
+	if *bot1 == "" || *bot2 == "" || *games < 1 || limit.first <= 0 || limit.turn <= 0 {
+		log.Fatalln("invalid settings, see -help")
+	}
+
+	var records *os.File
+	if *recordsPath != "" {
+		var err error
+		if records, err = os.OpenFile(*recordsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			log.Fatalln("records:", err)
+		}
+		defer records.Close()
+	}
+	stderr := io.Discard
+	if *showStderr {
+		stderr = os.Stderr
+	}
+
+	score := match.Score{}
+	for i := 0; i < *games; i++ {
+		// The first bot moves first in the even games, the player to move first is Player2
+		players, first := [2]string{*bot2, *bot1}, Game.Player2
+		if i%2 == 1 {
+			players, first = [2]string{*bot1, *bot2}, Game.Player1
+		}
+
+		record, note, err := playGame(players, limit, stderr)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if records != nil {
+			if err = record.Write(records); err != nil {
+				log.Fatalln("records:", err)
+			}
+		}
+
+		score.AddRecord(record, first)
+		fmt.Printf("%d\t%s\t%s\t%s\n", i+1, record.Result(), score, note)
+	}
+
+	fmt.Println()
+	fmt.Printf("%s vs %s\n", *bot1, *bot2)
+	fmt.Printf("games %d\tW/D/L %d/%d/%d\tscore %.3f\n", score.Games(), score.Wins, score.Draws, score.Losses, score.Mean())
+}
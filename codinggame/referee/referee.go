@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/codinggame/protocol"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+// startPosition is the empty board with every square open, CodinGame does not force the first move onto the middle board
+const startPosition = "........./........./........./........./........./........./........./........./......... o -"
+
+// limits are the response times of CodinGame, the first turn of a bot may take longer
+type limits struct {
+	first time.Duration
+	turn  time.Duration
+}
+
+// process is a bot running as a subprocess, it reads the turns on stdin and answers on stdout
+type process struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	done  chan struct{}
+}
+
+func startProcess(command string, stderr io.Writer) (*process, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no bot command")
+	}
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &process{cmd: cmd, stdin: stdin, lines: make(chan string), done: make(chan struct{})}
+	go func() {
+		defer close(p.lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case p.lines <- scanner.Text():
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p, nil
+}
+
+// answer sends the turn and waits for the reply, ok is false if the bot did not answer in time
+func (p *process) answer(turn string, limit time.Duration) (line string, ok bool) {
+	if _, err := io.WriteString(p.stdin, turn); err != nil {
+		return "", false
+	}
+
+	timer := time.NewTimer(limit)
+	defer timer.Stop()
+	select {
+	case line, ok = <-p.lines:
+		return line, ok
+	case <-timer.C:
+		return "", false
+	}
+}
+
+func (p *process) stop() {
+	close(p.done)
+	_ = p.stdin.Close()
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+}
+
+// validActions lists the row and column of every legal move, row by row as CodinGame sends them
+func validActions(game *Game.Game) [][2]int {
+	var actions [][2]int
+	for row := 0; row < 9; row++ {
+		for col := 0; col < 9; col++ {
+			if board, pos := protocol.Move(row, col); game.ValidMove(board, pos) {
+				actions = append(actions, [2]int{row, col})
+			}
+		}
+	}
+	return actions
+}
+
+// formatTurn is the input of a turn, the last move of the opponent followed by the number of valid actions and the actions
+func formatTurn(last [2]int, actions [][2]int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d %d\n%d\n", last[0], last[1], len(actions))
+	for _, action := range actions {
+		fmt.Fprintf(&sb, "%d %d\n", action[0], action[1])
+	}
+	return sb.String()
+}
+
+// parseAction reads the row and column of a reply, CodinGame ignores a message after them
+func parseAction(line string) (int, int, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return 0, 0, fmt.Errorf("invalid reply %q, want the row and column", line)
+	}
+	row, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid reply %q, want the row and column", line)
+	}
+	col, err := strconv.Atoi(fields[1])
+	if err != nil || row < 0 || row > 8 || col < 0 || col > 8 {
+		return 0, 0, fmt.Errorf("invalid reply %q, want the row and column", line)
+	}
+	return row, col, nil
+}
+
+// playGame runs a game between the bots, players is indexed by Game.Player and Player2 moves first.
+// A bot that does not answer in time or plays an illegal move loses, note says why
+func playGame(players [2]string, limit limits, stderr io.Writer) (record *match.Record, note string, err error) {
+	var processes [2]*process
+	defer func() {
+		for _, p := range processes {
+			if p != nil {
+				p.stop()
+			}
+		}
+	}()
+	for player, command := range players {
+		if processes[player], err = startProcess(command, stderr); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", command, err)
+		}
+	}
+
+	game, err := match.ParsePosition(startPosition)
+	if err != nil {
+		return nil, "", err
+	}
+	record = &match.Record{Players: players, Start: startPosition, Moves: make([]match.Move, 0, 81), Final: game}
+	last := [2]int{-1, -1}
+	for !game.IsTerminal() {
+		player := Game.Player(game.Board[Game.PlayerBoardIndex] & 0x1)
+		actions := validActions(game)
+		timeout := limit.turn
+		if record.Searches[player] == 0 {
+			timeout = limit.first
+		}
+
+		start := time.Now()
+		line, ok := processes[player].answer(formatTurn(last, actions), timeout)
+		record.Time[player] += time.Since(start)
+		record.Searches[player]++
+		if !ok {
+			record.Forfeit = match.TIME_FORFEIT
+			return record, fmt.Sprintf("%s did not answer within %s", players[player], timeout), nil
+		}
+
+		row, col, err := parseAction(line)
+		if err != nil {
+			record.Forfeit = match.ILLEGAL_MOVE
+			return record, fmt.Sprintf("%s: %s", players[player], err), nil
+		}
+		board, pos := protocol.Move(row, col)
+		if !game.ValidMove(board, pos) {
+			record.Forfeit = match.ILLEGAL_MOVE
+			return record, fmt.Sprintf("%s played the illegal move %d %d", players[player], row, col), nil
+		}
+
+		game.MakeMove(board, pos)
+		record.Moves = append(record.Moves, match.Move{Board: board, Pos: pos})
+		last = [2]int{row, col}
+	}
+	return record, "", nil
+}
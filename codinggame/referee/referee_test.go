@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+// TestMain runs the test binary as a bot when the tests start it as a subprocess
+func TestMain(m *testing.M) {
+	if os.Getenv("REFEREE_TEST_BOT") != "" {
+		runTestBot(os.Args[1])
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runTestBot plays the first valid action, a square that is not valid when illegal and nothing when silent
+func runTestBot(behaviour string) {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		var row, col, count int
+		if _, err := fmt.Fscan(in, &row, &col, &count); err != nil {
+			return
+		}
+		actions := map[[2]int]bool{}
+		var first [2]int
+		for i := 0; i < count; i++ {
+			var action [2]int
+			fmt.Fscan(in, &action[0], &action[1])
+			if i == 0 {
+				first = action
+			}
+			actions[action] = true
+		}
+
+		switch behaviour {
+		case "first":
+			fmt.Printf("%d %d first\n", first[0], first[1])
+		case "illegal":
+			// Every square is valid on the first turn of the game
+			illegal := [2]int{9, 9}
+			for square := 0; square < 81; square++ {
+				if action := [2]int{square / 9, square % 9}; !actions[action] {
+					illegal = action
+					break
+				}
+			}
+			fmt.Printf("%d %d\n", illegal[0], illegal[1])
+		case "silent":
+		}
+	}
+}
+
+func testBots(t *testing.T, player1 string, player2 string) [2]string {
+	t.Setenv("REFEREE_TEST_BOT", "1")
+	return [2]string{os.Args[0] + " " + player1, os.Args[0] + " " + player2}
+}
+
+var testLimits = limits{first: 5 * time.Second, turn: 2 * time.Second}
+
+func TestPlayGame(t *testing.T) {
+	record, note, err := playGame(testBots(t, "first", "first"), testLimits, io.Discard)
+	if err != nil || note != "" || record.Forfeit != match.NO_FORFEIT || !record.Final.IsTerminal() {
+		t.Fatalf("game ended with %v %q %q", err, note, record.Forfeit)
+	}
+	if record.Searches[Game.Player1]+record.Searches[Game.Player2] != len(record.Moves) {
+		t.Errorf("%v searches for %d moves", record.Searches, len(record.Moves))
+	}
+	// Both bots play the first square, row 0 column 0 is square 0 of board 0
+	if record.Moves[0] != (match.Move{Board: 0, Pos: 0}) {
+		t.Errorf("first move %s", record.Moves[0])
+	}
+
+	buffer := bytes.Buffer{}
+	_ = record.Write(&buffer)
+	records, err := match.ReadRecords(&buffer)
+	if err != nil || len(records) != 1 || !records[0].Final.Compare(record.Final) || records[0].Result() != record.Result() {
+		t.Fatalf("read %v, %v", records, err)
+	}
+}
+
+func TestForfeits(t *testing.T) {
+	for _, test := range []struct {
+		players [2]string
+		forfeit match.Forfeit
+		winner  Game.Player
+	}{
+		{[2]string{"illegal", "first"}, match.ILLEGAL_MOVE, Game.Player2},
+		{[2]string{"first", "illegal"}, match.ILLEGAL_MOVE, Game.Player1},
+		{[2]string{"first", "silent"}, match.TIME_FORFEIT, Game.Player1},
+	} {
+		limit := testLimits
+		if test.forfeit == match.TIME_FORFEIT {
+			limit = limits{first: 100 * time.Millisecond, turn: 100 * time.Millisecond}
+		}
+		record, note, err := playGame(testBots(t, test.players[0], test.players[1]), limit, io.Discard)
+		if err != nil || record.Forfeit != test.forfeit || record.Winner() != test.winner {
+			t.Errorf("%v ended with %v %q, winner %d", test.players, err, record.Forfeit, record.Winner())
+		}
+		if note == "" {
+			t.Errorf("%v: the forfeit has no note", test.players)
+		}
+	}
+}
+
+func TestTurn(t *testing.T) {
+	game, _ := match.ParsePosition(startPosition)
+	actions := validActions(game)
+	if len(actions) != 81 || actions[0] != [2]int{0, 0} || actions[80] != [2]int{8, 8} {
+		t.Fatalf("%d actions at the start", len(actions))
+	}
+
+	game.MakeMove(8, 8)
+	turn := formatTurn([2]int{4, 4}, validActions(game))
+	if turn != "4 4\n8\n3 3\n3 4\n3 5\n4 3\n4 5\n5 3\n5 4\n5 5\n" {
+		t.Errorf("turn after the centre is %q", turn)
+	}
+
+	for _, invalid := range []string{"", "4", "a 4", "4 9", "-1 -1"} {
+		if _, _, err := parseAction(invalid); err == nil {
+			t.Errorf("%q was accepted", invalid)
+		}
+	}
+}
+
+// TestCodinGameBots plays the generated submissions with the limits of CodinGame
+func TestCodinGameBots(t *testing.T) {
+	if testing.Short() {
+		t.Skip("plays a full game at 100ms per move")
+	}
+
+	var binaries [2]string
+	for i, bot := range []string{"minimax", "mcts"} {
+		binaries[i] = filepath.Join(t.TempDir(), bot)
+		if output, err := exec.Command("go", "build", "-o", binaries[i], "../"+bot).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %s", err, output)
+		}
+	}
+	for _, players := range [][2]string{binaries, {binaries[1], binaries[0]}} {
+		record, note, err := playGame(players, limits{first: time.Second, turn: 100 * time.Millisecond}, io.Discard)
+		if err != nil || record.Forfeit != match.NO_FORFEIT {
+			t.Fatalf("game ended with %v %q", err, note)
+		}
+	}
+}
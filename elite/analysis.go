@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/tuning"
+)
+
+// entry is a distinct weight set, generations count from 1 in the order of the elite file
+type entry struct {
+	First int
+	Last  int
+	Count int
+	Elite Game.Elite
+}
+
+func key(h *Game.HeuristicScores) string {
+	values := make([]float64, len(tuning.Params))
+	for i, param := range tuning.Params {
+		values[i] = *param.Field(h)
+	}
+	return fmt.Sprint(values)
+}
+
+// dedupe merges the generations that kept the same weights, the highest fitness of them is kept
+func dedupe(elites []Game.Elite) []*entry {
+	var entries []*entry
+	seen := map[string]*entry{}
+	for i, elite := range elites {
+		k := key(elite.Heuristic)
+		if e, ok := seen[k]; ok {
+			e.Last = i + 1
+			e.Count++
+			if elite.Fitness > e.Elite.Fitness {
+				e.Elite.Fitness = elite.Fitness
+			}
+			continue
+		}
+
+		e := &entry{First: i + 1, Last: i + 1, Count: 1, Elite: elite}
+		seen[k] = e
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// top returns the n fittest entries, on equal fitness the later one wins as the tuner appends elites as they improve
+func top(entries []*entry, n int) []*entry {
+	sorted := append([]*entry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Elite.Fitness != sorted[j].Elite.Fitness {
+			return sorted[i].Elite.Fitness > sorted[j].Elite.Fitness
+		}
+		return sorted[i].Last > sorted[j].Last
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// summary describes one parameter over all generations, Trend is the least squares slope per generation
+type summary struct {
+	Name  string
+	Min   float64
+	Max   float64
+	Mean  float64
+	First float64
+	Last  float64
+	Trend float64
+}
+
+func summarise(elites []Game.Elite) []summary {
+	summaries := make([]summary, len(tuning.Params))
+	n := float64(len(elites))
+	for p, param := range tuning.Params {
+		s := summary{Name: param.Name, Min: math.Inf(1), Max: math.Inf(-1)}
+		var sumX, sumY, sumXY, sumXX float64
+		for i, elite := range elites {
+			x, y := float64(i+1), *param.Field(elite.Heuristic)
+			s.Min, s.Max = math.Min(s.Min, y), math.Max(s.Max, y)
+			sumX, sumY, sumXY, sumXX = sumX+x, sumY+y, sumXY+x*y, sumXX+x*x
+		}
+
+		if len(elites) > 0 {
+			s.Mean = sumY / n
+			s.First = *param.Field(elites[0].Heuristic)
+			s.Last = *param.Field(elites[len(elites)-1].Heuristic)
+		}
+		if denominator := n*sumXX - sumX*sumX; denominator != 0 {
+			s.Trend = (n*sumXY - sumX*sumY) / denominator
+		}
+		summaries[p] = s
+	}
+	return summaries
+}
+
+// standing is the result of an entry in the round robin
+type standing struct {
+	Entry *entry
+	Score match.Score
+}
+
+// roundRobin plays every pair of entries on the openings and ranks them by their mean score
+func roundRobin(entries []*entry, bot match.Bot, openings [][]match.Move, threads int) []standing {
+	standings := make([]standing, len(entries))
+	bots := make([]*match.Bot, len(entries))
+	for i, e := range entries {
+		standings[i].Entry = e
+		b := bot
+		b.Heuristic = e.Elite.Heuristic
+		bots[i] = &b
+	}
+
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			score := match.PlayOpenings(bots[i], bots[j], openings, threads)
+			standings[i].Score.Merge(score)
+			standings[j].Score.Merge(score.Reversed())
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Score.Mean() > standings[j].Score.Mean()
+	})
+	return standings
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+func elitesWithRising(values ...float64) []Game.Elite {
+	elites := make([]Game.Elite, len(values))
+	for i, value := range values {
+		h := Game.DefaultHeuristic()
+		h.WonBoardRating = value
+		elites[i] = Game.Elite{Fitness: int(value), Heuristic: h}
+	}
+	return elites
+}
+
+func TestDedupeAndTop(t *testing.T) {
+	entries := dedupe(elitesWithRising(1, 2, 2, 3, 2))
+	if len(entries) != 3 {
+		t.Fatalf("%d distinct weight sets, want 3", len(entries))
+	}
+	if e := entries[1]; e.First != 2 || e.Last != 5 || e.Count != 3 {
+		t.Fatalf("repeated weights span generations %d to %d with count %d", e.First, e.Last, e.Count)
+	}
+
+	best := top(entries, 2)
+	if len(best) != 2 || best[0].Elite.Fitness != 3 || best[1].Elite.Fitness != 2 {
+		t.Fatalf("top entries %+v %+v", best[0], best[1])
+	}
+}
+
+func TestSummariseTrend(t *testing.T) {
+	for _, s := range summarise(elitesWithRising(1, 3, 5, 7)) {
+		if s.Name != "WonBoardRating" {
+			if math.Abs(s.Trend) > 1e-9 || s.Min != s.Max {
+				t.Errorf("%s changed without being tuned", s.Name)
+			}
+			continue
+		}
+		if s.Min != 1 || s.Max != 7 || s.Mean != 4 || s.First != 1 || s.Last != 7 || math.Abs(s.Trend-2) > 1e-9 {
+			t.Fatalf("summary %+v, want a slope of 2", s)
+		}
+	}
+}
+
+func TestRoundRobinReport(t *testing.T) {
+	entries := dedupe(elitesWithRising(1, 20, 40))
+	standings := roundRobin(entries, match.Bot{Depth: 2}, [][]match.Move{nil}, 2)
+	for _, s := range standings {
+		if s.Score.Games() != 4 {
+			t.Fatalf("generation %d played %d games, want 2 against each of the others", s.Entry.First, s.Score.Games())
+		}
+	}
+	if standings[0].Score.Mean() < standings[len(standings)-1].Score.Mean() {
+		t.Fatal("standings are not ranked by score")
+	}
+
+	buffer := bytes.Buffer{}
+	if err := writeCSV(&buffer, standingRows(standings)); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buffer.String(), "\n"); lines != 4 {
+		t.Fatalf("CSV has %d lines, want a header and 3 rows", lines)
+	}
+}
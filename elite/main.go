@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+func writeFile(path string, write func(f *os.File) error) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = write(f); err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		log.Fatalln(path+":", err)
+	}
+}
+
+func main() {
+	topN := flag.Int("top", 4, "fittest distinct weight sets played in the round robin, below 2 skips it")
+	paramsCSV := flag.String("params-csv", "", "file the parameter summary is written to as CSV")
+	tournamentCSV := flag.String("tournament-csv", "", "file the round robin standings are written to as CSV")
+	champion := flag.String("champion", "", "file the weights of the round robin winner are written to")
+
+	depth := flag.Int("depth", 5, "maximum search depth per move")
+	moveTime := flag.Duration("move-time", time.Millisecond*100, "search time per move, 0 bounds the search by depth only")
+	openingCount := flag.Int("openings", 4, "random openings per pairing, each is played with both colours")
+	openingPlies := flag.Int("opening-plies", 2, "moves of the random openings, 0 plays the start position")
+	threads := flag.Int("threads", 16, "games played at the same time")
+	seed := flag.Int64("seed", 0, "seed of the openings, 0 picks one from the clock")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: elite [flags] elitePop.txt")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	elites, err := Game.LoadElites(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(elites) == 0 {
+		log.Fatalln(flag.Arg(0), "has no weight sets")
+	}
+
+	entries := dedupe(elites)
+	fmt.Printf("%d generations, %d distinct weight sets\n\n", len(elites), len(entries))
+
+	summaries := summaryRows(summarise(elites))
+	_ = writeTable(os.Stdout, summaries)
+	if *paramsCSV != "" {
+		writeFile(*paramsCSV, func(f *os.File) error { return writeCSV(f, summaries) })
+	}
+
+	if *topN < 2 || len(entries) < 2 {
+		return
+	}
+	if *depth < 2 || *depth > 255 || *threads < 1 || *moveTime < 0 || *openingCount < 1 || *openingPlies < 0 {
+		log.Fatalln("invalid round robin settings, see -help")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	Game.Seed(*seed)
+
+	openings := [][]match.Move{nil}
+	if *openingPlies > 0 {
+		rng := rand.New(rand.NewSource(*seed))
+		openings = make([][]match.Move, *openingCount)
+		for i := range openings {
+			openings[i] = match.RandomOpening(rng, *openingPlies)
+		}
+	}
+
+	standings := roundRobin(top(entries, *topN), match.Bot{Depth: byte(*depth), MoveTime: *moveTime}, openings, *threads)
+	fmt.Println()
+	rows := standingRows(standings)
+	_ = writeTable(os.Stdout, rows)
+	if *tournamentCSV != "" {
+		writeFile(*tournamentCSV, func(f *os.File) error { return writeCSV(f, rows) })
+	}
+
+	best := standings[0].Entry
+	fmt.Printf("\nchampion: generation %d, score %s\n", best.First, standings[0].Score)
+	if *champion != "" {
+		data, err := json.MarshalIndent(best.Elite, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		writeFile(*champion, func(f *os.File) error {
+			_, err := f.Write(append(data, '\n'))
+			return err
+		})
+	}
+}
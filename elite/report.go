@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+func summaryRows(summaries []summary) [][]string {
+	rows := [][]string{{"parameter", "min", "max", "mean", "first", "last", "trend"}}
+	for _, s := range summaries {
+		rows = append(rows, []string{s.Name, formatFloat(s.Min), formatFloat(s.Max), formatFloat(s.Mean), formatFloat(s.First), formatFloat(s.Last), formatFloat(s.Trend)})
+	}
+	return rows
+}
+
+func standingRows(standings []standing) [][]string {
+	rows := [][]string{{"rank", "generation", "last generation", "fitness", "score", "interval", "wins", "draws", "losses"}}
+	for i, s := range standings {
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1),
+			strconv.Itoa(s.Entry.First),
+			strconv.Itoa(s.Entry.Last),
+			strconv.Itoa(s.Entry.Elite.Fitness),
+			strconv.FormatFloat(s.Score.Mean(), 'f', 3, 64),
+			strconv.FormatFloat(s.Score.ConfidenceInterval(), 'f', 3, 64),
+			strconv.Itoa(s.Score.Wins),
+			strconv.Itoa(s.Score.Draws),
+			strconv.Itoa(s.Score.Losses),
+		})
+	}
+	return rows
+}
+
+// writeTable aligns the rows in columns, the first row is the header
+func writeTable(w io.Writer, rows [][]string) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, row := range rows {
+		for _, cell := range row {
+			if _, err := fmt.Fprint(table, cell, "\t"); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(table); err != nil {
+			return err
+		}
+	}
+	return table.Flush()
+}
+
+func writeCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
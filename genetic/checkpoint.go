@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tomcraven/goga"
+)
+
+// checkpointGenome is a member of the population, the bits are stored as a string of 0 and 1
+type checkpointGenome struct {
+	Fitness int
+	Bits    string
+}
+
+// checkpoint is everything needed to continue a tuning run after the generation was simulated
+type checkpoint struct {
+	Generation int
+
+	// Seed is the seed of the run, every generation reseeds the mating with Seed + Generation
+	Seed int64
+
+	Best       checkpointGenome
+	HallOfFame []checkpointGenome
+	Population []checkpointGenome
+}
+
+func encodeBits(bits *goga.Bitset) string {
+	var sb strings.Builder
+	for _, bit := range bits.GetAll() {
+		sb.WriteByte(byte('0' + bit))
+	}
+	return sb.String()
+}
+
+func decodeBits(s string) (goga.Bitset, error) {
+	bits := goga.Bitset{}
+	bits.Create(len(s))
+	for i, c := range s {
+		if c != '0' && c != '1' {
+			return bits, fmt.Errorf("invalid bit %q at %d", c, i)
+		}
+		bits.Set(i, int(c-'0'))
+	}
+	return bits, nil
+}
+
+func newCheckpointGenome(g goga.Genome) checkpointGenome {
+	return checkpointGenome{Fitness: g.GetFitness(), Bits: encodeBits(g.GetBits())}
+}
+
+// bitset decodes the genome, it must match the current genome layout
+func (g checkpointGenome) bitset() (goga.Bitset, error) {
+	if len(g.Bits) != totalBits() {
+		return goga.Bitset{}, fmt.Errorf("genome has %d bits, want %d", len(g.Bits), totalBits())
+	}
+	return decodeBits(g.Bits)
+}
+
+func newCheckpoint(generation int, seed int64, best checkpointGenome, population []goga.Genome) *checkpoint {
+	c := &checkpoint{Generation: generation, Seed: seed, Best: best, Population: make([]checkpointGenome, len(population))}
+	for i, g := range population {
+		c.Population[i] = newCheckpointGenome(g)
+	}
+	return c
+}
+
+// genomes returns the bitsets of the population
+func (c *checkpoint) genomes() ([]goga.Bitset, error) {
+	bitsets := make([]goga.Bitset, len(c.Population))
+	for i, g := range c.Population {
+		bits, err := g.bitset()
+		if err != nil {
+			return nil, fmt.Errorf("genome %d: %w", i, err)
+		}
+		bitsets[i] = bits
+	}
+	return bitsets, nil
+}
+
+// save replaces the checkpoint at path, the file is written next to it first so a crash never leaves half a checkpoint
+func (c *checkpoint) save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checkpoint{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(c.Population) == 0 {
+		return nil, fmt.Errorf("%s: checkpoint has no population", path)
+	}
+	return c, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/tomcraven/goga"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	heuristic := Game.DefaultHeuristic()
+	heuristic.WonBoardRating += 1
+	population := []goga.Genome{
+		goga.NewGenome(encodeHeuristic(Game.DefaultHeuristic())),
+		goga.NewGenome(encodeHeuristic(heuristic)),
+	}
+	population[0].SetFitness(300)
+	population[1].SetFitness(500)
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	saved := newCheckpoint(7, 42, newCheckpointGenome(population[1]), population)
+	if err := saved.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Generation != 7 || loaded.Seed != 42 || loaded.Best != saved.Best {
+		t.Fatalf("loaded generation %d seed %d best %+v", loaded.Generation, loaded.Seed, loaded.Best)
+	}
+
+	genomes, err := loaded.genomes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, bits := range genomes {
+		if encodeBits(&bits) != encodeBits(population[i].GetBits()) || loaded.Population[i].Fitness != population[i].GetFitness() {
+			t.Errorf("genome %d changed", i)
+		}
+	}
+
+	create := utttHeuristicBitsetCreate{resume: genomes}
+	for i := range population {
+		bits := create.Go()
+		if encodeBits(&bits) != encodeBits(population[i].GetBits()) {
+			t.Errorf("resumed genome %d is not the checkpointed one", i)
+		}
+	}
+	bits := create.Go()
+	if encodeBits(&bits) != encodeBits(population[0].GetBits()) {
+		t.Error("the population after the checkpoint does not start from the default heuristic")
+	}
+}
+
+func TestCheckpointRejectsOtherLayout(t *testing.T) {
+	c := checkpoint{Population: []checkpointGenome{{Bits: "0101"}}}
+	if _, err := c.genomes(); err == nil {
+		t.Fatal("expected an error for a genome of the wrong length")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/tomcraven/goga"
+)
+
+// duration is a time.Duration written as "250ms" in the config file
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	value, err := time.ParseDuration(s)
+	*d = duration(value)
+	return err
+}
+
+var materFunctions = map[string]func(goga.Genome, goga.Genome) (goga.Genome, goga.Genome){
+	"OnePointCrossover": goga.OnePointCrossover,
+	"TwoPointCrossover": goga.TwoPointCrossover,
+	"UniformCrossover":  goga.UniformCrossover,
+	"Mutate":            goga.Mutate,
+}
+
+// materProbability is a goga.MaterFunctionProbability with the function referred to by name
+type materProbability struct {
+	P        float32
+	F        string
+	UseElite bool
+}
+
+// opponentPool is what every genome is played against, each opening is played with both colours against every opponent
+type opponentPool struct {
+	// Default plays the Opponent heuristic
+	Default bool
+
+	// HallOfFame plays the elites of this many earlier generations
+	HallOfFame int
+
+	// Population plays this many random members of the last generation
+	Population int
+
+	// MCTS plays the default MCTS bot
+	MCTS bool
+
+	// Openings random openings of OpeningPlies moves are played, no plies plays the start position once
+	Openings     int
+	OpeningPlies int
+}
+
+// config is everything an experiment can change, it is read from a JSON file and flags override single values
+type config struct {
+	Population int
+	Threads    int
+
+	// MoveTime and Depth limit the search of every move in the simulated games, MCTSRounds limits the MCTS opponent.
+	// Without a MoveTime only Depth and MCTSRounds bound the searches and a run repeats exactly for the same Seed
+	MoveTime   duration
+	Depth      int
+	MCTSRounds int
+
+	Mater []materProbability
+
+	// Opponent is an elite or weights file, OpponentIndex picks the heuristic in it, empty plays the default heuristic
+	Opponent      string
+	OpponentIndex int
+	Opponents     opponentPool
+
+	Output          string
+	Checkpoint      string
+	CheckpointEvery int
+	Seed            int64
+}
+
+func defaultConfig() *config {
+	return &config{
+		Population: 200,
+		Threads:    16,
+		MoveTime:   duration(time.Millisecond * 250),
+		Depth:      5,
+		MCTSRounds: 20000,
+		Mater: []materProbability{
+			{P: 1.0, F: "UniformCrossover", UseElite: true},
+			{P: 1.0, F: "TwoPointCrossover", UseElite: true},
+			{P: 0.8, F: "Mutate"},
+			{P: 1.0, F: "Mutate"},
+			{P: 1.0, F: "Mutate"},
+			{P: 0.7, F: "Mutate"},
+			{P: 1.0, F: "Mutate"},
+			{P: 1.0, F: "Mutate"},
+			{P: 1.0, F: "Mutate"},
+			{P: 1.0, F: "Mutate"},
+		},
+		OpponentIndex: Game.BestHeuristic,
+		Opponents: opponentPool{
+			Default:      true,
+			HallOfFame:   2,
+			Population:   1,
+			Openings:     2,
+			OpeningPlies: 2,
+		},
+		Output:          "elitePop.txt",
+		Checkpoint:      "checkpoint.json",
+		CheckpointEvery: 1,
+	}
+}
+
+// bind registers a flag for every scalar setting, the defaults are the current values
+func (c *config) bind(fs *flag.FlagSet) {
+	fs.IntVar(&c.Population, "population", c.Population, "genomes per generation")
+	fs.IntVar(&c.Threads, "threads", c.Threads, "genomes simulated in parallel")
+	fs.DurationVar((*time.Duration)(&c.MoveTime), "move-time", time.Duration(c.MoveTime), "search time per move, 0 bounds the search by depth and rounds only to make runs reproducible")
+	fs.IntVar(&c.Depth, "depth", c.Depth, "maximum search depth per move")
+	fs.IntVar(&c.MCTSRounds, "mcts-rounds", c.MCTSRounds, "rounds per move of the MCTS opponent without a move time")
+	fs.StringVar(&c.Opponent, "opponent", c.Opponent, "elite or weights file of the opponent, empty plays the default heuristic")
+	fs.IntVar(&c.OpponentIndex, "opponent-index", c.OpponentIndex, "heuristic of the opponent file, -1 picks the fittest elite")
+	fs.BoolVar(&c.Opponents.Default, "play-default", c.Opponents.Default, "play the opponent heuristic")
+	fs.IntVar(&c.Opponents.HallOfFame, "hall-of-fame", c.Opponents.HallOfFame, "play the elites of this many earlier generations")
+	fs.IntVar(&c.Opponents.Population, "play-population", c.Opponents.Population, "play this many random members of the last generation")
+	fs.BoolVar(&c.Opponents.MCTS, "play-mcts", c.Opponents.MCTS, "play the default MCTS bot")
+	fs.IntVar(&c.Opponents.Openings, "openings", c.Opponents.Openings, "random openings per opponent, each is played with both colours")
+	fs.IntVar(&c.Opponents.OpeningPlies, "opening-plies", c.Opponents.OpeningPlies, "moves of the random openings, 0 plays the start position")
+	fs.StringVar(&c.Output, "output", c.Output, "file the elite of every generation is appended to")
+	fs.StringVar(&c.Checkpoint, "checkpoint", c.Checkpoint, "file the population is checkpointed to, empty disables checkpoints")
+	fs.IntVar(&c.CheckpointEvery, "checkpoint-every", c.CheckpointEvery, "generations between checkpoints")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "seed of the run, 0 picks one from the clock, ignored when resuming")
+}
+
+// parseConfig reads the flags, values from the -config file are used unless the flag is also given
+func parseConfig(fs *flag.FlagSet, args []string) (*config, error) {
+	c := defaultConfig()
+	path := fs.String("config", "", "JSON config file, flags override its values")
+	c.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		fileConfig, err := loadConfig(*path)
+		if err != nil {
+			return nil, err
+		}
+
+		fileFlags := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		fileConfig.bind(fileFlags)
+		fs.Visit(func(f *flag.Flag) {
+			if fileFlags.Lookup(f.Name) != nil && err == nil {
+				err = fileFlags.Set(f.Name, f.Value.String())
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		c = fileConfig
+	}
+	return c, c.validate()
+}
+
+// loadConfig reads a config file, settings missing from the file keep their default
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := defaultConfig()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func (c *config) validate() error {
+	switch {
+	case c.Population < 2:
+		return fmt.Errorf("population must be at least 2")
+	case c.Threads < 1:
+		return fmt.Errorf("threads must be at least 1")
+	case c.MoveTime < 0:
+		return fmt.Errorf("move time must not be negative")
+	case c.MoveTime == 0 && c.Opponents.MCTS && c.MCTSRounds < 1:
+		return fmt.Errorf("the MCTS opponent needs rounds without a move time")
+	case c.Depth < 2 || c.Depth > 255:
+		return fmt.Errorf("depth must be within [2, 255], mtd searches up to depth - 1")
+	case c.CheckpointEvery < 1:
+		return fmt.Errorf("checkpoint every must be at least 1")
+	case c.Opponents.HallOfFame < 0 || c.Opponents.Population < 0 || c.Opponents.Openings < 0 || c.Opponents.OpeningPlies < 0:
+		return fmt.Errorf("opponent counts must not be negative")
+	case !c.Opponents.Default && !c.Opponents.MCTS && c.Opponents.HallOfFame == 0 && c.Opponents.Population == 0:
+		return fmt.Errorf("no opponents")
+	case c.Opponents.OpeningPlies > 0 && c.Opponents.Openings == 0:
+		return fmt.Errorf("no openings")
+	case len(c.Mater) == 0:
+		return fmt.Errorf("no mater functions")
+	}
+
+	for _, m := range c.Mater {
+		if _, ok := materFunctions[m.F]; !ok {
+			return fmt.Errorf("unknown mater function %q", m.F)
+		}
+		if m.P < 0 || m.P > 1 {
+			return fmt.Errorf("mater function %s has probability %f outside [0, 1]", m.F, m.P)
+		}
+	}
+	return nil
+}
+
+func (c *config) materFunctions() []goga.MaterFunctionProbability {
+	functions := make([]goga.MaterFunctionProbability, len(c.Mater))
+	for i, m := range c.Mater {
+		functions[i] = goga.MaterFunctionProbability{P: m.P, F: materFunctions[m.F], UseElite: m.UseElite}
+	}
+	return functions
+}
+
+// opponent returns the heuristic the population plays against
+func (c *config) opponent() (*Game.HeuristicScores, error) {
+	if c.Opponent == "" {
+		return Game.DefaultHeuristic(), nil
+	}
+	return Game.LoadHeuristic(c.Opponent, c.OpponentIndex)
+}
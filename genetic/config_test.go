@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExampleConfigIsDefault(t *testing.T) {
+	c, err := loadConfig("config.example.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, defaultConfig()) {
+		t.Fatalf("config.example.json %+v differs from the defaults %+v", c, defaultConfig())
+	}
+}
+
+func TestFlagsOverrideConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tuner.json")
+	data := `{"Population": 50, "MoveTime": "100ms", "Mater": [{"P": 0.5, "F": "OnePointCrossover"}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("genetic", flag.ContinueOnError)
+	c, err := parseConfig(fs, []string{"-config", path, "-population", "30", "-depth", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Population != 30 || c.Depth != 3 {
+		t.Errorf("flags were not applied, population %d depth %d", c.Population, c.Depth)
+	}
+	if time.Duration(c.MoveTime) != time.Millisecond*100 || len(c.Mater) != 1 || c.Mater[0].F != "OnePointCrossover" {
+		t.Errorf("file values were lost, move time %v mater %+v", time.Duration(c.MoveTime), c.Mater)
+	}
+	if c.Threads != defaultConfig().Threads {
+		t.Errorf("threads %d, want the default", c.Threads)
+	}
+}
+
+func TestConfigRejectsInvalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"-population", "1"},
+		{"-move-time", "-1s"},
+		{"-move-time", "0s", "-play-mcts", "-mcts-rounds", "0"},
+		{"-checkpoint-every", "0"},
+	} {
+		fs := flag.NewFlagSet("genetic", flag.ContinueOnError)
+		if _, err := parseConfig(fs, args); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+
+	c := defaultConfig()
+	c.Mater[0].F = "Crossover"
+	if c.validate() == nil {
+		t.Error("unknown mater function was accepted")
+	}
+}
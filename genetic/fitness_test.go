@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/tomcraven/goga"
+)
+
+func TestSimulatePlaysThePool(t *testing.T) {
+	c := defaultConfig()
+	c.MoveTime = duration(time.Millisecond)
+	c.Depth = 2
+	c.Opponents = opponentPool{Default: true, HallOfFame: 1, Openings: 2, OpeningPlies: 2}
+
+	population := []goga.Genome{
+		goga.NewGenome(encodeHeuristic(Game.DefaultHeuristic())),
+		goga.NewGenome(encodeHeuristic(Game.DefaultHeuristic())),
+	}
+	sms := &utttMaterSimulator{config: c, opponent: Game.DefaultHeuristic(), seed: 5}
+
+	sms.OnBeginSimulation()
+	if len(sms.opponents) != 1 || len(sms.openings) != 2 {
+		t.Fatalf("%d opponents and %d openings, want 1 and 2", len(sms.opponents), len(sms.openings))
+	}
+	for _, g := range population {
+		sms.Simulate(g)
+	}
+
+	score := sms.score(population[0])
+	if score.Games() != 4 {
+		t.Fatalf("%d games were played, want 2 openings with both colours", score.Games())
+	}
+	if fitness := population[0].GetFitness(); fitness != int(score.Mean()*1000+0.5) {
+		t.Fatalf("fitness %d does not match the score %v", fitness, score)
+	}
+
+	// The played elite joins the hall of fame, the next generation plays it too
+	population[1].SetFitness(population[0].GetFitness() + 1)
+	sms.addToHallOfFame(population)
+	if len(sms.hallOfFame) != 1 || sms.hallOfFame[0] != newCheckpointGenome(population[1]) {
+		t.Fatalf("hall of fame %v does not hold the elite", sms.hallOfFame)
+	}
+	sms.addToHallOfFame(population)
+	if len(sms.hallOfFame) != 1 {
+		t.Fatalf("hall of fame grew to %d, the limit is 1", len(sms.hallOfFame))
+	}
+}
+
+func TestSimulateWithoutMoveTimeIsReproducible(t *testing.T) {
+	c := defaultConfig()
+	c.MoveTime = 0
+	c.Depth = 3
+	c.MCTSRounds = 300
+	c.Opponents = opponentPool{Default: true, MCTS: true, Openings: 1, OpeningPlies: 3}
+
+	heuristic := Game.DefaultHeuristic()
+	heuristic.WonBoardRating += 2
+	fitness := make([]int, 2)
+	for i := range fitness {
+		Game.Seed(9)
+		sms := &utttMaterSimulator{config: c, opponent: Game.DefaultHeuristic(), seed: 9}
+		sms.OnBeginSimulation()
+
+		g := goga.NewGenome(encodeHeuristic(heuristic))
+		sms.Simulate(g)
+		fitness[i] = g.GetFitness()
+	}
+	if fitness[0] != fitness[1] {
+		t.Fatalf("the same genome scored %d and %d", fitness[0], fitness[1])
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/tuning"
+	"github.com/tomcraven/goga"
+)
+
+// heuristicParam describes how a field of HeuristicScores is stored in the genome,
+// the value is quantised to steps of Resolution within [Min, Max]
+type heuristicParam struct {
+	tuning.Param
+}
+
+// heuristicParams is the genome layout, parameters are stored in the order of tuning.Params
+var heuristicParams = func() []heuristicParam {
+	params := make([]heuristicParam, len(tuning.Params))
+	for i, param := range tuning.Params {
+		params[i] = heuristicParam{param}
+	}
+	return params
+}()
+
+// steps is the largest quantised value of the parameter
+func (p *heuristicParam) steps() uint32 {
+	return uint32(math.Round(p.Range() / p.Resolution))
+}
+
+// bits is the amount of bits needed to store every step of the parameter
+func (p *heuristicParam) bits() int {
+	bits := 1
+	for p.steps()>>bits > 0 {
+		bits++
+	}
+	return bits
+}
+
+func (p *heuristicParam) encode(f float64) goga.Bitset {
+	f = p.Clamp(f)
+	value := uint32(math.Round((f - p.Min) / p.Resolution))
+	b := goga.Bitset{}
+	b.Create(p.bits())
+	for i := 0; i < p.bits(); i++ {
+		if value&(1<<i) > 0 {
+			b.Set(i, 1)
+		}
+	}
+	return b
+}
+
+func (p *heuristicParam) decode(bits goga.Bitset) float64 {
+	value := uint32(0)
+	for i := 0; i < bits.GetSize(); i++ {
+		if bits.Get(i) == 1 {
+			value |= 1 << i
+		}
+	}
+
+	// Mutations can create values past the last step
+	if value > p.steps() {
+		value = p.steps()
+	}
+	return p.Min + float64(value)*p.Resolution
+}
+
+// totalBits is the size of a genome
+func totalBits() int {
+	total := 0
+	for _, param := range heuristicParams {
+		total += param.bits()
+	}
+	return total
+}
+
+func encodeHeuristic(h *Game.HeuristicScores) goga.Bitset {
+	b := goga.Bitset{}
+	b.Create(totalBits())
+
+	offset := 0
+	for _, param := range heuristicParams {
+		bitset := param.encode(*param.Field(h))
+		for x := 0; x < bitset.GetSize(); x++ {
+			b.Set(offset+x, bitset.Get(x))
+		}
+		offset += bitset.GetSize()
+	}
+	return b
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+func assertRoundTrip(t *testing.T, h *Game.HeuristicScores) {
+	bits := encodeHeuristic(h)
+	decoded := GetHeuristic(&bits)
+	for _, param := range heuristicParams {
+		want, got := *param.Field(h), *param.Field(decoded)
+		if math.Abs(want-got) > param.Resolution/2+1e-9 {
+			t.Errorf("%s: encoded %f decoded %f", param.Name, want, got)
+		}
+	}
+	if decoded.BoardRating[0] != decoded.BoardCornerRating || decoded.BoardRating[8] != decoded.BoardMiddleRating || decoded.PosRating[1] != decoded.PosSideRating {
+		t.Fatalf("derived ratings were not rebuilt")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	assertRoundTrip(t, Game.DefaultHeuristic())
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		h := &Game.HeuristicScores{}
+		for _, param := range heuristicParams {
+			*param.Field(h) = param.Min + random.Float64()*(param.Max-param.Min)
+		}
+		assertRoundTrip(t, h)
+	}
+}
+
+func TestDecodeClampsToRange(t *testing.T) {
+	bits := encodeHeuristic(&Game.HeuristicScores{})
+	bits.SetAll(1)
+	h := GetHeuristic(&bits)
+	for _, param := range heuristicParams {
+		if got := *param.Field(h); got > param.Max+1e-9 {
+			t.Errorf("%s decoded to %f above its maximum %f", param.Name, got, param.Max)
+		}
+	}
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/FabianPetersen/UltimateTicTacToe/Game"
-	"github.com/FabianPetersen/UltimateTicTacToe/mtd"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"log"
+	"math"
+	"math/rand"
 	"os"
 	"runtime"
 	"sync"
@@ -13,250 +18,256 @@ import (
 	"github.com/tomcraven/goga"
 )
 
-const totalParams = 22
-const bitsPerParam = 12
-const bitsPerParamSmall = 8
-const divisionPerParam = 100
-const population = 200
-
-var bbitsPerParam = []int{
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-	bitsPerParam,
-	bitsPerParam,
-	bitsPerParam,
-	bitsPerParam,
-	bitsPerParam,
-	bitsPerParam,
-	bitsPerParam,
-	bitsPerParam,
-	bitsPerParamSmall,
-	bitsPerParam,
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-	bitsPerParamSmall,
-}
-var bbitsPerParamOffset = []int{}
 var genAlgo = goga.NewGeneticAlgorithm()
-var oppHeuristic = Game.DefaultHeuristic()
 
-func getFloat64(bits goga.Bitset) float64 {
-	value := uint32(0)
-	for i := 0; i < bits.GetSize(); i++ {
-		if bits.Get(i) == 1 {
-			value |= 1 << i
-		}
-	}
-	return float64(value) / divisionPerParam
+func newBot(c *config, heuristic *Game.HeuristicScores) *match.Bot {
+	return &match.Bot{Heuristic: heuristic, Depth: byte(c.Depth), MoveTime: time.Duration(c.MoveTime)}
 }
 
-func getBits(f float64) goga.Bitset {
-	value := uint32(f * divisionPerParam)
-	b := goga.Bitset{}
-	b.Create(bitsPerParam)
-	for i := 0; i < bitsPerParam; i++ {
-		if value&(1<<i) > 0 {
-			b.Set(i, 1)
-		}
-	}
-	return b
-}
-
-type utttHeuristicBitsetCreate struct{}
-
-func (bc *utttHeuristicBitsetCreate) Go() goga.Bitset {
-	b := goga.Bitset{}
-	b.Create(totalParams * bitsPerParam)
-
-	// Convert heuristic object to bitset
-	h := Game.DefaultHeuristic()
-	params := []float64{
-		h.BoardCornerRating,
-		h.BoardSideRating,
-		h.BoardMiddleRating,
-		h.PosCornerRating,
-		h.PosSideRating,
-		h.PosMiddleRating,
-		h.OverallWinLossRating,
-		h.OverallAlmostDrawWinLossRating,
-		h.GlobalStateRating,
-		h.OverallBoardMultiplierRating,
-		h.WinMovesMadeLossRating,
-		h.LossMovesMadeAdvantageRating,
-		h.TwoInARowAdvantageRating,
-		h.EnemyTwoInARowLossRating,
-		h.EnemyWonBoardLossRating,
-		h.EnemyWonBoardDiscountRating,
-		h.WonBoardRating,
-		h.DrawBoardScoreEnemyDiscountRating,
-		h.DrawBoardScorePlayerDiscountRating,
-		h.LocalBoardWinPlayedMovesDiscountRating,
-		h.OverallBoardWinPlayedMovesDiscountRating,
-	}
-
-	for i, param := range params {
-		bitset := getBits(param)
-		for x := 0; x < bitset.GetSize(); x++ {
-			b.Set(bbitsPerParamOffset[i]+x, bitset.Get(x))
-		}
+func GetHeuristic(bits *goga.Bitset) *Game.HeuristicScores {
+	h := Game.HeuristicScores{}
+	offset := 0
+	for _, param := range heuristicParams {
+		*param.Field(&h) = param.decode(bits.Slice(offset, param.bits()))
+		offset += param.bits()
 	}
 
-	return b
+	h.Normalise()
+	return &h
 }
 
-func GetHeuristic(bits *goga.Bitset) *Game.HeuristicScores {
-	if len(bbitsPerParamOffset) == 0 {
-		offset := 0
-		for _, i2 := range bbitsPerParam {
-			bbitsPerParamOffset = append(bbitsPerParamOffset, offset)
-			offset += i2
-		}
-	}
+// utttHeuristicBitsetCreate starts from the default heuristic, or from the population of a checkpoint when resuming
+type utttHeuristicBitsetCreate struct {
+	resume []goga.Bitset
+}
 
-	h := Game.HeuristicScores{
-		BoardCornerRating:                        getFloat64(bits.Slice(bbitsPerParamOffset[0], bbitsPerParam[0])),
-		BoardSideRating:                          getFloat64(bits.Slice(bbitsPerParamOffset[1], bbitsPerParam[1])),
-		BoardMiddleRating:                        getFloat64(bits.Slice(bbitsPerParamOffset[2], bbitsPerParam[2])),
-		PosCornerRating:                          getFloat64(bits.Slice(bbitsPerParamOffset[3], bbitsPerParam[3])),
-		PosSideRating:                            getFloat64(bits.Slice(bbitsPerParamOffset[4], bbitsPerParam[4])),
-		PosMiddleRating:                          getFloat64(bits.Slice(bbitsPerParamOffset[5], bbitsPerParam[5])),
-		OverallWinLossRating:                     getFloat64(bits.Slice(bbitsPerParamOffset[6], bbitsPerParam[6])),
-		OverallAlmostDrawWinLossRating:           getFloat64(bits.Slice(bbitsPerParamOffset[7], bbitsPerParam[7])),
-		GlobalStateRating:                        getFloat64(bits.Slice(bbitsPerParamOffset[8], bbitsPerParam[8])),
-		OverallBoardMultiplierRating:             getFloat64(bits.Slice(bbitsPerParamOffset[9], bbitsPerParam[9])),
-		WinMovesMadeLossRating:                   getFloat64(bits.Slice(bbitsPerParamOffset[11], bbitsPerParam[11])),
-		LossMovesMadeAdvantageRating:             getFloat64(bits.Slice(bbitsPerParamOffset[12], bbitsPerParam[12])),
-		TwoInARowAdvantageRating:                 getFloat64(bits.Slice(bbitsPerParamOffset[13], bbitsPerParam[13])),
-		EnemyTwoInARowLossRating:                 getFloat64(bits.Slice(bbitsPerParamOffset[14], bbitsPerParam[14])),
-		EnemyWonBoardLossRating:                  getFloat64(bits.Slice(bbitsPerParamOffset[15], bbitsPerParam[15])),
-		EnemyWonBoardDiscountRating:              getFloat64(bits.Slice(bbitsPerParamOffset[16], bbitsPerParam[16])),
-		WonBoardRating:                           getFloat64(bits.Slice(bbitsPerParamOffset[17], bbitsPerParam[17])),
-		DrawBoardScoreEnemyDiscountRating:        getFloat64(bits.Slice(bbitsPerParamOffset[18], bbitsPerParam[18])),
-		DrawBoardScorePlayerDiscountRating:       getFloat64(bits.Slice(bbitsPerParamOffset[19], bbitsPerParam[19])),
-		LocalBoardWinPlayedMovesDiscountRating:   getFloat64(bits.Slice(bbitsPerParamOffset[20], bbitsPerParam[20])),
-		OverallBoardWinPlayedMovesDiscountRating: getFloat64(bits.Slice(bbitsPerParamOffset[21], bbitsPerParam[21])),
+func (bc *utttHeuristicBitsetCreate) Go() goga.Bitset {
+	if len(bc.resume) > 0 {
+		bits := bc.resume[0]
+		bc.resume = bc.resume[1:]
+		return bits
 	}
-	h.BoardRating = [9]float64{h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardCornerRating, h.BoardSideRating, h.BoardMiddleRating}
-	h.PosRating = [9]float64{h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosCornerRating, h.PosSideRating, h.PosMiddleRating}
-	return &h
+	return encodeHeuristic(Game.DefaultHeuristic())
 }
 
 type utttMaterSimulator struct {
-	pop    []goga.Genome
-	scores sync.Map
+	config     *config
+	opponent   *Game.HeuristicScores
+	seed       int64
+	generation int
+
+	// hallOfFame holds the elites of earlier generations, the newest last
+	hallOfFame []checkpointGenome
+
+	pop       []goga.Genome
+	opponents []*match.Bot
+	openings  [][]match.Move
+	scores    sync.Map
 }
 
+// OnBeginSimulation picks the opponents and openings of the generation, all genomes play the same games.
+// It runs before the next generation is mated, the population is still the one that was played last
 func (sms *utttMaterSimulator) OnBeginSimulation() {
+	sms.generation++
+	rng := rand.New(rand.NewSource(sms.seed + int64(sms.generation)))
+
 	sms.pop = genAlgo.GetPopulation()
+	sms.addToHallOfFame(sms.pop)
 	sms.scores.Range(func(key, value any) bool {
 		sms.scores.Delete(key)
 		return true
 	})
-}
-func (sms *utttMaterSimulator) OnEndSimulation() {}
-func (sms *utttMaterSimulator) ExitFunc(g goga.Genome) bool {
-	return false
+	sms.opponents = sms.pickOpponents(rng)
+
+	sms.openings = [][]match.Move{nil}
+	if sms.config.Opponents.OpeningPlies > 0 {
+		sms.openings = make([][]match.Move, sms.config.Opponents.Openings)
+		for i := range sms.openings {
+			sms.openings[i] = match.RandomOpening(rng, sms.config.Opponents.OpeningPlies)
+		}
+	}
 }
 
-func (sms *utttMaterSimulator) Simulate(g goga.Genome) {
-	playerH := GetHeuristic(g.GetBits())
-	winner1, movesMade1 := sms.sim(playerH, oppHeuristic)
-	winner2, movesMade2 := sms.sim(oppHeuristic, playerH)
-
-	var fitness uint32 = 0
-	if winner1 == Game.Player1 {
-		fitness += 400 - movesMade1
-	} else {
-		fitness += movesMade1
+func (sms *utttMaterSimulator) pickOpponents(rng *rand.Rand) []*match.Bot {
+	pool := sms.config.Opponents
+	var opponents []*match.Bot
+	if pool.Default {
+		opponents = append(opponents, newBot(sms.config, sms.opponent))
 	}
 
-	if winner2 == Game.Player2 {
-		fitness += 400 - movesMade2
-	} else {
-		fitness += movesMade2
+	hallOfFame := sms.hallOfFame
+	if len(hallOfFame) > pool.HallOfFame {
+		hallOfFame = hallOfFame[len(hallOfFame)-pool.HallOfFame:]
+	}
+	for _, elite := range hallOfFame {
+		bits, err := elite.bitset()
+		if err != nil {
+			continue
+		}
+		opponents = append(opponents, newBot(sms.config, GetHeuristic(&bits)))
 	}
 
-	g.SetFitness(int(fitness))
-}
+	members := rng.Perm(len(sms.pop))
+	if len(members) > pool.Population {
+		members = members[:pool.Population]
+	}
+	for _, i := range members {
+		opponents = append(opponents, newBot(sms.config, GetHeuristic(sms.pop[i].GetBits())))
+	}
 
-func (sms *utttMaterSimulator) sim(p1 *Game.HeuristicScores, p2 *Game.HeuristicScores) (Game.Player, uint32) {
-	playerGame := Game.NewGame()
-	playerGame.HeuristicScores = p1
+	if pool.MCTS {
+		mctsConfig := gmcts.DefaultConfig()
+		opponents = append(opponents, &match.Bot{MCTS: &mctsConfig, Rounds: sms.config.MCTSRounds, MoveTime: time.Duration(sms.config.MoveTime)})
+	}
 
-	enemyGame := Game.NewGame()
-	enemyGame.HeuristicScores = p2
+	// The hall of fame is empty in the first generation
+	if len(opponents) == 0 {
+		opponents = append(opponents, newBot(sms.config, sms.opponent))
+	}
+	return opponents
+}
 
-	for !playerGame.IsTerminal() {
-		// Player move
-		move, board := mtd.IterativeDeepeningTime(playerGame, 5, time.Millisecond*250)
-		playerGame.MakeMove(board, move)
-		enemyGame.MakeMove(board, move)
+func (sms *utttMaterSimulator) OnEndSimulation() {}
 
-		if playerGame.IsTerminal() {
-			break
+// addToHallOfFame adds the elite of the population if it was played, a new or resumed population has no scores yet
+func (sms *utttMaterSimulator) addToHallOfFame(population []goga.Genome) {
+	var elite goga.Genome
+	for _, g := range population {
+		if _, played := sms.scores.Load(g); played && (elite == nil || g.GetFitness() > elite.GetFitness()) {
+			elite = g
 		}
+	}
+	if elite == nil || sms.config.Opponents.HallOfFame == 0 {
+		return
+	}
+
+	sms.hallOfFame = append(sms.hallOfFame, newCheckpointGenome(elite))
+	if len(sms.hallOfFame) > sms.config.Opponents.HallOfFame {
+		sms.hallOfFame = sms.hallOfFame[len(sms.hallOfFame)-sms.config.Opponents.HallOfFame:]
+	}
+}
+
+func (sms *utttMaterSimulator) ExitFunc(g goga.Genome) bool {
+	return false
+}
 
-		// Enemy move
-		move, board = mtd.IterativeDeepeningTime(enemyGame, 5, time.Millisecond*250)
-		playerGame.MakeMove(board, move)
-		enemyGame.MakeMove(board, move)
+// Simulate plays every opening against every opponent with both colours, the fitness is the mean score in thousandths
+func (sms *utttMaterSimulator) Simulate(g goga.Genome) {
+	playerBot := newBot(sms.config, GetHeuristic(g.GetBits()))
+	score := match.Score{}
+	for _, opponent := range sms.opponents {
+		// goga already simulates the genomes in parallel
+		score.Merge(match.PlayOpenings(playerBot, opponent, sms.openings, 1))
 	}
 
-	return playerGame.WinningPlayer(), playerGame.MovesMade()
+	sms.scores.Store(g, score)
+	g.SetFitness(int(math.Round(score.Mean() * 1000)))
+}
+
+// score returns the games behind the fitness of a genome of the last generation
+func (sms *utttMaterSimulator) score(g goga.Genome) match.Score {
+	score, _ := sms.scores.Load(g)
+	s, _ := score.(match.Score)
+	return s
 }
 
 type utttEliteConsumer struct {
+	simulator   *utttMaterSimulator
 	currentIter int
+	seed        int64
+	best        checkpointGenome
+
+	output          string
+	checkpointPath  string
+	checkpointEvery int
 }
 
 func (ec *utttEliteConsumer) OnElite(g goga.Genome) {
-	data, _ := json.Marshal(GetHeuristic(g.GetBits()))
+	data, _ := json.Marshal(Game.Elite{Fitness: g.GetFitness(), Heuristic: GetHeuristic(g.GetBits())})
 	ec.currentIter++
-	fmt.Println(ec.currentIter, "\t", g.GetFitness())
+	fmt.Println(ec.currentIter, "\t", g.GetFitness(), "\t", ec.simulator.score(g))
+
+	if g.GetFitness() > ec.best.Fitness || ec.best.Bits == "" {
+		ec.best = newCheckpointGenome(g)
+	}
+
+	if ec.checkpointPath != "" && ec.currentIter%ec.checkpointEvery == 0 {
+		c := newCheckpoint(ec.currentIter, ec.seed, ec.best, genAlgo.GetPopulation())
+		c.HallOfFame = ec.simulator.hallOfFame
+		if err := c.save(ec.checkpointPath); err != nil {
+			log.Println("checkpoint:", err)
+		}
+	}
+
+	// The mating of the next generation follows, a resumed run seeds it the same way
+	rand.Seed(ec.seed + int64(ec.currentIter))
 
 	//if g.GetFitness() > 745 {
-	//	oppHeuristic = GetHeuristic(g.GetBits())
+	//	oppBot.heuristic = GetHeuristic(g.GetBits())
 	//}
 
-	f, _ := os.OpenFile("elitePop.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	f, _ := os.OpenFile(ec.output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	_, _ = f.Write(data)
 	_, _ = f.WriteString("\n")
 	_ = f.Close()
 }
 
 func main() {
-	numThreads := 16
-	runtime.GOMAXPROCS(numThreads)
-
-	genAlgo.Simulator = &utttMaterSimulator{}
-	genAlgo.BitsetCreate = &utttHeuristicBitsetCreate{}
-	genAlgo.EliteConsumer = &utttEliteConsumer{}
-	genAlgo.Mater = goga.NewMater(
-		[]goga.MaterFunctionProbability{
-			{P: 1.0, F: goga.UniformCrossover, UseElite: true},
-			{P: 1.0, F: goga.TwoPointCrossover, UseElite: true},
-			{P: 0.8, F: goga.Mutate},
-			{P: 1.0, F: goga.Mutate},
-			{P: 1.0, F: goga.Mutate},
-			{P: 0.7, F: goga.Mutate},
-			{P: 1.0, F: goga.Mutate},
-			{P: 1.0, F: goga.Mutate},
-			{P: 1.0, F: goga.Mutate},
-			{P: 1.0, F: goga.Mutate},
-		},
-	)
+	resume := flag.Bool("resume", false, "continue from the checkpoint instead of a fresh population")
+	c, err := parseConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+
+	opponent, err := c.opponent()
+	if err != nil {
+		log.Fatalln("opponent:", err)
+	}
+
+	runtime.GOMAXPROCS(c.Threads)
+
+	bitsetCreate := &utttHeuristicBitsetCreate{}
+	simulator := &utttMaterSimulator{config: c, opponent: opponent, seed: c.Seed}
+	eliteConsumer := &utttEliteConsumer{simulator: simulator, seed: c.Seed, output: c.Output, checkpointPath: c.Checkpoint, checkpointEvery: c.CheckpointEvery}
+	if *resume {
+		checkpoint, err := loadCheckpoint(c.Checkpoint)
+		if err != nil {
+			log.Fatalln("resume:", err)
+		}
+		if bitsetCreate.resume, err = checkpoint.genomes(); err != nil {
+			log.Fatalln("resume:", err)
+		}
+		if len(bitsetCreate.resume) != c.Population {
+			log.Fatalf("resume: checkpoint has %d genomes, want %d\n", len(bitsetCreate.resume), c.Population)
+		}
+
+		// The checkpointed generation is simulated again, its fitness is not part of the genomes
+		eliteConsumer.seed = checkpoint.Seed
+		eliteConsumer.currentIter = checkpoint.Generation - 1
+		eliteConsumer.best = checkpoint.Best
+		simulator.seed = checkpoint.Seed
+		simulator.generation = checkpoint.Generation - 1
+		simulator.hallOfFame = checkpoint.HallOfFame
+		fmt.Println("resuming after generation", checkpoint.Generation, "best fitness", checkpoint.Best.Fitness)
+	}
+	// Every random choice of the run follows from its seed
+	rand.Seed(eliteConsumer.seed)
+	Game.Seed(eliteConsumer.seed)
+
+	genAlgo.Simulator = simulator
+	genAlgo.BitsetCreate = bitsetCreate
+	genAlgo.EliteConsumer = eliteConsumer
+	genAlgo.Mater = goga.NewMater(c.materFunctions())
 	genAlgo.Selector = goga.NewSelector(
 		[]goga.SelectorFunctionProbability{
 			{P: 1.0, F: goga.Roulette},
 		},
 	)
 
-	genAlgo.Init(population, numThreads)
+	genAlgo.Init(c.Population, c.Threads)
 
 	startTime := time.Now()
 	genAlgo.Simulate()
@@ -0,0 +1,291 @@
+package gmcts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+type ProvenStatus string
+
+const (
+	UNPROVEN    ProvenStatus = ""
+	PROVEN_WIN  ProvenStatus = "win"
+	PROVEN_LOSS ProvenStatus = "loss"
+	PROVEN_DRAW ProvenStatus = "draw"
+)
+
+// ExportOptions limits the exported part of the search
+type ExportOptions struct {
+	// MaxDepth is the amount of levels below the root that are exported
+	MaxDepth int
+
+	// MinVisits skips children that were chosen less often than this
+	MinVisits uint32
+}
+
+// ExportNode is a snapshot of a node, the statistics are for the player who made the move leading to it.
+// The root has no move, its Board and Move are 255
+type ExportNode struct {
+	Board    byte          `json:"board"`
+	Move     byte          `json:"move"`
+	Visits   uint32        `json:"visits"`
+	WinRate  float32       `json:"winRate"`
+	UCT      float32       `json:"uct"`
+	Proven   ProvenStatus  `json:"proven,omitempty"`
+	Children []*ExportNode `json:"children,omitempty"`
+}
+
+// exportEdge is a move in either the tree or the DAG
+type exportEdge struct {
+	board  byte
+	move   byte
+	visits uint32
+	child  exportable
+}
+
+type exportable interface {
+	stats() (visits uint32, exploit float32, meanSquares float32)
+	exportEdges() []exportEdge
+	solvedStatus() byte
+}
+
+func (n *Node) solvedStatus() byte {
+	return n.solved
+}
+
+func (n *dagNode) solvedStatus() byte {
+	return n.solved
+}
+
+func (n *Node) stats() (uint32, float32, float32) {
+	if n.nodeVisits == 0 {
+		return 0, 0, 0
+	}
+	return n.nodeVisits, n.exploit(), n.meanSquares()
+}
+
+func (n *Node) exportEdges() []exportEdge {
+	edges := make([]exportEdge, n.childrenCount)
+	for i := byte(0); i < n.childrenCount; i++ {
+		edges[i] = exportEdge{board: n.children[i].board, move: n.children[i].move, visits: n.children[i].nodeVisits, child: n.children[i]}
+	}
+	return edges
+}
+
+func (n *dagNode) stats() (uint32, float32, float32) {
+	if n.nodeVisits == 0 {
+		return 0, 0, 0
+	}
+	return n.nodeVisits, n.value, n.meanSquares()
+}
+
+func (n *dagNode) exportEdges() []exportEdge {
+	edges := make([]exportEdge, len(n.edges))
+	for i, edge := range n.edges {
+		edges[i] = exportEdge{board: edge.board, move: edge.move, visits: edge.visits, child: edge.child}
+	}
+	return edges
+}
+
+type exporter struct {
+	config  *MCTSConfig
+	options ExportOptions
+	proven  map[exportable]ProvenStatus
+}
+
+// Export returns the top levels of the search, positions shared in the DAG are repeated for every path
+func (t *MCTS) Export(options ExportOptions) *ExportNode {
+	e := exporter{config: &t.config, options: options, proven: map[exportable]ProvenStatus{}}
+	state := t.game.Copy()
+
+	var root exportable = t.root
+	if t.dag != nil {
+		root = t.dag.root
+	}
+	visits, exploit, _ := root.stats()
+	return &ExportNode{
+		Board:    255,
+		Move:     255,
+		Visits:   visits,
+		WinRate:  exploit,
+		Proven:   e.provenStatus(root, &state),
+		Children: e.children(root, &state, 1),
+	}
+}
+
+// PrincipalVariation starts with the best action and follows the most visited moves after it, at most length moves.
+// The win rate is the one of the best action for the player to move
+func (t *MCTS) PrincipalVariation(length int) (boards []byte, moves []byte, winRate float32) {
+	var node exportable = t.root
+	if t.dag != nil {
+		node = t.dag.root
+	}
+
+	move, board := t.BestAction()
+	for len(moves) < length {
+		var next *exportEdge
+		edges := node.exportEdges()
+		for i, edge := range edges {
+			if len(moves) == 0 && edge.board == board && edge.move == move {
+				next = &edges[i]
+				break
+			}
+			if len(moves) > 0 && edge.visits > 0 && (next == nil || edge.visits > next.visits) {
+				next = &edges[i]
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		if len(moves) == 0 {
+			_, winRate, _ = next.child.stats()
+		}
+		boards = append(boards, next.board)
+		moves = append(moves, next.move)
+		node = next.child
+	}
+	return boards, moves, winRate
+}
+
+func (e *exporter) children(parent exportable, state *Game.Game, depth int) []*ExportNode {
+	if depth > e.options.MaxDepth {
+		return nil
+	}
+
+	parentVisits, _, _ := parent.stats()
+	edges := parent.exportEdges()
+	children := make([]*ExportNode, 0, len(edges))
+	for _, edge := range edges {
+		if edge.visits < e.options.MinVisits {
+			continue
+		}
+
+		prevBoard := byte(state.Board[Game.PlayerBoardIndex] >> 1)
+		state.MakeMove(edge.board, edge.move)
+		_, exploit, meanSquares := edge.child.stats()
+		children = append(children, &ExportNode{
+			Board:    edge.board,
+			Move:     edge.move,
+			Visits:   edge.visits,
+			WinRate:  exploit,
+			UCT:      e.config.selectionValue(parentVisits, edge.visits, exploit, meanSquares, byte(len(edges))),
+			Proven:   e.provenStatus(edge.child, state),
+			Children: e.children(edge.child, state, depth+1),
+		})
+		state.UnMakeMove(edge.move, edge.board, prevBoard)
+	}
+	return children
+}
+
+// provenStatus solves the node from the terminal positions in the searched part of its subtree
+func (e *exporter) provenStatus(node exportable, state *Game.Game) ProvenStatus {
+	if status, ok := e.proven[node]; ok {
+		return status
+	}
+
+	status := UNPROVEN
+	mover := Game.Player(state.Board[Game.PlayerBoardIndex]&0x1) ^ 0x1
+	edges := node.exportEdges()
+	if state.IsTerminal() {
+		switch state.WinningPlayer() {
+		case mover:
+			status = PROVEN_WIN
+		case Game.Draw:
+			status = PROVEN_DRAW
+		default:
+			status = PROVEN_LOSS
+		}
+	} else if node.solvedStatus() == SOLVED_WIN {
+		status = PROVEN_WIN
+	} else if node.solvedStatus() == SOLVED_LOSS {
+		status = PROVEN_LOSS
+	} else if len(edges) > 0 {
+		// The children are proven for the player to move, one win is enough, otherwise all must be known
+		allProven, anyDraw := len(edges) == int(state.Len()), false
+		for _, edge := range edges {
+			prevBoard := byte(state.Board[Game.PlayerBoardIndex] >> 1)
+			state.MakeMove(edge.board, edge.move)
+			childStatus := e.provenStatus(edge.child, state)
+			state.UnMakeMove(edge.move, edge.board, prevBoard)
+
+			if childStatus == PROVEN_WIN {
+				status = PROVEN_LOSS
+				break
+			}
+			allProven = allProven && childStatus != UNPROVEN
+			anyDraw = anyDraw || childStatus == PROVEN_DRAW
+		}
+
+		if status == UNPROVEN && allProven {
+			status = PROVEN_WIN
+			if anyDraw {
+				status = PROVEN_DRAW
+			}
+		}
+	}
+
+	e.proven[node] = status
+	return status
+}
+
+// WriteJSON writes the exported tree as indented JSON
+func (n *ExportNode) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(n)
+}
+
+var provenColors = map[ProvenStatus]string{
+	PROVEN_WIN:  "palegreen",
+	PROVEN_LOSS: "lightpink",
+	PROVEN_DRAW: "lightgrey",
+}
+
+// WriteDOT writes the exported tree as a Graphviz digraph
+func (n *ExportNode) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph mcts {\n\tnode [shape=box, style=filled, fillcolor=white, fontname=monospace];"); err != nil {
+		return err
+	}
+
+	id := 0
+	if err := n.writeDOT(w, &id); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func (n *ExportNode) writeDOT(w io.Writer, id *int) error {
+	nodeId := *id
+	label := "root"
+	if n.Board != 255 {
+		label = fmt.Sprintf("board %d move %d\\nuct %.3f", n.Board, n.Move, n.UCT)
+	}
+	label += fmt.Sprintf("\\nvisits %d\\nwin %.1f%%", n.Visits, n.WinRate*100)
+
+	fillColor := "white"
+	if n.Proven != UNPROVEN {
+		label += "\\n" + string(n.Proven)
+		fillColor = provenColors[n.Proven]
+	}
+
+	if _, err := fmt.Fprintf(w, "\tn%d [label=\"%s\", fillcolor=%s];\n", nodeId, label, fillColor); err != nil {
+		return err
+	}
+
+	for _, child := range n.Children {
+		*id++
+		if _, err := fmt.Fprintf(w, "\tn%d -> n%d;\n", nodeId, *id); err != nil {
+			return err
+		}
+		if err := child.writeDOT(w, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
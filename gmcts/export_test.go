@@ -0,0 +1,87 @@
+package gmcts
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+// winInOne returns a position where player 1 wins the game by playing move 2 in board 2
+func winInOne() *Game.Game {
+	game := Game.NewGame()
+	game.Board[0] = 0x7 | 0x18<<9
+	game.Board[1] = 0x7 | 0x18<<9
+	game.Board[2] = 0x3 | 0x30<<9
+	game.OverallBoard = 0x3
+	game.Board[Game.PlayerBoardIndex] = 2 << 1
+	return game
+}
+
+func TestExportProvenWin(t *testing.T) {
+	for _, transpositions := range []bool{false, true} {
+		config := DefaultConfig()
+		config.Transpositions = transpositions
+		mcts := NewMCTS(winInOne(), config)
+		mcts.SearchRounds(3000)
+
+		root := mcts.Export(ExportOptions{MaxDepth: 2})
+		if root.Proven != PROVEN_LOSS {
+			t.Errorf("transpositions %t: root proven %q, want %q", transpositions, root.Proven, PROVEN_LOSS)
+		}
+
+		var winning *ExportNode
+		for _, child := range root.Children {
+			if child.Board == 2 && child.Move == 2 {
+				winning = child
+			}
+		}
+		if winning == nil || winning.Proven != PROVEN_WIN {
+			t.Fatalf("transpositions %t: winning move not exported as proven win: %+v", transpositions, winning)
+		}
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	game := Game.NewGame()
+	game.MakeMove(8, 8)
+	mcts := NewMCTS(game, DefaultConfig())
+	mcts.SearchRounds(5000)
+
+	root := mcts.Export(ExportOptions{MaxDepth: 2, MinVisits: 50})
+	if root.Visits != 5000 || len(root.Children) == 0 {
+		t.Fatalf("root has %d visits and %d children", root.Visits, len(root.Children))
+	}
+	for _, child := range root.Children {
+		if child.Visits < 50 {
+			t.Errorf("child with %d visits exported below the threshold", child.Visits)
+		}
+		for _, grandChild := range child.Children {
+			if len(grandChild.Children) != 0 {
+				t.Errorf("exported deeper than two levels")
+			}
+		}
+	}
+
+	var jsonOut bytes.Buffer
+	if err := root.WriteJSON(&jsonOut); err != nil {
+		t.Fatal(err)
+	}
+	var decoded ExportNode
+	if err := json.Unmarshal(jsonOut.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Visits != root.Visits || len(decoded.Children) != len(root.Children) {
+		t.Fatalf("JSON round trip lost nodes")
+	}
+
+	var dotOut bytes.Buffer
+	if err := root.WriteDOT(&dotOut); err != nil {
+		t.Fatal(err)
+	}
+	if dot := dotOut.String(); !strings.HasPrefix(dot, "digraph mcts {") || !strings.Contains(dot, "n0 -> n1;") {
+		t.Fatalf("unexpected DOT output:\n%s", dot)
+	}
+}
@@ -0,0 +1,57 @@
+package gmcts
+
+import (
+	"math"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+)
+
+// solverHeuristic only scores finished games, so a minimax value of 1 is a forced win
+// and every other value is unknown
+var solverHeuristic = &Game.HeuristicScores{
+	OverallWinLossRating:           1,
+	OverallAlmostDrawWinLossRating: 1,
+}
+
+var noTimeLimit = time.Duration(math.MaxInt64)
+
+// solver runs the shallow searches of the hybrid MCTS on its own copy of the game
+type solver struct {
+	game   Game.Game
+	depth  byte
+	budget *minimax.Budget
+}
+
+func newSolver(depth byte) solver {
+	return solver{depth: depth, budget: minimax.NewBudget(noTimeLimit)}
+}
+
+// within ends the shallow searches with the search of budget, nil lets them run to their depth
+func (s *solver) within(budget *minimax.Budget) {
+	if budget == nil {
+		s.budget = minimax.NewBudget(noTimeLimit)
+		return
+	}
+	s.budget = minimax.NewInnerBudget(budget)
+}
+
+// solve checks the position with a shallow alpha-beta search without a transposition table,
+// the result is for the player who made the move leading to it. A search cut short by the budget solves nothing
+func (s *solver) solve(state *Game.Game) byte {
+	s.game.Board = state.Board
+	s.game.OverallBoard = state.OverallBoard
+	s.game.HeuristicScores = solverHeuristic
+
+	toMove := Game.Player(state.Board[Game.PlayerBoardIndex] & 0x1)
+	if value, _, _ := minimax.Search(nil, &s.game, -2, 2, s.depth, toMove, s.budget); value >= 1 && !s.budget.Exhausted() {
+		return SOLVED_LOSS
+	}
+
+	// A loss for the player to move can also be a draw, so the mover has to prove the win
+	if value, _, _ := minimax.Search(nil, &s.game, -2, 2, s.depth, toMove^0x1, s.budget); value >= 1 && !s.budget.Exhausted() {
+		return SOLVED_WIN
+	}
+	return UNSOLVED
+}
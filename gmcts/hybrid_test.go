@@ -0,0 +1,49 @@
+package gmcts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+)
+
+func TestSolverFindsWinInOne(t *testing.T) {
+	s := newSolver(2)
+	if solved := s.solve(winInOne()); solved != SOLVED_LOSS {
+		t.Fatalf("position is lost for the player who moved into it, got %d", solved)
+	}
+
+	game := winInOne()
+	game.MakeMove(2, 2)
+	if solved := s.solve(game); solved != SOLVED_WIN {
+		t.Fatalf("finished game won by the mover, got %d", solved)
+	}
+}
+
+func TestHybridPlaysWinningMove(t *testing.T) {
+	for _, transpositions := range []bool{false, true} {
+		config := DefaultConfig()
+		config.MinimaxDepth = 2
+		config.Transpositions = transpositions
+		mcts := NewMCTS(winInOne(), config)
+		mcts.SearchRounds(300)
+
+		if move, board := mcts.BestAction(); board != 2 || move != 2 {
+			t.Errorf("transpositions %t: played move %d in board %d instead of the win", transpositions, move, board)
+		}
+	}
+}
+
+func TestHybridEndsWithBudget(t *testing.T) {
+	config := DefaultConfig()
+	config.MinimaxDepth = 20
+	mcts := NewMCTS(Game.NewGame(), config)
+	defer mcts.Close()
+
+	start := time.Now()
+	mcts.SearchBudget(minimax.NewBudget(50 * time.Millisecond))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("a search of 50ms ran %s", elapsed)
+	}
+}
@@ -3,132 +3,339 @@ package gmcts
 import (
 	"fmt"
 	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+	"math"
 	"os"
+	"sync"
 	"time"
 )
 
-const bestActionPolicy = ROBUST_CHILD
+// poolSize bounds the nodes of a search, a full pool stops growing the tree
+const poolSize = 700000
 
-var nodePool = [700000]Node{}
-var NodePoolIndex = 1
+// nodePool holds the nodes of a search and the children of the nodes, a growing tree allocates nothing
+type nodePool struct {
+	nodes [poolSize]Node
+	links [poolSize]*Node
+}
+
+// nodePools are reused between searches, a pool is too large to allocate for every move
+var nodePools = sync.Pool{New: func() any { return new(nodePool) }}
 
-// MCTS contains functionality for the MCTS algorithm
+// MCTS contains functionality for the MCTS algorithm, every search keeps its own nodes and
+// random playouts so searches can run in parallel
 type MCTS struct {
-	game     *Game.Game
-	gameCopy *Game.Game
-	root     *Node
+	game      *Game.Game
+	gameCopy  Game.Game
+	root      *Node
+	pool      *nodePool
+	poolIndex int
+	rng       *Game.Xorshift
+	dag       *dag
+	solver    solver
+	config    MCTSConfig
+}
+
+// NewMCTS returns a new MCTS wrapper, Close returns its nodes once the search is no longer used.
+// The search keeps its own copy of the position
+func NewMCTS(initial *Game.Game, config MCTSConfig) *MCTS {
+	state := initial.Copy()
+	state.HeuristicScores = initial.HeuristicScores
+	m := &MCTS{
+		game:   &state,
+		rng:    Game.NewXorshift(),
+		solver: newSolver(config.MinimaxDepth),
+		config: config,
+	}
+	m.reset()
+	return m
+}
+
+// reset starts a new tree at the position
+func (m *MCTS) reset() {
+	if m.pool == nil {
+		m.pool = nodePools.Get().(*nodePool)
+	}
+	m.root, m.poolIndex = &m.pool.nodes[0], 1
+	m.root.clear()
+	if m.config.Transpositions {
+		m.dag = newDag(m.game)
+	}
 }
 
-var ggCopy Game.Game
+func (n *Node) clear() {
+	n.parent = nil
+	n.nodeVisits = 0
+	n.nodeScore = 0
+	n.nodeSquares = 0
+	n.childrenCount = 0
+	n.solved = UNSOLVED
+}
+
+// Advance plays the move at the root, the statistics below the move are kept for the next search. Searching the
+// position before the opponent moves and advancing by the move that was played continues with the work of every
+// reply. A move that was never searched starts a new tree
+func (m *MCTS) Advance(board byte, move byte) {
+	m.game.MakeMove(board, move)
+	if m.dag != nil {
+		if !m.dag.advance(board, move) {
+			m.reset()
+		}
+		return
+	}
+
+	// The nodes of the other moves are only reused by the next tree, a nearly full pool starts it now
+	var child *Node
+	for i := byte(0); i < m.root.childrenCount && m.poolIndex < poolSize*3/4; i++ {
+		if m.root.children[i].board == board && m.root.children[i].move == move {
+			child = m.root.children[i]
+		}
+	}
+	if child == nil {
+		m.reset()
+		return
+	}
+
+	// A proven leaf is not expanded, as the root it needs children for the next move
+	child.parent, child.solved = nil, UNSOLVED
+	m.root = child
+}
 
-// NewMCTS returns a new MCTS wrapper
-func NewMCTS(initial *Game.Game) *MCTS {
-	NodePoolIndex = 1
+// SeedPlayouts draws the random playouts from the sequence of the root position, see Game.NewXorshiftAt.
+// A search of rounds from the position then repeats exactly after Game.Seed
+func (m *MCTS) SeedPlayouts() {
+	m.rng = Game.NewXorshiftAt(m.game)
+}
 
-	nodePool[0].parent = nil
-	nodePool[0].nodeVisits = 1
-	nodePool[0].nodeScore = 0
-	nodePool[0].childrenCount = 0
-	return &MCTS{
-		game:     initial,
-		gameCopy: &ggCopy,
-		root:     &nodePool[0],
+// Close releases the nodes for the next search, the search must not be used afterwards
+func (m *MCTS) Close() {
+	if m.pool != nil {
+		nodePools.Put(m.pool)
+		m.pool, m.root = nil, nil
 	}
 }
 
-var player Game.Player
-var winningPlayer Game.Player
-var node *Node = nil
-var availableMoves byte = 0
+// Nodes is the amount of nodes in the tree
+func (m *MCTS) Nodes() int {
+	if m.dag != nil {
+		return len(m.dag.table)
+	}
+	return m.poolIndex
+}
 
 func (m *MCTS) search() {
+	if m.dag != nil {
+		m.dag.search(m.game, &m.gameCopy, &m.config, &m.solver, m.rng)
+		return
+	}
+
 	// Selection
-	node = m.root
+	node := m.root
 	m.gameCopy.OverallBoard = m.game.OverallBoard
-	player = Game.Player(m.gameCopy.Board[Game.PlayerBoardIndex] & 0x1)
 	for i := 0; i < 10; i++ {
 		m.gameCopy.Board[i] = m.game.Board[i]
 	}
 
 	for node.childrenCount > 0 {
 		// Check children (tree policy)
-		node = node.treePolicy()
+		node = node.treePolicy(&m.config)
 		m.gameCopy.MakeMove(node.board, node.move)
 	}
 
-	// Expansion
-	if !m.gameCopy.IsTerminal() {
-		// Fill out the slice to make room for new items
-		availableMoves = m.gameCopy.Len()
-		if node.maxChildren < availableMoves {
-			node.children = append(node.children, make([]*Node, availableMoves-node.maxChildren)...)
-			node.maxChildren = availableMoves
-		}
+	// Expansion, once the pool is exhausted the leaf is simulated without growing the tree
+	if node.solved == UNSOLVED && !m.gameCopy.IsTerminal() && m.poolIndex+int(m.gameCopy.Len()) < poolSize {
+		// The children take the next nodes of the pool, their links are kept at the same indices
+		availableMoves := int(m.gameCopy.Len())
+		node.children = m.pool.links[m.poolIndex+1 : m.poolIndex+1+availableMoves : m.poolIndex+1+availableMoves]
 
 		// Iterate over all children
 		node.childrenCount = 0
 		m.gameCopy.GetMoves(func(board byte, move byte) bool {
-			NodePoolIndex++
-			node.children[node.childrenCount] = &nodePool[NodePoolIndex]
+			m.poolIndex++
+			node.children[node.childrenCount] = &m.pool.nodes[m.poolIndex]
 			node.children[node.childrenCount].parent = node
 			node.children[node.childrenCount].move = move
 			node.children[node.childrenCount].board = board
-			node.children[node.childrenCount].nodeVisits = 1
+			node.children[node.childrenCount].nodeVisits = 0
 			node.children[node.childrenCount].nodeScore = 0
+			node.children[node.childrenCount].nodeSquares = 0
 			node.children[node.childrenCount].childrenCount = 0
+			node.children[node.childrenCount].solved = UNSOLVED
 			node.childrenCount++
 			return false
 		})
 
 		// node = node.children[Game.RandSource.Intn(int(node.childrenCount))]
-		node = node.children[Game.Xorshift64star(node.childrenCount)]
+		node = node.children[m.rng.Next(node.childrenCount)]
 		m.gameCopy.MakeMove(node.board, node.move)
+		if m.config.MinimaxDepth > 0 {
+			node.solved = m.solver.solve(&m.gameCopy)
+		}
 	}
 
-	// Simulation
-	m.gameCopy.MakeMoveRandUntilTerminal()
+	// The node is scored for the player who made the move leading to it
+	player := Game.Player(m.gameCopy.Board[Game.PlayerBoardIndex]&0x1) ^ 0x1
 
-	// Backpropagation
-	player = m.gameCopy.WinningPlayer()
-	for node.parent != nil {
+	// Simulation, a solved node already knows the winner
+	var winningPlayer Game.Player
+	if node.solved == SOLVED_WIN {
+		winningPlayer = player
+	} else if node.solved == SOLVED_LOSS {
+		winningPlayer = player ^ 0x1
+	} else {
+		m.gameCopy.RandomPlayout(m.rng)
+		winningPlayer = m.gameCopy.WinningPlayer()
+	}
+
+	// Backpropagation, the root is included so its visits match the children
+	for node != nil {
 		if player == winningPlayer {
 			node.nodeScore += 2
-		} else if winningPlayer == 2 {
+			node.nodeSquares += 4
+		} else if winningPlayer == Game.Draw {
 			node.nodeScore += 1
+			node.nodeSquares += 1
 		}
 		node.nodeVisits += 1
-		node.nodeExploit = float32(node.nodeScore>>1) / float32(node.nodeVisits)
 		node = node.parent
+		player ^= 0x1
+	}
+}
+
+// rootChild summarises a move at the root for the final move selection
+type rootChild struct {
+	move    byte
+	board   byte
+	visits  uint32
+	exploit float32
+}
+
+func (t *MCTS) rootChildren() []rootChild {
+	if t.config.Transpositions {
+		return t.dag.root.childStats()
 	}
+	return t.root.childStats()
+}
+
+// childStats summarises the children of the node as if it was the root
+func (n *Node) childStats() []rootChild {
+	children := make([]rootChild, n.childrenCount)
+	for i := byte(0); i < n.childrenCount; i++ {
+		child := n.children[i]
+		children[i] = rootChild{move: child.move, board: child.board, visits: child.nodeVisits}
+		if child.nodeVisits > 0 {
+			children[i].exploit = child.exploit()
+		}
+	}
+	return children
 }
 
 func (t *MCTS) BestAction() (byte, byte) {
-	var bestAction byte
-	var bestBoard byte
-	//Select the child with the highest winrate
-	if bestActionPolicy == MAX_CHILD_SCORE {
-		var bestWinRate float32 = 0
-		player = Game.Player(t.game.Board[Game.PlayerBoardIndex] & 0x1)
-		for i := byte(0); i < t.game.Len(); i++ {
-			winRate := float32(t.root.children[i].nodeScore>>1) / float32(t.root.children[i].nodeVisits)
-			if winRate >= bestWinRate {
-				bestAction = t.root.children[i].move
-				bestBoard = t.root.children[i].board
-				bestWinRate = winRate
+	return t.bestChild(t.rootChildren())
+}
+
+// BestReply is the best action after the move at the root without advancing the tree, it is the move a search
+// of the opponent's turn would play after that reply. ok is false when nothing was searched after the move
+func (t *MCTS) BestReply(board byte, move byte) (replyMove byte, replyBoard byte, ok bool) {
+	var children []rootChild
+	if t.config.Transpositions {
+		for _, edge := range t.dag.root.edges {
+			if edge.board == board && edge.move == move {
+				children = edge.child.childStats()
 			}
 		}
-	} else if bestActionPolicy == ROBUST_CHILD {
-		var mostVisists uint16 = 1
-		for i := byte(0); i < t.game.Len(); i++ {
-			if t.root.children[i].nodeVisits >= mostVisists {
-				bestAction = t.root.children[i].move
-				bestBoard = t.root.children[i].board
-				mostVisists = t.root.children[i].nodeVisits
+	} else {
+		for i := byte(0); i < t.root.childrenCount; i++ {
+			if child := t.root.children[i]; child.board == board && child.move == move {
+				children = child.childStats()
 			}
 		}
 	}
+	if len(children) == 0 {
+		return 0, 0, false
+	}
+	replyMove, replyBoard = t.bestChild(children)
+	return replyMove, replyBoard, true
+}
+
+// bestChild selects the move and board among the children with the best action policy
+func (t *MCTS) bestChild(children []rootChild) (byte, byte) {
+	var best int
+	switch t.config.BestAction {
+	case MAX_CHILD_SCORE:
+		best = maxChild(children)
+	case MAX_ROBUST_CHILD:
+		best = maxRobustChild(children)
+	case SECURE_CHILD:
+		best = secureChild(children, t.config.ExplorationConst)
+	default:
+		best = robustChild(children)
+	}
 
-	return bestAction, bestBoard
+	if best < 0 {
+		return 0, 0
+	}
+	return children[best].move, children[best].board
+}
+
+// maxChild selects the child with the highest winrate
+func maxChild(children []rootChild) int {
+	var best = -1
+	var bestWinRate float32 = -1
+	for i, child := range children {
+		if child.visits > 0 && child.exploit > bestWinRate {
+			best = i
+			bestWinRate = child.exploit
+		}
+	}
+	return best
+}
+
+// robustChild selects the most visited child
+func robustChild(children []rootChild) int {
+	var best = -1
+	var mostVisits uint32 = 0
+	for i, child := range children {
+		if best < 0 || child.visits > mostVisits {
+			best = i
+			mostVisits = child.visits
+		}
+	}
+	return best
+}
+
+// maxRobustChild selects the child that is both the most visited and the highest winrate,
+// when they disagree the child with the most visits plus wins is used instead
+func maxRobustChild(children []rootChild) int {
+	if best := robustChild(children); best == maxChild(children) {
+		return best
+	}
+
+	var best = -1
+	var bestValue float32 = 0
+	for i, child := range children {
+		if value := float32(child.visits) * (1 + child.exploit); best < 0 || value > bestValue {
+			best = i
+			bestValue = value
+		}
+	}
+	return best
+}
+
+// secureChild selects the child with the highest lower confidence bound
+func secureChild(children []rootChild, c float32) int {
+	var best = -1
+	var bestBound = float32(math.Inf(-1))
+	for i, child := range children {
+		if child.visits == 0 {
+			continue
+		}
+		if bound := child.exploit - c/float32(math.Sqrt(float64(child.visits))); bound > bestBound {
+			best = i
+			bestBound = bound
+		}
+	}
+	return best
 }
 
 // SearchTime searches the tree for a specified time
@@ -149,11 +356,21 @@ func (t *MCTS) SearchTime(duration time.Duration) {
 
 // SearchRounds searches the tree for a specified number of rounds
 //
-// SearchRounds will panic if the Game's ApplyAction
-// method returns an error or if any game state's Hash()
-// method returns a noncomparable value.
+// With MCTSConfig.Transpositions the positions are keyed by the Game's Hash()
+// so statistics are shared between move orders reaching the same position.
 func (t *MCTS) SearchRounds(rounds int) {
 	for i := 0; i < rounds; i++ {
 		t.search()
 	}
 }
+
+// SearchBudget searches until the budget is exhausted, every round counts as a node.
+// The alpha-beta searches of the hybrid MCTS end with the budget as well
+func (t *MCTS) SearchBudget(budget *minimax.Budget) {
+	t.solver.within(budget)
+	defer t.solver.within(nil)
+	for !budget.Exhausted() {
+		t.search()
+		budget.Nodes++
+	}
+}
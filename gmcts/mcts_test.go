@@ -0,0 +1,253 @@
+package gmcts
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+func TestNodeSize(t *testing.T) {
+	if size := unsafe.Sizeof(Node{}); size > 48 {
+		t.Fatalf("Node grew to %d bytes, the preallocated pool expects at most 48", size)
+	}
+}
+
+func TestLongSearchStatisticsMonotonic(t *testing.T) {
+	if testing.Short() {
+		t.Skip("long search")
+	}
+
+	game := Game.NewGame()
+	game.MakeMove(8, 8)
+	mcts := NewMCTS(game, DefaultConfig())
+
+	const batches = 60
+	const roundsPerBatch = 10000
+	var prevVisits, prevScores []uint32
+	for b := 0; b < batches; b++ {
+		mcts.SearchRounds(roundsPerBatch)
+
+		root := mcts.root
+		if want := uint32((b + 1) * roundsPerBatch); root.nodeVisits != want {
+			t.Fatalf("batch %d: root visits %d, want %d", b, root.nodeVisits, want)
+		}
+
+		visits := make([]uint32, root.childrenCount)
+		scores := make([]uint32, root.childrenCount)
+		var childVisits uint32
+		for i := byte(0); i < root.childrenCount; i++ {
+			child := root.children[i]
+			visits[i], scores[i] = child.nodeVisits, child.nodeScore
+			childVisits += child.nodeVisits
+			if child.nodeScore > 2*child.nodeVisits {
+				t.Fatalf("batch %d: child %d score %d exceeds twice its visits %d", b, i, child.nodeScore, child.nodeVisits)
+			}
+			if winRate := child.exploit(); winRate < 0 || winRate > 1 {
+				t.Fatalf("batch %d: child %d win rate %f out of range", b, i, winRate)
+			}
+			if prevVisits != nil && (visits[i] < prevVisits[i] || scores[i] < prevScores[i]) {
+				t.Fatalf("batch %d: child %d statistics decreased, visits %d -> %d, score %d -> %d", b, i, prevVisits[i], visits[i], prevScores[i], scores[i])
+			}
+		}
+		if childVisits != root.nodeVisits {
+			t.Fatalf("batch %d: children visits %d do not add up to root visits %d", b, childVisits, root.nodeVisits)
+		}
+		prevVisits, prevScores = visits, scores
+	}
+
+	var mostVisits uint32
+	for i := byte(0); i < mcts.root.childrenCount; i++ {
+		if mcts.root.children[i].nodeVisits > mostVisits {
+			mostVisits = mcts.root.children[i].nodeVisits
+		}
+	}
+	if mostVisits <= 0xFFFF {
+		t.Fatalf("most visited child has %d visits, the search should exceed the 16 bit range", mostVisits)
+	}
+}
+
+func TestConfigsReturnLegalMove(t *testing.T) {
+	game := Game.NewGame()
+	game.MakeMove(8, 8)
+	game.MakeMove(8, 0)
+
+	selections := []SelectionPolicy{UCT1, UCT2, UCB1_TUNED, PUCT}
+	policies := []BestActionPolicy{MAX_CHILD_SCORE, ROBUST_CHILD, MAX_ROBUST_CHILD, SECURE_CHILD}
+	for _, selection := range selections {
+		for _, policy := range policies {
+			config := DefaultConfig()
+			config.Selection = selection
+			config.BestAction = policy
+
+			mcts := NewMCTS(game, config)
+			mcts.SearchRounds(2000)
+			move, board := mcts.BestAction()
+			if !game.ValidMove(board, move) {
+				t.Errorf("selection %d policy %d: illegal move %d in board %d", selection, policy, move, board)
+			}
+		}
+	}
+}
+
+func TestTranspositionsShareNodes(t *testing.T) {
+	game := Game.NewGame()
+	game.MakeMove(8, 8)
+
+	config := DefaultConfig()
+	config.Transpositions = true
+	mcts := NewMCTS(game, config)
+	mcts.SearchRounds(20000)
+
+	if mcts.dag.root.nodeVisits != 20000 || mcts.dag.root.edgeVisits() != 20000 {
+		t.Fatalf("root visits %d and edge visits %d, want 20000", mcts.dag.root.nodeVisits, mcts.dag.root.edgeVisits())
+	}
+
+	parents := map[*dagNode]int{}
+	for _, node := range mcts.dag.table {
+		for _, edge := range node.edges {
+			parents[edge.child]++
+		}
+	}
+	shared := 0
+	for _, count := range parents {
+		if count > 1 {
+			shared++
+		}
+	}
+	if shared == 0 {
+		t.Fatalf("no position in %d nodes is reached from more than one parent", len(mcts.dag.table))
+	}
+
+	move, board := mcts.BestAction()
+	if !game.ValidMove(board, move) {
+		t.Fatalf("illegal move %d in board %d", move, board)
+	}
+}
+
+func TestSearchesRunInParallel(t *testing.T) {
+	game := Game.NewGame()
+	game.MakeMove(8, 8)
+
+	// Seeded from the position every search draws the same playouts as when it runs alone
+	Game.Seed(7)
+	alone := NewMCTS(game, DefaultConfig())
+	alone.SeedPlayouts()
+	alone.SearchRounds(3000)
+	aloneMove, aloneBoard := alone.BestAction()
+	alone.Close()
+
+	var wait sync.WaitGroup
+	searches := make([]*MCTS, 4)
+	for i := range searches {
+		searches[i] = NewMCTS(game, DefaultConfig())
+		searches[i].SeedPlayouts()
+		wait.Add(1)
+		go func(m *MCTS) {
+			defer wait.Done()
+			m.SearchRounds(3000)
+		}(searches[i])
+	}
+	wait.Wait()
+
+	for i, m := range searches {
+		if m.root.nodeVisits != 3000 || m.Nodes() < 9 {
+			t.Fatalf("search %d has %d visits and %d nodes", i, m.root.nodeVisits, m.Nodes())
+		}
+	}
+	for i, m := range searches {
+		if move, board := m.BestAction(); move != aloneMove || board != aloneBoard {
+			t.Fatalf("search %d played %d %d, alone it played %d %d", i, board, move, aloneBoard, aloneMove)
+		}
+	}
+	for _, m := range searches {
+		m.Close()
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	game := Game.NewGame()
+	game.MakeMove(8, 8)
+
+	for _, transpositions := range []bool{false, true} {
+		config := DefaultConfig()
+		config.Transpositions = transpositions
+		mcts := NewMCTS(game, config)
+		mcts.SearchRounds(5000)
+
+		// The opponent moves first, the tree is searched for every reply
+		move, board := mcts.BestAction()
+		var visits uint32
+		for _, child := range mcts.rootChildren() {
+			if child.move == move && child.board == board {
+				visits = child.visits
+			}
+		}
+		replyMove, replyBoard, ok := mcts.BestReply(board, move)
+		if !ok {
+			t.Fatalf("transpositions %t: no reply after %d%d", transpositions, board, move)
+		}
+
+		mcts.Advance(board, move)
+		if root := mcts.rootChildren(); len(root) == 0 {
+			t.Fatalf("transpositions %t: the subtree of %d%d was not kept", transpositions, board, move)
+		}
+		if m, b := mcts.BestAction(); m != replyMove || b != replyBoard {
+			t.Errorf("transpositions %t: best action %d%d after advancing, reply %d%d", transpositions, b, m, replyBoard, replyMove)
+		}
+		var kept uint32
+		for _, child := range mcts.rootChildren() {
+			kept += child.visits
+		}
+		if kept == 0 || kept > visits {
+			t.Errorf("transpositions %t: %d visits below the root, the move had %d", transpositions, kept, visits)
+		}
+
+		state := game.Copy()
+		state.MakeMove(board, move)
+		mcts.SearchRounds(1000)
+		if m, b := mcts.BestAction(); !state.ValidMove(b, m) {
+			t.Errorf("transpositions %t: illegal move %d%d after advancing", transpositions, b, m)
+		}
+		mcts.Close()
+	}
+}
+
+func TestAdvanceOntoSolvedNode(t *testing.T) {
+	game := Game.NewGame()
+	game.MakeMove(8, 8)
+
+	for _, transpositions := range []bool{false, true} {
+		config := DefaultConfig()
+		config.Transpositions = transpositions
+		mcts := NewMCTS(game, config)
+		mcts.SearchRounds(1)
+
+		// The hybrid MCTS proves the result of a leaf, the leaf is never expanded. The move may not send
+		// the opponent to board 0, where the 00 of a root without children is legal
+		var board, move byte
+		if mcts.dag != nil {
+			edge := &mcts.dag.root.edges[0]
+			for i := 1; edge.move == 0; i++ {
+				edge = &mcts.dag.root.edges[i]
+			}
+			edge.child.solved, board, move = SOLVED_WIN, edge.board, edge.move
+		} else {
+			child := mcts.root.children[0]
+			for i := 1; child.move == 0; i++ {
+				child = mcts.root.children[i]
+			}
+			child.solved, board, move = SOLVED_WIN, child.board, child.move
+		}
+		mcts.Advance(board, move)
+		mcts.SearchRounds(100)
+
+		state := game.Copy()
+		state.MakeMove(board, move)
+		if m, b := mcts.BestAction(); !state.ValidMove(b, m) {
+			t.Errorf("transpositions %t: illegal move %d%d after advancing onto a solved node", transpositions, b, m)
+		}
+		mcts.Close()
+	}
+}
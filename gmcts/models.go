@@ -9,21 +9,43 @@ type Node struct {
 	parent        *Node
 	children      []*Node
 	childrenCount byte
-	maxChildren   byte
+
+	// solved is set when a shallow minimax proved the result of the node
+	solved byte
 
 	move  byte
 	board byte
 
-	nodeScore   uint16
-	nodeVisits  uint16
-	nodeExploit float32
+	// nodeScore counts half points (win = 2, draw = 1) so draws are kept exact,
+	// nodeSquares counts the squared rewards in quarter points (win = 4, draw = 1) for UCB1-Tuned.
+	// The counters are 32 bit to survive long analysis searches while keeping the node at 48 bytes
+	nodeScore   uint32
+	nodeVisits  uint32
+	nodeSquares uint32
 }
 
 type BestActionPolicy byte
 
 const (
-	MAX_CHILD_SCORE BestActionPolicy = 0
-	ROBUST_CHILD    BestActionPolicy = 1
+	MAX_CHILD_SCORE  BestActionPolicy = 0
+	ROBUST_CHILD     BestActionPolicy = 1
+	MAX_ROBUST_CHILD BestActionPolicy = 2
+	SECURE_CHILD     BestActionPolicy = 3
+)
+
+const (
+	UNSOLVED    byte = 0
+	SOLVED_WIN  byte = 1
+	SOLVED_LOSS byte = 2
+)
+
+type SelectionPolicy byte
+
+const (
+	UCT1       SelectionPolicy = 0
+	UCT2       SelectionPolicy = 1
+	UCB1_TUNED SelectionPolicy = 2
+	PUCT       SelectionPolicy = 3
 )
 
 const (
@@ -31,8 +53,40 @@ const (
 	//Sqrt(2) is a frequent choice for this constant as specified by
 	//https://en.wikipedia.org/wiki/Monte_Carlo_tree_search
 	DefaultExplorationConst = float32(math.Sqrt2) - 1
+
+	//DefaultFirstPlayUrgency makes every child be visited once before any is revisited
+	DefaultFirstPlayUrgency = float32(math.MaxFloat32)
 )
 
+// MCTSConfig contains the tunable parameters of a search
+type MCTSConfig struct {
+	// ExplorationConst scales the exploration term of the selection formula,
+	// for SECURE_CHILD it is also the width of the lower confidence bound
+	ExplorationConst float32
+	Selection        SelectionPolicy
+	BestAction       BestActionPolicy
+
+	// FirstPlayUrgency is the value given to children that have not been visited yet
+	FirstPlayUrgency float32
+
+	// Transpositions searches a DAG where positions reached by different move orders share a node
+	Transpositions bool
+
+	// MinimaxDepth runs an alpha-beta search of this depth on every expanded node to find forced
+	// wins and losses (MCTS-MS), solved nodes are not expanded or simulated. 0 disables the check
+	MinimaxDepth byte
+}
+
+// DefaultConfig returns the configuration the bots have been tuned with
+func DefaultConfig() MCTSConfig {
+	return MCTSConfig{
+		ExplorationConst: DefaultExplorationConst,
+		Selection:        UCT2,
+		BestAction:       ROBUST_CHILD,
+		FirstPlayUrgency: DefaultFirstPlayUrgency,
+	}
+}
+
 const magic32 = 0x5F375A86
 const th = 1.5
 
@@ -53,13 +107,65 @@ func ln(x float32) float32 {
 	return -1.49278 + (2.11263+(-0.729104+0.10969*x)*x)*x + 0.6931471806*t
 }
 
-// UCT2 algorithm is described in this paper
+// exploit is the average reward of the node for the player who moved into it
+func (n *Node) exploit() float32 {
+	return float32(float64(n.nodeScore) / float64(2*uint64(n.nodeVisits)))
+}
+
+// meanSquares is the average squared reward of the node
+func (n *Node) meanSquares() float32 {
+	return float32(float64(n.nodeSquares) / float64(4*uint64(n.nodeVisits)))
+}
+
+// uct1 is the UCB1 formula by Auer et al. applied to trees
+// https://link.springer.com/article/10.1023/A:1013689704352
+func uct1(parentVisits uint32, visits uint32, exploit float32, c float32) float32 {
+	explore := 2 * ln(float32(parentVisits)) / float32(visits)
+	return exploit + c*float32(math.Sqrt(float64(explore)))
+}
+
+// uct2 algorithm is described in this paper
 // https://www.csse.uwa.edu.au/cig08/Proceedings/papers/8057.pdf
-func (n *Node) UCT2(i byte) float32 {
-	explore := ln(float32(n.nodeVisits)) / float32(n.children[i].nodeVisits) // math.Log(float64(n.nodeVisits)) / float64(n.children[i].nodeVisits)
-	explore = float32(math.Sqrt(float64(explore)))                           // FastSqrt32(explore)                                            // float32(math.Sqrt(float64(explore)))                           // 1 / FastInvSqrt64(explore) // math.Sqrt(explore) // 1 / FastInvSqrt64(explore) //
+func uct2(parentVisits uint32, visits uint32, exploit float32, c float32) float32 {
+	explore := ln(float32(parentVisits)) / float32(visits) // math.Log(float64(n.nodeVisits)) / float64(n.children[i].nodeVisits)
+	explore = float32(math.Sqrt(float64(explore)))         // FastSqrt32(explore)                                            // float32(math.Sqrt(float64(explore)))                           // 1 / FastInvSqrt64(explore) // math.Sqrt(explore) // 1 / FastInvSqrt64(explore) //
+
+	return exploit + c*explore
+}
 
-	return n.nodeExploit + DefaultExplorationConst*explore
+// ucb1Tuned bounds the exploration by the variance of the rewards, as described by Auer et al.
+func ucb1Tuned(parentVisits uint32, visits uint32, exploit float32, meanSquares float32, c float32) float32 {
+	logVisits := ln(float32(parentVisits)) / float32(visits)
+	variance := meanSquares - exploit*exploit + float32(math.Sqrt(float64(2*logVisits)))
+	if variance > 0.25 {
+		variance = 0.25
+	}
+	return exploit + c*float32(math.Sqrt(float64(logVisits*variance)))
+}
+
+// puct is the AlphaZero selection formula, without a policy the prior is uniform over the children
+func puct(parentVisits uint32, visits uint32, exploit float32, siblings byte, c float32) float32 {
+	prior := 1 / float32(siblings)
+	return exploit + c*prior*float32(math.Sqrt(float64(parentVisits)))/float32(1+visits)
+}
+
+// selectionValue scores a child for the tree policy, visits counts how often the child was chosen from
+// this parent and exploit is its average reward for the player choosing it
+func (config *MCTSConfig) selectionValue(parentVisits uint32, visits uint32, exploit float32, meanSquares float32, siblings byte) float32 {
+	if visits == 0 {
+		return config.FirstPlayUrgency
+	}
+
+	switch config.Selection {
+	case UCT1:
+		return uct1(parentVisits, visits, exploit, config.ExplorationConst)
+	case UCB1_TUNED:
+		return ucb1Tuned(parentVisits, visits, exploit, meanSquares, config.ExplorationConst)
+	case PUCT:
+		return puct(parentVisits, visits, exploit, siblings, config.ExplorationConst)
+	default:
+		return uct2(parentVisits, visits, exploit, config.ExplorationConst)
+	}
 }
 
 // smitsimax Node selection algorithm is described in this paper
@@ -76,14 +182,16 @@ func (n *Node) smitsimax(i int, p Game.Player) float64 {
 }
 */
 
-func (node *Node) treePolicy() *Node {
-	var bestScore float32 = 0
+func (node *Node) treePolicy(config *MCTSConfig) *Node {
+	var bestScore = float32(math.Inf(-1))
 	var bestNode = node.children[0]
+	var child *Node
 	for i := byte(0); i < node.childrenCount; i++ {
-		score := node.UCT2(i)
+		child = node.children[i]
+		score := config.selectionValue(node.nodeVisits, child.nodeVisits, child.exploit(), child.meanSquares(), node.childrenCount)
 		if score >= bestScore {
 			bestScore = score
-			bestNode = node.children[i]
+			bestNode = child
 		}
 	}
 	return bestNode
@@ -0,0 +1,206 @@
+package gmcts
+
+import "github.com/FabianPetersen/UltimateTicTacToe/Game"
+
+// maxDagNodes bounds the transposition table to the size of the tree pool
+const maxDagNodes = poolSize
+
+// dagEdge is a move from one position to another, the visits count how often the move was chosen from its parent
+type dagEdge struct {
+	child  *dagNode
+	visits uint32
+	board  byte
+	move   byte
+}
+
+// dagNode holds the statistics of a single position, shared by every parent that can reach it
+type dagNode struct {
+	edges []dagEdge
+
+	// nodeScore and nodeSquares are kept like Node for every pass through the position,
+	// leafScore only counts the playouts started from the position itself
+	nodeScore   uint32
+	nodeVisits  uint32
+	nodeSquares uint32
+	leafScore   uint32
+	solved      byte
+
+	// value is the UCT3 backed up reward for the player who moved into the position
+	value float32
+}
+
+// dag is the search graph of a transposition aware search, positions are keyed by Game.Hash()
+type dag struct {
+	root  *dagNode
+	table map[[10]uint32]*dagNode
+	path  []*dagEdge
+	nodes []*dagNode
+}
+
+func newDag(initial *Game.Game) *dag {
+	d := &dag{
+		root:  &dagNode{},
+		table: make(map[[10]uint32]*dagNode, 1<<14),
+		path:  make([]*dagEdge, 0, 81),
+		nodes: make([]*dagNode, 0, 82),
+	}
+	d.table[*initial.Hash()] = d.root
+	return d
+}
+
+// advance moves the root to the position after the move, the positions that can no longer be reached stay in
+// the table. It returns false when the move was never expanded or the table is nearly full
+func (d *dag) advance(board byte, move byte) bool {
+	if len(d.table) >= maxDagNodes*3/4 {
+		return false
+	}
+	for _, edge := range d.root.edges {
+		if edge.board == board && edge.move == move {
+			// A proven leaf is not expanded, as the root it needs edges for the next move
+			d.root = edge.child
+			d.root.solved = UNSOLVED
+			return true
+		}
+	}
+	return false
+}
+
+// edgeVisits is the amount of passes that continued from the node to a child
+func (n *dagNode) edgeVisits() uint32 {
+	var visits uint32 = 0
+	for i := range n.edges {
+		visits += n.edges[i].visits
+	}
+	return visits
+}
+
+func (n *dagNode) meanSquares() float32 {
+	return float32(float64(n.nodeSquares) / float64(4*uint64(n.nodeVisits)))
+}
+
+// update computes the UCT3 value, the playouts started from the node are combined with the
+// values of the children weighted by how often they were chosen from this node
+func (n *dagNode) update() {
+	var visits = float64(n.nodeVisits - n.edgeVisits())
+	var total = float64(n.leafScore) / 2
+	for i := range n.edges {
+		if n.edges[i].visits > 0 {
+			total += float64(n.edges[i].visits) * float64(1-n.edges[i].child.value)
+			visits += float64(n.edges[i].visits)
+		}
+	}
+	n.value = float32(total / visits)
+}
+
+func (d *dag) treePolicy(node *dagNode, config *MCTSConfig) *dagEdge {
+	var bestScore float32
+	var bestEdge *dagEdge
+	var edge *dagEdge
+	for i := range node.edges {
+		edge = &node.edges[i]
+		score := config.selectionValue(node.nodeVisits, edge.visits, edge.child.value, edge.child.meanSquares(), byte(len(node.edges)))
+		if bestEdge == nil || score >= bestScore {
+			bestScore = score
+			bestEdge = edge
+		}
+	}
+	return bestEdge
+}
+
+// expand creates the edges of the node, children already in the table are shared
+func (d *dag) expand(node *dagNode, state *Game.Game) {
+	node.edges = make([]dagEdge, 0, state.Len())
+	state.GetMoves(func(board byte, move byte) bool {
+		prevBoard := byte(state.Board[Game.PlayerBoardIndex] >> 1)
+		state.MakeMove(board, move)
+		child, exists := d.table[*state.Hash()]
+		if !exists {
+			child = &dagNode{}
+			d.table[*state.Hash()] = child
+		}
+		state.UnMakeMove(move, board, prevBoard)
+
+		node.edges = append(node.edges, dagEdge{child: child, board: board, move: move})
+		return false
+	})
+}
+
+func (d *dag) search(game *Game.Game, gameCopy *Game.Game, config *MCTSConfig, solver *solver, rng *Game.Xorshift) {
+	// Selection
+	node := d.root
+	d.path = d.path[:0]
+	d.nodes = append(d.nodes[:0], node)
+	gameCopy.OverallBoard = game.OverallBoard
+	for i := 0; i < 10; i++ {
+		gameCopy.Board[i] = game.Board[i]
+	}
+
+	for len(node.edges) > 0 {
+		edge := d.treePolicy(node, config)
+		d.path = append(d.path, edge)
+		gameCopy.MakeMove(edge.board, edge.move)
+		node = edge.child
+		d.nodes = append(d.nodes, node)
+	}
+
+	// Expansion, once the table is full the leaf is simulated without growing the graph
+	if node.solved == UNSOLVED && !gameCopy.IsTerminal() && len(d.table)+int(gameCopy.Len()) < maxDagNodes {
+		d.expand(node, gameCopy)
+		edge := &node.edges[rng.Next(byte(len(node.edges)))]
+		d.path = append(d.path, edge)
+		gameCopy.MakeMove(edge.board, edge.move)
+		node = edge.child
+		d.nodes = append(d.nodes, node)
+		if config.MinimaxDepth > 0 && node.nodeVisits == 0 {
+			node.solved = solver.solve(gameCopy)
+		}
+	}
+
+	// The node is scored for the player who made the move leading to it
+	player := Game.Player(gameCopy.Board[Game.PlayerBoardIndex]&0x1) ^ 0x1
+
+	// Simulation, a solved node already knows the winner
+	var winningPlayer Game.Player
+	if node.solved == SOLVED_WIN {
+		winningPlayer = player
+	} else if node.solved == SOLVED_LOSS {
+		winningPlayer = player ^ 0x1
+	} else {
+		gameCopy.RandomPlayout(rng)
+		winningPlayer = gameCopy.WinningPlayer()
+	}
+
+	var score, squares uint32 = 0, 0
+	if player == winningPlayer {
+		score, squares = 2, 4
+	} else if winningPlayer == Game.Draw {
+		score, squares = 1, 1
+	}
+	node.leafScore += score
+
+	// Backpropagation along the path that was taken, parents of shared nodes on other
+	// paths pick up the new value the next time they are updated
+	for i := len(d.path); i >= 0; i-- {
+		node = d.nodes[i]
+		if i < len(d.path) {
+			d.path[i].visits++
+		}
+		node.nodeScore += score
+		node.nodeSquares += squares
+		node.nodeVisits++
+		node.update()
+
+		// A win for one player is a loss for the other
+		score = 2 - score
+		squares = score * score
+	}
+}
+
+// childStats summarises the edges of the node as if it was the root
+func (n *dagNode) childStats() []rootChild {
+	children := make([]rootChild, len(n.edges))
+	for i, edge := range n.edges {
+		children[i] = rootChild{move: edge.move, board: edge.board, visits: edge.visits, exploit: edge.child.value}
+	}
+	return children
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+// http serves the engine as JSON over HTTP:
+//
+//	POST /bestmove    {"position": "...", "moves": ["84"], "engine": "mtd:depth=9", "movetime": 100, "depth": 0, "nodes": 0}
+//	POST /legalmoves  {"position": "...", "moves": [...]}
+//	POST /evaluate    {"position": "...", "moves": [...], "engine": "..."}
+//	GET  /health
+//
+// A position is written as by match.FormatPosition and an empty one is the start, a move is its board and
+// square digit. The engine is a bot spec as the arena takes it
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	instances := flag.Int("instances", 4, "engine instances, requests beyond them wait for a free one")
+	engine := flag.String("engine", "mtd:depth=64,time=500ms", "bot spec of requests without an engine")
+	maxTime := flag.Duration("max-time", time.Second*5, "longest search of a request")
+	flag.Parse()
+
+	s, err := newServer(*instances, *engine, *maxTime)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("listening on", *addr)
+	log.Fatalln(http.ListenAndServe(*addr, s.handler()))
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+)
+
+// instance is an engine of the pool, every instance searches with its own transposition table
+type instance struct {
+	table minimax.Storage
+}
+
+// server answers the requests with the instances of its pool, a request waits for a free instance
+type server struct {
+	pool    chan *instance
+	engine  string
+	maxTime time.Duration
+}
+
+// newServer starts a pool of size instances, engine is the bot spec used when a request names none.
+// No search runs longer than maxTime
+func newServer(size int, engine string, maxTime time.Duration) (*server, error) {
+	if size < 1 || maxTime <= 0 {
+		return nil, fmt.Errorf("the pool needs an instance and a positive maximum time")
+	}
+	if _, err := match.ParseBot(engine); err != nil {
+		return nil, err
+	}
+
+	s := &server{pool: make(chan *instance, size), engine: engine, maxTime: maxTime}
+	for i := 0; i < size; i++ {
+		s.pool <- &instance{table: minimax.NewStorage()}
+	}
+	return s, nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bestmove", post(s.bestMove))
+	mux.HandleFunc("/legalmoves", post(s.legalMoves))
+	mux.HandleFunc("/evaluate", post(s.evaluate))
+	mux.HandleFunc("/health", s.health)
+	return mux
+}
+
+// errBusy is returned when the request ended while it waited for an instance
+var errBusy = errors.New("no engine instance is free")
+
+// requestError is a problem with the request itself, it is answered with a 400
+type requestError struct {
+	err error
+}
+
+func (e requestError) Error() string {
+	return e.err.Error()
+}
+
+// post decodes the JSON body of a POST into the request of handle and encodes its response
+func post[Request any, Response any](handle func(r *http.Request, request *Request) (Response, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use POST"})
+			return
+		}
+
+		request := new(Request)
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(request); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		response, err := handle(r, request)
+		var badRequest requestError
+		switch {
+		case errors.As(err, &badRequest):
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		case errors.Is(err, errBusy):
+			writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, response)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// positionRequest is a position as written by match.FormatPosition followed by moves, an empty position is the start
+type positionRequest struct {
+	Position string   `json:"position"`
+	Moves    []string `json:"moves"`
+}
+
+func (p *positionRequest) game() (*Game.Game, error) {
+	game := Game.NewGame()
+	if p.Position != "" && p.Position != "startpos" {
+		var err error
+		if game, err = match.ParsePosition(p.Position); err != nil {
+			return nil, requestError{err}
+		}
+	}
+
+	moves, err := parseMoves(p.Moves)
+	if err != nil {
+		return nil, err
+	}
+	for _, move := range moves {
+		if game.IsTerminal() || !game.ValidMove(move.Board, move.Pos) {
+			return nil, requestError{fmt.Errorf("illegal move %s", move)}
+		}
+		game.MakeMove(move.Board, move.Pos)
+	}
+	return game, nil
+}
+
+func parseMoves(fields []string) ([]match.Move, error) {
+	moves := make([]match.Move, len(fields))
+	for i, field := range fields {
+		var err error
+		if moves[i], err = match.ParseMove(field); err != nil {
+			return nil, requestError{err}
+		}
+	}
+	return moves, nil
+}
+
+func formatMoves(moves []match.Move) []string {
+	fields := make([]string, len(moves))
+	for i, move := range moves {
+		fields[i] = move.String()
+	}
+	return fields
+}
+
+// bestMoveRequest searches with the bot spec of Engine, the limits replace the ones of the spec
+// and every search ends at the maximum time of the server
+type bestMoveRequest struct {
+	positionRequest
+	Engine   string `json:"engine"`
+	MoveTime int    `json:"movetime"`
+	Depth    int    `json:"depth"`
+	Nodes    uint64 `json:"nodes"`
+}
+
+// bestMoveResponse is the last info of the search, see match.Info for the score
+type bestMoveResponse struct {
+	Move   string   `json:"move"`
+	Score  float64  `json:"score"`
+	Depth  byte     `json:"depth"`
+	Nodes  uint64   `json:"nodes"`
+	TimeMs int64    `json:"timeMs"`
+	PV     []string `json:"pv"`
+}
+
+func (s *server) bestMove(r *http.Request, request *bestMoveRequest) (*bestMoveResponse, error) {
+	game, err := request.game()
+	if err != nil {
+		return nil, err
+	}
+	if game.IsTerminal() {
+		return nil, requestError{fmt.Errorf("the game is over")}
+	}
+
+	spec := request.Engine
+	if spec == "" {
+		spec = s.engine
+	}
+	bot, err := match.ParseRemoteBot(spec)
+	if err != nil {
+		return nil, requestError{err}
+	}
+	if request.MoveTime < 0 || request.Depth < 0 || request.Depth > 254 {
+		return nil, requestError{fmt.Errorf("limits must be positive and the depth below 255")}
+	}
+
+	moveTime, nodes := s.limits(bot, request), request.Nodes
+	if bot.MCTS != nil && nodes == 0 && request.MoveTime == 0 && bot.MoveTime == 0 {
+		nodes = uint64(bot.Rounds)
+	}
+
+	var inst *instance
+	select {
+	case inst = <-s.pool:
+	case <-r.Context().Done():
+		return nil, errBusy
+	}
+	defer func() { s.pool <- inst }()
+
+	// The stored bounds depend on the heuristic of the request
+	inst.table.Reset()
+	response := &bestMoveResponse{}
+	budget := minimax.NewBudget(moveTime)
+	budget.MaxNodes = nodes
+	move, board := bot.Search(game, &inst.table, budget, func(info match.Info) {
+		response.Score, response.Depth, response.Nodes, response.PV = info.Score, info.Depth, info.Nodes, formatMoves(info.PV)
+	})
+	if !game.ValidMove(board, move) {
+		return nil, fmt.Errorf("the search found no move")
+	}
+
+	response.Move = match.Move{Board: board, Pos: move}.String()
+	response.Nodes = budget.Nodes
+	response.TimeMs = time.Since(budget.Start).Milliseconds()
+	if len(response.PV) == 0 || response.PV[0] != response.Move {
+		response.PV = []string{response.Move}
+	}
+	return response, nil
+}
+
+// limits applies the limits of the request to the bot and returns the time of the search
+func (s *server) limits(bot *match.Bot, request *bestMoveRequest) time.Duration {
+	if request.Depth > 0 {
+		// The searches stop below the depth of the bot
+		bot.Depth = byte(request.Depth + 1)
+	}
+
+	moveTime := bot.MoveTime
+	if request.MoveTime > 0 {
+		moveTime = time.Duration(request.MoveTime) * time.Millisecond
+	} else if request.Depth > 0 || request.Nodes > 0 {
+		moveTime = s.maxTime
+	}
+	if moveTime == 0 || moveTime > s.maxTime {
+		moveTime = s.maxTime
+	}
+	return moveTime
+}
+
+type legalMovesResponse struct {
+	Position string   `json:"position"`
+	Moves    []string `json:"moves"`
+	Terminal bool     `json:"terminal"`
+	Winner   string   `json:"winner,omitempty"`
+}
+
+func (s *server) legalMoves(r *http.Request, request *positionRequest) (*legalMovesResponse, error) {
+	game, err := request.game()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &legalMovesResponse{Position: match.FormatPosition(game), Moves: []string{}, Terminal: game.IsTerminal()}
+	if response.Terminal {
+		response.Winner = map[Game.Player]string{Game.Player1: "x", Game.Player2: "o", Game.Draw: "draw"}[game.WinningPlayer()]
+		return response, nil
+	}
+
+	// GetMoves shuffles the order, the moves are listed by board and square
+	var moves []match.Move
+	game.GetMoves(func(board byte, pos byte) bool {
+		moves = append(moves, match.Move{Board: board, Pos: pos})
+		return false
+	})
+	response.Moves = sortedMoves(moves)
+	return response, nil
+}
+
+func sortedMoves(moves []match.Move) []string {
+	fields := formatMoves(moves)
+	sort.Strings(fields)
+	return fields
+}
+
+// evaluateRequest scores the position with the heuristic of the Engine spec for the player to move
+type evaluateRequest struct {
+	positionRequest
+	Engine string `json:"engine"`
+}
+
+type evaluateResponse struct {
+	Position  string                  `json:"position"`
+	Player    string                  `json:"player"`
+	Breakdown Game.HeuristicBreakdown `json:"breakdown"`
+}
+
+func (s *server) evaluate(r *http.Request, request *evaluateRequest) (*evaluateResponse, error) {
+	game, err := request.game()
+	if err != nil {
+		return nil, err
+	}
+
+	spec := request.Engine
+	if spec == "" {
+		spec = s.engine
+	}
+	bot, err := match.ParseRemoteBot(spec)
+	if err != nil {
+		return nil, requestError{err}
+	}
+	if bot.Heuristic == nil {
+		return nil, requestError{fmt.Errorf("%s has no heuristic", spec)}
+	}
+
+	game.HeuristicScores = bot.Heuristic
+	player := Game.Player(game.Board[Game.PlayerBoardIndex] & 0x1)
+	return &evaluateResponse{
+		Position:  match.FormatPosition(game),
+		Player:    map[Game.Player]string{Game.Player1: "x", Game.Player2: "o"}[player],
+		Breakdown: game.Breakdown(player),
+	}, nil
+}
+
+type healthResponse struct {
+	Status    string `json:"status"`
+	Instances int    `json:"instances"`
+	Idle      int    `json:"idle"`
+}
+
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use GET"})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok", Instances: cap(s.pool), Idle: len(s.pool)})
+}
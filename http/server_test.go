@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+func newTestServer(t *testing.T, instances int) *httptest.Server {
+	s, err := newServer(instances, "mtd:depth=5,time=0s", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// call posts the request and decodes the answer into response, the status code is returned
+func call(t *testing.T, server *httptest.Server, path string, request any, response any) int {
+	body, _ := json.Marshal(request)
+	res, err := http.Post(server.URL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if err = json.NewDecoder(res.Body).Decode(response); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestBestMove(t *testing.T) {
+	server := newTestServer(t, 2)
+	response := bestMoveResponse{}
+	if status := call(t, server, "/bestmove", map[string]any{"moves": []string{"84"}, "depth": 3}, &response); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if response.Depth != 3 || response.Nodes == 0 || len(response.PV) == 0 || response.PV[0] != response.Move {
+		t.Fatalf("answered %+v", response)
+	}
+
+	move, err := match.ParseMove(response.Move)
+	if err != nil || move.Board != 4 {
+		t.Fatalf("move %q is not on board 4", response.Move)
+	}
+}
+
+func TestBestMoveInParallel(t *testing.T) {
+	server := newTestServer(t, 2)
+	var wait sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wait.Add(1)
+		go func(engine string) {
+			defer wait.Done()
+			response := bestMoveResponse{}
+			if status := call(t, server, "/bestmove", map[string]any{"engine": engine, "nodes": 2000}, &response); status != http.StatusOK {
+				t.Errorf("%s: status %d", engine, status)
+			}
+		}([]string{"mtd", "minimax", "mcts", "mtd:depth=3"}[i])
+	}
+	wait.Wait()
+}
+
+func TestRemoteSpecs(t *testing.T) {
+	server := newTestServer(t, 1)
+	for _, engine := range []string{"mtd:heuristic=/dev/zero", "mcts:minimax=9"} {
+		errResponse := errorResponse{}
+		if status := call(t, server, "/bestmove", map[string]any{"engine": engine}, &errResponse); status != http.StatusBadRequest {
+			t.Errorf("%s answered %d", engine, status)
+		}
+	}
+}
+
+func TestLegalMoves(t *testing.T) {
+	server := newTestServer(t, 1)
+	response := legalMovesResponse{}
+	if status := call(t, server, "/legalmoves", map[string]any{"position": match.StartPosition}, &response); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if len(response.Moves) != 9 || response.Moves[0] != "80" || response.Terminal {
+		t.Fatalf("start position has moves %v", response.Moves)
+	}
+
+	errResponse := errorResponse{}
+	if status := call(t, server, "/legalmoves", map[string]any{"moves": []string{"00"}}, &errResponse); status != http.StatusBadRequest || errResponse.Error == "" {
+		t.Fatalf("illegal move answered %d %+v", status, errResponse)
+	}
+	if status := call(t, server, "/legalmoves", map[string]any{"board": 3}, &errResponse); status != http.StatusBadRequest {
+		t.Fatalf("unknown field answered %d", status)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	server := newTestServer(t, 1)
+	response := evaluateResponse{}
+	if status := call(t, server, "/evaluate", map[string]any{"moves": []string{"84", "40"}}, &response); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+
+	sum := response.Breakdown.Terminal + response.Breakdown.GlobalState + response.Breakdown.Overall
+	for _, board := range response.Breakdown.Boards {
+		sum += board
+	}
+	if response.Player != "o" || sum-response.Breakdown.Total > 1e-9 || response.Breakdown.Total-sum > 1e-9 {
+		t.Fatalf("answered %+v", response)
+	}
+
+	errResponse := errorResponse{}
+	if status := call(t, server, "/evaluate", map[string]any{"engine": "mcts"}, &errResponse); status != http.StatusBadRequest {
+		t.Fatalf("MCTS answered %d", status)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	server := newTestServer(t, 3)
+	res, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	response := healthResponse{}
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil || response.Status != "ok" || response.Instances != 3 || response.Idle != 3 {
+		t.Fatalf("answered %+v, %v", response, err)
+	}
+
+	if res, err = http.Get(server.URL + "/bestmove"); err != nil || res.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /bestmove answered %v, %v", res.StatusCode, err)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/websocket"
+)
+
+// testClient is the reference client, it sends messages and waits for the states of the server
+type testClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dial(t *testing.T, server *httptest.Server) *testClient {
+	conn, err := websocket.Dial("ws" + strings.TrimPrefix(server.URL, "http") + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return &testClient{t: t, conn: conn}
+}
+
+func (c *testClient) send(message clientMessage) {
+	if err := c.conn.WriteJSON(message); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next reads the next message, errors are returned in the Result with an error type
+func (c *testClient) next() *stateMessage {
+	state := &stateMessage{}
+	message := map[string]any{}
+	data, err := c.conn.ReadMessage()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err = json.Unmarshal(data, &message); err == nil && message["type"] == "error" {
+		return &stateMessage{Type: "error", Result: message["error"].(string)}
+	}
+	if err = json.Unmarshal(data, state); err != nil {
+		c.t.Fatal(err)
+	}
+	return state
+}
+
+// until reads states until done accepts one
+func (c *testClient) until(done func(state *stateMessage) bool) *stateMessage {
+	for {
+		if state := c.next(); done(state) {
+			return state
+		}
+	}
+}
+
+func newTestServer(t *testing.T, records *syncBuffer) *httptest.Server {
+	server, _ := newTestServerOf(t, records)
+	return server
+}
+
+func newTestServerOf(t *testing.T, records *syncBuffer) (*httptest.Server, *server) {
+	s := newServer(time.Minute, 0, 2, nil)
+	if records != nil {
+		s.records = records
+	}
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(server.Close)
+	return server, s
+}
+
+type syncBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.Write(p)
+}
+
+func TestTwoClientsPlay(t *testing.T) {
+	server := newTestServer(t, nil)
+	x, o := dial(t, server), dial(t, server)
+
+	x.send(clientMessage{Type: "create", Seat: "x", Name: "first"})
+	created := x.next()
+	if created.You != "x" || created.Started {
+		t.Fatalf("created %+v", created)
+	}
+
+	o.send(clientMessage{Type: "join", Game: created.Game, Name: "second"})
+	joined := o.next()
+	if joined.You != "o" || !joined.Started || joined.ToMove != "o" || joined.Players != [2]string{"first", "second"} {
+		t.Fatalf("joined %+v", joined)
+	}
+	x.next()
+
+	// Player2 moves first
+	x.send(clientMessage{Type: "move", Move: "84"})
+	if state := x.next(); state.Type != "error" {
+		t.Fatalf("x moved out of turn: %+v", state)
+	}
+	o.send(clientMessage{Type: "move", Move: "84"})
+	state := x.next()
+	if len(state.Moves) != 1 || state.ToMove != "x" || state.Clock["o"] > 60000 {
+		t.Fatalf("after the move %+v", state)
+	}
+	o.next()
+
+	watcher := dial(t, server)
+	watcher.send(clientMessage{Type: "join", Game: created.Game})
+	if state = watcher.next(); state.You != "" || len(state.Moves) != 1 {
+		t.Fatalf("watcher got %+v", state)
+	}
+	watcher.send(clientMessage{Type: "move", Move: "40"})
+	if state = watcher.next(); state.Type != "error" {
+		t.Fatalf("watcher moved: %+v", state)
+	}
+}
+
+func TestBotGamesRunConcurrently(t *testing.T) {
+	records := &syncBuffer{}
+	server := newTestServer(t, records)
+
+	var wait sync.WaitGroup
+	for _, engine := range []string{"mtd:depth=3,time=0s", "minimax:depth=2,time=0s", "mcts:rounds=300,time=0s", "mcts:rounds=300,time=0s,transpositions=true"} {
+		wait.Add(1)
+		go func(engine string) {
+			defer wait.Done()
+			c := dial(t, server)
+			c.send(clientMessage{Type: "create", Seat: "x", Engine: engine})
+
+			// The client plays its first legal move until the game ends
+			state := c.next()
+			for state.Result == "" {
+				if state.Type == "error" {
+					t.Errorf("%s: %s", engine, state.Result)
+					return
+				}
+				if state.Started && state.ToMove == "x" {
+					game, err := match.ParsePosition(state.Position)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					var move match.Move
+					game.GetMoves(func(board byte, pos byte) bool {
+						move = match.Move{Board: board, Pos: pos}
+						return true
+					})
+					c.send(clientMessage{Type: "move", Move: move.String()})
+				}
+				state = c.next()
+			}
+			if state.Players[Game.Player2] != engine {
+				t.Errorf("bot is named %q", state.Players[Game.Player2])
+			}
+		}(engine)
+	}
+	wait.Wait()
+
+	records.lock.Lock()
+	defer records.lock.Unlock()
+	read, err := match.ReadRecords(&records.buffer)
+	if err != nil || len(read) != 4 {
+		t.Fatalf("%d records were written, %v", len(read), err)
+	}
+}
+
+func TestClockFlags(t *testing.T) {
+	server := newTestServer(t, nil)
+	c := dial(t, server)
+	c.send(clientMessage{Type: "create", Seat: "o", Engine: "mtd:depth=2", Clock: 50})
+
+	state := c.until(func(state *stateMessage) bool { return state.Result != "" })
+	if state.Result != "1-0" || state.Termination != "time forfeit" || state.Clock["o"] != 0 {
+		t.Fatalf("flagged %+v", state)
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	server := newTestServer(t, nil)
+	c := dial(t, server)
+	for _, message := range []clientMessage{
+		{Type: "move", Move: "84"},
+		{Type: "join", Game: "g99"},
+		{Type: "create", Seat: "y"},
+		{Type: "create", Engine: "alphabeta"},
+		{Type: "create", Engine: "mtd:heuristic=/dev/zero"},
+		{Type: "create", Engine: "mcts:minimax=9"},
+		{Type: "create", Clock: 1 << 62},
+		{Type: "create", Clock: 1000, Increment: int(2 * time.Hour / time.Millisecond)},
+		{Type: "resign"},
+	} {
+		c.send(message)
+		if state := c.next(); state.Type != "error" {
+			t.Fatalf("%+v answered %+v", message, state)
+		}
+	}
+}
+
+func TestUnjoinedGameExpires(t *testing.T) {
+	server, s := newTestServerOf(t, nil)
+	s.joinTimeout = 50 * time.Millisecond
+	c := dial(t, server)
+	c.send(clientMessage{Type: "create"})
+	created := c.next()
+
+	if state := c.next(); state.Type != "error" {
+		t.Fatalf("the waiting game got %+v", state)
+	}
+	s.lock.Lock()
+	_, exists := s.games[created.Game]
+	s.lock.Unlock()
+	if exists {
+		t.Fatalf("game %s is still hosted", created.Game)
+	}
+}
+
+func TestBotSearchIsCapped(t *testing.T) {
+	server, s := newTestServerOf(t, nil)
+	s.maxTime = 100 * time.Millisecond
+	c := dial(t, server)
+	start := time.Now()
+	c.send(clientMessage{Type: "create", Seat: "x", Engine: "mtd:depth=60,time=1h"})
+
+	c.until(func(state *stateMessage) bool { return len(state.Moves) == 1 })
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the bot searched %s", elapsed)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+	"github.com/FabianPetersen/UltimateTicTacToe/timing"
+)
+
+// maxTableNodes is the most positions a bot keeps in its transposition table between moves
+const maxTableNodes = 1 << 20
+
+var seatNames = [2]string{Game.Player1: "x", Game.Player2: "o"}
+
+func parseSeat(name string) (Game.Player, error) {
+	for seat, seatName := range seatNames {
+		if name == seatName {
+			return Game.Player(seat), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown seat %q, want x or o", name)
+}
+
+// liveGame is a game between two seats, a seat is taken by a client or by the bot of the game.
+// The clock of the player to move runs once both seats are taken
+type liveGame struct {
+	id   string
+	lock sync.Mutex
+
+	game   *Game.Game
+	record *match.Record
+
+	seats    [2]*client
+	watchers map[*client]bool
+
+	// bot plays botSeat, nil when two clients play. The alpha-beta searches have their own transposition table.
+	// A search takes a slot of searches while it runs and ends after maxTime whatever the spec of the bot asks.
+	// botBudget is the budget of the running search and is stopped when the game ends
+	bot       *match.Bot
+	botSeat   Game.Player
+	table     *minimax.Storage
+	searches  chan struct{}
+	maxTime   time.Duration
+	botBudget *minimax.Budget
+
+	// control is the time control of both seats, clock the time left
+	control   timing.Control
+	clock     [2]time.Duration
+	turnStart time.Time
+	timer     *time.Timer
+
+	started bool
+	over    bool
+
+	// onEnd is called with the finished record while the game is locked
+	onEnd func(record *match.Record)
+}
+
+func newLiveGame(id string, clock time.Duration, increment time.Duration) *liveGame {
+	return &liveGame{
+		id:       id,
+		game:     Game.NewGame(),
+		record:   &match.Record{Players: [2]string{"", ""}},
+		watchers: map[*client]bool{},
+		control:  timing.Control{Total: clock, Increment: increment},
+		clock:    [2]time.Duration{clock, clock},
+	}
+}
+
+func (g *liveGame) toMove() Game.Player {
+	return Game.Player(g.game.Board[Game.PlayerBoardIndex] & 0x1)
+}
+
+// remaining is the time left on the clock of seat at now
+func (g *liveGame) remaining(seat Game.Player, now time.Time) time.Duration {
+	if g.started && !g.over && seat == g.toMove() {
+		return g.clock[seat] - now.Sub(g.turnStart)
+	}
+	return g.clock[seat]
+}
+
+// sit gives the seat to the client, or to the bot when c is nil, and starts the game once both seats are taken
+func (g *liveGame) sit(c *client, seat Game.Player, name string) error {
+	if g.seats[seat] != nil || (g.bot != nil && g.botSeat == seat) {
+		return fmt.Errorf("seat %s is taken", seatNames[seat])
+	}
+	g.seats[seat] = c
+	g.record.Players[seat] = name
+	if c != nil {
+		g.watchers[c] = true
+	}
+
+	other := seat ^ 0x1
+	if g.seats[other] != nil || (g.bot != nil && g.botSeat == other) {
+		g.start()
+	}
+	return nil
+}
+
+func (g *liveGame) start() {
+	g.started = true
+	g.startTurn(time.Now())
+}
+
+// startTurn starts the clock of the player to move and lets the bot search when it is its turn
+func (g *liveGame) startTurn(now time.Time) {
+	g.turnStart = now
+	ply := len(g.record.Moves)
+	seat := g.toMove()
+	g.timer = time.AfterFunc(g.clock[seat], func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		if !g.over && len(g.record.Moves) == ply {
+			g.clock[seat] = 0
+			g.record.Forfeit = match.TIME_FORFEIT
+			g.finish()
+		}
+	})
+
+	if g.bot != nil && seat == g.botSeat {
+		go g.botMove(ply)
+	}
+}
+
+// move plays the move of seat, the time of the turn is taken from its clock
+func (g *liveGame) move(seat Game.Player, move match.Move) error {
+	switch {
+	case !g.started:
+		return fmt.Errorf("the game has not started")
+	case g.over:
+		return fmt.Errorf("the game is over")
+	case seat != g.toMove():
+		return fmt.Errorf("it is not your turn")
+	case !g.game.ValidMove(move.Board, move.Pos):
+		return fmt.Errorf("illegal move %s", move)
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(g.turnStart)
+	g.timer.Stop()
+	if g.clock[seat] <= elapsed {
+		// The timer is about to flag the player
+		g.clock[seat] = 0
+		g.record.Forfeit = match.TIME_FORFEIT
+		g.finish()
+		return fmt.Errorf("the time ran out")
+	}
+	g.clock[seat] += g.control.Increment - elapsed
+
+	g.game.MakeMove(move.Board, move.Pos)
+	g.record.Moves = append(g.record.Moves, move)
+	g.record.Time[seat] += elapsed
+	g.record.Searches[seat]++
+	if g.game.IsTerminal() {
+		g.finish()
+		return nil
+	}
+
+	g.startTurn(now)
+	g.broadcast()
+	return nil
+}
+
+// botMove searches the position of ply without holding the lock, the move is dropped when the game went on
+func (g *liveGame) botMove(ply int) {
+	g.searches <- struct{}{}
+	defer func() { <-g.searches }()
+
+	g.lock.Lock()
+	if g.over || len(g.record.Moves) != ply {
+		g.lock.Unlock()
+		return
+	}
+	state := g.game.Copy()
+	// The move time of the bot caps the time allocated from its clock
+	bot := *g.bot
+	bot.Clock = g.control
+	budget := bot.Budget(&state, g.remaining(g.botSeat, time.Now()))
+	if budget.MaxTime > g.maxTime {
+		budget.MaxTime = g.maxTime
+	}
+	if budget.SoftTime > budget.MaxTime {
+		budget.SoftTime = budget.MaxTime
+	}
+	g.botBudget = budget
+	if g.table != nil && g.table.Count() > maxTableNodes {
+		g.table.Reset()
+	}
+	g.lock.Unlock()
+
+	move, board := bot.Search(&state, g.table, budget, nil)
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.botBudget = nil
+	if g.over || len(g.record.Moves) != ply {
+		return
+	}
+	if !g.game.ValidMove(board, move) {
+		// A search that ran out of time before its first iteration has no move
+		g.game.GetMoves(func(b byte, m byte) bool {
+			board, move = b, m
+			return true
+		})
+	}
+	_ = g.move(g.botSeat, match.Move{Board: board, Pos: move})
+}
+
+func (g *liveGame) finish() {
+	g.over = true
+	g.timer.Stop()
+	if g.botBudget != nil {
+		g.botBudget.Stop()
+	}
+	g.record.Final = g.game
+	if g.onEnd != nil {
+		g.onEnd(g.record)
+	}
+	g.broadcast()
+}
+
+// state is the game as c sees it
+func (g *liveGame) state(c *client, now time.Time) *stateMessage {
+	state := &stateMessage{
+		Type:     "state",
+		Game:     g.id,
+		Players:  g.record.Players,
+		Position: match.FormatPosition(g.game),
+		Moves:    make([]string, len(g.record.Moves)),
+		ToMove:   seatNames[g.toMove()],
+		Clock:    map[string]int64{},
+		Started:  g.started,
+	}
+	for i, move := range g.record.Moves {
+		state.Moves[i] = move.String()
+	}
+	for seat, name := range seatNames {
+		state.Clock[name] = g.remaining(Game.Player(seat), now).Milliseconds()
+		if g.seats[seat] == c {
+			state.You = name
+		}
+	}
+	if g.over {
+		state.Result = g.record.Result()
+		state.Termination = string(g.record.Forfeit)
+	}
+	return state
+}
+
+// broadcast sends the state to every watcher, a watcher that can not keep up is disconnected
+func (g *liveGame) broadcast() {
+	now := time.Now()
+	for c := range g.watchers {
+		if !c.push(g.state(c, now)) {
+			delete(g.watchers, c)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+)
+
+// live hosts games over WebSocket at /ws. A client sends {"type": "create", "seat": "x", "engine": "mtd:depth=9"}
+// to play the bot, or creates a game without an engine and a second client sends {"type": "join", "game": "g1"}.
+// Moves are sent as {"type": "move", "move": "84"} and after every change everyone in the game receives the state
+// with the position, the moves and the clocks. Clients joining a started game watch it
+func main() {
+	addr := flag.String("addr", "localhost:8081", "address to listen on")
+	clock := flag.Duration("clock", time.Minute*5, "time per player of games that set no clock")
+	increment := flag.Duration("increment", time.Second*2, "time added after every move of games that set no clock")
+	maxClock := flag.Duration("max-clock", maxClock, "longest clock and increment a game may set")
+	maxTime := flag.Duration("max-time", maxTime, "longest search of a bot")
+	searches := flag.Int("searches", runtime.NumCPU(), "bot searches running at the same time")
+	recordsPath := flag.String("records", "", "file every finished game is appended to in the record format")
+	flag.Parse()
+	if *clock <= 0 || *increment < 0 || *searches < 1 || *maxTime <= 0 {
+		log.Fatalln("the clock and the search time must be positive, the increment not negative and at least one search must run")
+	}
+	if *clock > *maxClock || *increment > *maxClock {
+		log.Fatalln("the clock and the increment must be at most", *maxClock)
+	}
+
+	s := newServer(*clock, *increment, *searches, nil)
+	s.maxClock, s.maxTime = *maxClock, *maxTime
+	if *recordsPath != "" {
+		records, err := os.OpenFile(*recordsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer records.Close()
+		s.records = records
+	}
+
+	log.Println("listening on", *addr)
+	log.Fatalln(http.ListenAndServe(*addr, s.handler()))
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+	"github.com/FabianPetersen/UltimateTicTacToe/websocket"
+)
+
+// clientMessage is sent by the clients, one connection plays or watches one game
+type clientMessage struct {
+	// Type is create, join or move
+	Type string `json:"type"`
+
+	// Game is the game to join
+	Game string `json:"game,omitempty"`
+
+	// Seat is the seat the creator takes, x or o. Engine is the bot spec of the other seat,
+	// without one the game waits for a second client. Clock and Increment are in milliseconds
+	Seat      string `json:"seat,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Engine    string `json:"engine,omitempty"`
+	Clock     int    `json:"clock,omitempty"`
+	Increment int    `json:"increment,omitempty"`
+
+	Move string `json:"move,omitempty"`
+}
+
+// stateMessage is sent to everyone in the game after every change, the clocks are in milliseconds
+type stateMessage struct {
+	Type        string           `json:"type"`
+	Game        string           `json:"game"`
+	You         string           `json:"you,omitempty"`
+	Players     [2]string        `json:"players"`
+	Position    string           `json:"position"`
+	Moves       []string         `json:"moves"`
+	ToMove      string           `json:"toMove"`
+	Clock       map[string]int64 `json:"clock"`
+	Started     bool             `json:"started"`
+	Result      string           `json:"result,omitempty"`
+	Termination string           `json:"termination,omitempty"`
+}
+
+type errorMessage struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// sendBuffer is the amount of messages a client may fall behind before it is disconnected
+const sendBuffer = 64
+
+// client is a connection, its messages are written by its own goroutine in the order they were pushed
+type client struct {
+	conn *websocket.Conn
+	game *liveGame
+
+	lock   sync.Mutex
+	send   chan any
+	closed bool
+}
+
+// push queues a message and reports false when the client fell behind and was disconnected
+func (c *client) push(message any) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return false
+	}
+
+	select {
+	case c.send <- message:
+		return true
+	default:
+		c.closed = true
+		close(c.send)
+		return false
+	}
+}
+
+// close ends the writer once the queued messages are written
+func (c *client) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+func (c *client) write() {
+	for message := range c.send {
+		if err := c.conn.WriteJSON(message); err != nil {
+			break
+		}
+	}
+	_ = c.conn.Close()
+}
+
+const (
+	// joinTimeout is how long a game without an engine waits for a second client before it is dropped
+	joinTimeout = 10 * time.Minute
+
+	// maxClock is the longest clock and increment a client may set, maxTime the longest search of a bot
+	maxClock = time.Hour
+	maxTime  = 10 * time.Second
+)
+
+// server hosts the live games, every game is locked on its own so games run concurrently.
+// searches holds a slot for every running bot search, a bot waits for a free slot on its own clock.
+// The clocks a client sets are at most maxClock and no bot searches longer than maxTime
+type server struct {
+	lock  sync.Mutex
+	games map[string]*liveGame
+	next  int
+
+	clock       time.Duration
+	increment   time.Duration
+	maxClock    time.Duration
+	maxTime     time.Duration
+	joinTimeout time.Duration
+	searches    chan struct{}
+
+	recordsLock sync.Mutex
+	records     io.Writer
+}
+
+// newServer hosts games of clock and increment unless they set their own, up to searches bots search at the same time
+func newServer(clock time.Duration, increment time.Duration, searches int, records io.Writer) *server {
+	return &server{
+		games:       map[string]*liveGame{},
+		clock:       clock,
+		increment:   increment,
+		maxClock:    maxClock,
+		maxTime:     maxTime,
+		joinTimeout: joinTimeout,
+		searches:    make(chan struct{}, searches),
+		records:     records,
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.serveWebSocket)
+	return mux
+}
+
+func (s *server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+
+	c := &client{conn: conn, send: make(chan any, sendBuffer)}
+	go c.write()
+	defer s.leave(c)
+
+	for {
+		message := clientMessage{}
+		if err = conn.ReadJSON(&message); err != nil {
+			return
+		}
+		if err = s.handle(c, &message); err != nil {
+			if !c.push(errorMessage{Type: "error", Error: err.Error()}) {
+				return
+			}
+		}
+	}
+}
+
+func (s *server) handle(c *client, message *clientMessage) error {
+	switch message.Type {
+	case "create":
+		return s.create(c, message)
+	case "join":
+		return s.join(c, message)
+	case "move":
+		if c.game == nil {
+			return fmt.Errorf("create or join a game first")
+		}
+		move, err := match.ParseMove(message.Move)
+		if err != nil {
+			return err
+		}
+
+		g := c.game
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		for seat, player := range g.seats {
+			if player == c {
+				return g.move(Game.Player(seat), move)
+			}
+		}
+		return fmt.Errorf("spectators can not move")
+	}
+	return fmt.Errorf("unknown message type %q", message.Type)
+}
+
+func (s *server) create(c *client, message *clientMessage) error {
+	if c.game != nil {
+		return fmt.Errorf("already in game %s", c.game.id)
+	}
+	seat := Game.Player1
+	if message.Seat != "" {
+		var err error
+		if seat, err = parseSeat(message.Seat); err != nil {
+			return err
+		}
+	}
+	if message.Clock < 0 || message.Increment < 0 {
+		return fmt.Errorf("clock and increment must not be negative")
+	}
+	if limit := s.maxClock.Milliseconds(); int64(message.Clock) > limit || int64(message.Increment) > limit {
+		return fmt.Errorf("clock and increment must be at most %s", s.maxClock)
+	}
+
+	clock, increment := s.clock, s.increment
+	if message.Clock > 0 {
+		clock, increment = time.Duration(message.Clock)*time.Millisecond, time.Duration(message.Increment)*time.Millisecond
+	}
+
+	s.lock.Lock()
+	s.next++
+	g := newLiveGame("g"+strconv.Itoa(s.next), clock, increment)
+	s.games[g.id] = g
+	s.lock.Unlock()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.onEnd = s.end
+	if message.Engine != "" {
+		// The spec comes from the client, it may not read files of the server
+		bot, err := match.ParseRemoteBot(message.Engine)
+		if err != nil {
+			s.remove(g)
+			return err
+		}
+		g.bot, g.botSeat, g.searches, g.maxTime = bot, seat^0x1, s.searches, s.maxTime
+		if bot.MCTS == nil {
+			table := minimax.NewStorage()
+			g.table = &table
+		}
+		g.record.Players[seat^0x1] = bot.Name
+	} else {
+		time.AfterFunc(s.joinTimeout, func() { s.expire(g) })
+	}
+
+	c.game = g
+	_ = g.sit(c, seat, message.Name)
+	g.broadcast()
+	return nil
+}
+
+func (s *server) join(c *client, message *clientMessage) error {
+	if c.game != nil {
+		return fmt.Errorf("already in game %s", c.game.id)
+	}
+	s.lock.Lock()
+	g, exists := s.games[message.Game]
+	s.lock.Unlock()
+	if !exists {
+		return fmt.Errorf("unknown game %q", message.Game)
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	c.game = g
+	for _, seat := range []Game.Player{Game.Player1, Game.Player2} {
+		if !g.started && g.sit(c, seat, message.Name) == nil {
+			g.broadcast()
+			return nil
+		}
+	}
+
+	// Both seats are taken, the client watches
+	g.watchers[c] = true
+	g.broadcast()
+	return nil
+}
+
+// leave stops sending to the client, its seat stays taken and its clock keeps running
+func (s *server) leave(c *client) {
+	if g := c.game; g != nil {
+		g.lock.Lock()
+		delete(g.watchers, c)
+		g.lock.Unlock()
+	}
+	c.close()
+}
+
+// end writes the record of a finished game, the game can no longer be joined
+func (s *server) end(record *match.Record) {
+	s.lock.Lock()
+	for id, g := range s.games {
+		if g.record == record {
+			delete(s.games, id)
+		}
+	}
+	s.lock.Unlock()
+
+	if s.records == nil {
+		return
+	}
+	s.recordsLock.Lock()
+	defer s.recordsLock.Unlock()
+	if err := record.Write(s.records); err != nil {
+		log.Println("records:", err)
+	}
+}
+
+// expire drops a game nobody joined, its clients are told and disconnected
+func (s *server) expire(g *liveGame) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.started {
+		return
+	}
+	s.remove(g)
+	g.over = true
+	for c := range g.watchers {
+		c.push(errorMessage{Type: "error", Error: fmt.Sprintf("nobody joined game %s", g.id)})
+		c.close()
+	}
+	g.watchers = map[*client]bool{}
+}
+
+func (s *server) remove(g *liveGame) {
+	s.lock.Lock()
+	delete(s.games, g.id)
+	s.lock.Unlock()
+}
@@ -1,23 +1,50 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/FabianPetersen/UltimateTicTacToe/Game"
 	"github.com/FabianPetersen/UltimateTicTacToe/bns"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
 	"github.com/FabianPetersen/UltimateTicTacToe/mtd"
+	"github.com/FabianPetersen/UltimateTicTacToe/timing"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"golang.org/x/image/colornames"
 	"image/color"
+	"io"
 	"log"
 	"math/rand"
+	"os"
 	"time"
 )
 
 type GameEngine struct {
 	game         *Game.Game
 	restartCount int
+	lastSearch   *gmcts.MCTS
+	heuristic    *Game.HeuristicScores
+	ponder       *pondering
+}
+
+// pondering is the search of the bot during the turn of the human. MTD(f) searches the expected reply of the
+// principal variation, MCTS keeps searching the tree of the last search for every reply
+type pondering struct {
+	budget *minimax.Budget
+	done   chan struct{}
+
+	// expected is the board and square of the reply searched by MTD(f), answer is the best move after it
+	expected [2]byte
+	answer   [2]byte
+	hit      bool
+}
+
+func (g *GameEngine) newGame() *Game.Game {
+	game := Game.NewGame()
+	game.HeuristicScores = g.heuristic
+	return game
 }
 
 var boards = [][2]float64{
@@ -58,6 +85,13 @@ const (
 )
 
 var activeBotAlgorithm = MTD_F
+var mctsConfig = gmcts.DefaultConfig()
+
+// botControl is the time of every bot move
+var botControl = timing.Control{MoveTime: 100 * time.Millisecond}
+
+// botPonders lets the bot search while the human moves
+var botPonders = true
 
 const windowSizeW = 320 * 2
 const windowSizeH = 320 * 2
@@ -71,8 +105,20 @@ var randSource = rand.New(rand.NewSource(time.Now().Unix()))
 const HUMAN = true
 
 func (g *GameEngine) Update() error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) && g.lastSearch != nil {
+		// The tree can not be read while it grows
+		g.stopPonder()
+		exportTree(g.lastSearch)
+	}
+
 	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
-		g.game = Game.NewGame()
+		g.stopPonder()
+		g.ponder = nil
+		if g.lastSearch != nil {
+			g.lastSearch.Close()
+			g.lastSearch = nil
+		}
+		g.game = g.newGame()
 		return nil
 	}
 
@@ -83,6 +129,7 @@ func (g *GameEngine) Update() error {
 			boardIndex, posIndex := g.getBoardPos(float64(x), float64(y))
 			if g.game.ValidMove(byte(boardIndex), byte(posIndex)) {
 				g.game.MakeMove(byte(boardIndex), byte(posIndex))
+				g.humanMoved(byte(boardIndex), byte(posIndex))
 				/*
 					g.game.UnMakeMove(posIndex, byte(boardIndex))
 					for i := 0; i < 4; i++ {
@@ -99,18 +146,97 @@ func (g *GameEngine) Update() error {
 		// Check if the board is empty
 		if g.game.ValidMove(board, botmove) {
 			g.game.MakeMove(board, botmove)
+			g.startPonder(board, botmove)
 		}
 	}
 
 	return nil
 }
 
+// startPonder searches during the turn of the human after the bot played the move
+func (g *GameEngine) startPonder(board byte, move byte) {
+	g.ponder = nil
+	if !botPonders || g.game.IsTerminal() {
+		return
+	}
+	if activeBotAlgorithm == MONTE_CARLO_TREE_SEARCH && g.lastSearch != nil {
+		g.lastSearch.Advance(board, move)
+	}
+
+	p := &pondering{budget: minimax.NewPonderBudget(), done: make(chan struct{})}
+	switch activeBotAlgorithm {
+	case MTD_F:
+		boards, moves := minimax.PrincipalVariation(&minimax.TranspositionTable, g.game, 1)
+		if len(moves) == 0 {
+			return
+		}
+		p.expected = [2]byte{boards[0], moves[0]}
+		state := g.game.Copy()
+		state.HeuristicScores = g.game.HeuristicScores
+		state.MakeMove(boards[0], moves[0])
+		if state.IsTerminal() {
+			return
+		}
+		go func() {
+			defer close(p.done)
+			p.answer[1], p.answer[0] = mtd.IterativeDeepeningBudget(&minimax.TranspositionTable, &state, 15, p.budget, nil)
+		}()
+
+	case MONTE_CARLO_TREE_SEARCH:
+		if g.lastSearch == nil {
+			return
+		}
+		go func(mcts *gmcts.MCTS) {
+			defer close(p.done)
+			mcts.SearchBudget(p.budget)
+		}(g.lastSearch)
+
+	default:
+		return
+	}
+	g.ponder = p
+}
+
+// humanMoved ends the pondering, the search of the expected reply continues on the time of the bot move.
+// After any other reply MTD(f) searches again with the table, MCTS continues below the reply
+func (g *GameEngine) humanMoved(board byte, move byte) {
+	if g.ponder != nil && activeBotAlgorithm == MTD_F && g.ponder.expected == [2]byte{board, move} {
+		g.ponder.hit = true
+		g.ponder.budget.PonderHit(botControl.Budget(0, g.game))
+		return
+	}
+
+	g.stopPonder()
+	g.ponder = nil
+	if botPonders && activeBotAlgorithm == MONTE_CARLO_TREE_SEARCH && g.lastSearch != nil {
+		g.lastSearch.Advance(board, move)
+	}
+}
+
+// stopPonder ends the pondering and waits for the search
+func (g *GameEngine) stopPonder() {
+	if g.ponder != nil {
+		g.ponder.budget.Stop()
+		<-g.ponder.done
+	}
+}
+
 func (g *GameEngine) getBotMove() (byte, byte) {
 	var botMove byte = 254
 	var botBoard byte = 254
+	if g.ponder != nil && g.ponder.hit {
+		// The search of the expected reply is the search of this move
+		<-g.ponder.done
+		botBoard, botMove = g.ponder.answer[0], g.ponder.answer[1]
+		g.ponder = nil
+		if g.game.ValidMove(botBoard, botMove) {
+			return botBoard, botMove
+		}
+	}
+
 	switch activeBotAlgorithm {
 	case MTD_F:
-		botMove, botBoard = mtd.IterativeDeepeningTime(g.game, 15, 100*time.Millisecond)
+		botMove, botBoard = mtd.IterativeDeepeningBudget(&minimax.TranspositionTable, g.game, 15, botControl.Budget(0, g.game), nil)
 
 	case MINIMAX_ITERATIVE:
 		//mini := minimax.NewMinimax(g.game)
@@ -119,18 +245,37 @@ func (g *GameEngine) getBotMove() (byte, byte) {
 	case BNS:
 		botMove = bns.IterativeDeepening(g.game, 10)
 
-		/*
-			case MONTE_CARLO_TREE_SEARCH:
-				mcts := gmcts.NewMCTS(g.game)
-				tree := mcts.SpawnTree(gmcts.ROBUST_CHILD, gmcts.SMITSIMAX)
-				tree.SearchTime(timeToSearch)
-				mcts.AddTree(tree)
-				botMove, _ = mcts.BestAction()
-		*/
+	case MONTE_CARLO_TREE_SEARCH:
+		// The tree follows the game while the bot ponders
+		budget := botControl.Budget(0, g.game)
+		if g.lastSearch == nil || !botPonders {
+			if g.lastSearch != nil {
+				g.lastSearch.Close()
+			}
+			g.lastSearch = gmcts.NewMCTS(g.game, mctsConfig)
+		}
+		g.lastSearch.SearchBudget(budget)
+		botMove, botBoard = g.lastSearch.BestAction()
 	}
 	return botBoard, botMove
 }
 
+// exportTree writes the top of the last MCTS search to mcts_tree.dot and mcts_tree.json
+func exportTree(mcts *gmcts.MCTS) {
+	tree := mcts.Export(gmcts.ExportOptions{MaxDepth: 3, MinVisits: 20})
+	for path, write := range map[string]func(io.Writer) error{"mcts_tree.dot": tree.WriteDOT, "mcts_tree.json": tree.WriteJSON} {
+		f, err := os.Create(path)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if err = write(f); err != nil {
+			log.Println(err)
+		}
+		_ = f.Close()
+	}
+}
+
 func (g *GameEngine) getBoardPos(clickX float64, clickY float64) (boardIndex int, posIndex int) {
 	for i, boardPos := range boards {
 		x, y := boardPos[0]+1, boardPos[1]+1
@@ -242,12 +387,25 @@ func (g *GameEngine) Layout(outsideWidth, outsideHeight int) (screenWidth, scree
 }
 
 func main() {
+	heuristicPath := flag.String("heuristic", "", "weights or elite file used by the bot")
+	heuristicIndex := flag.Int("heuristic-index", Game.BestHeuristic, "weight set in the heuristic file, -1 selects the highest fitness")
+	flag.Parse()
+
+	heuristic := Game.DefaultHeuristic()
+	if *heuristicPath != "" {
+		var err error
+		if heuristic, err = Game.LoadHeuristic(*heuristicPath, *heuristicIndex); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	ebiten.SetWindowSize(windowSizeW, windowSizeH)
 	ebiten.SetWindowTitle("Ultimate Tic-Tac-Toe")
 	gameEngine := &GameEngine{
-		Game.NewGame(),
-		5,
+		restartCount: 5,
+		heuristic:    heuristic,
 	}
+	gameEngine.game = gameEngine.newGame()
 
 	if err := ebiten.RunGame(gameEngine); err != nil {
 		log.Fatal(err)
@@ -0,0 +1,136 @@
+package match
+
+import (
+	"math"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+	"github.com/FabianPetersen/UltimateTicTacToe/mtd"
+	"github.com/FabianPetersen/UltimateTicTacToe/timing"
+)
+
+// noTimeLimit bounds the searches of a bot without a move time
+const noTimeLimit = time.Duration(math.MaxInt64)
+
+// Algorithm is the alpha-beta search of a bot without an MCTS config
+type Algorithm byte
+
+const (
+	MTD_F   Algorithm = 0
+	MINIMAX Algorithm = 1
+)
+
+// Bot is one side of a game, it searches with MCTS when an MCTS config is set and with the Algorithm otherwise.
+// Without a MoveTime the alpha-beta searches are bounded by Depth and MCTS by Rounds,
+// after Game.Seed such a bot always makes the same move
+type Bot struct {
+	Name      string
+	Algorithm Algorithm
+	Heuristic *Game.HeuristicScores
+	MCTS      *gmcts.MCTSConfig
+	Depth     byte
+	Rounds    int
+	MoveTime  time.Duration
+
+	// Clock is the time control of games played with a clock, the time of every move is allocated from what is left.
+	// Without a Total the bot searches MoveTime per move
+	Clock timing.Control
+}
+
+// Info is the progress of a search, Score is the heuristic value for the player to move of the alpha-beta
+// searches and the win rate of the best move for MCTS. Depth is 0 for MCTS
+type Info struct {
+	Depth byte
+	Score float64
+	Nodes uint64
+	Time  time.Duration
+	PV    []Move
+}
+
+// pvLength is the most moves reported in the principal variation
+const pvLength = 16
+
+// BestMove searches the position with the heuristic of the bot, the table is only used by the alpha-beta searches
+func (b *Bot) BestMove(game *Game.Game, table *minimax.Storage) (byte, byte) {
+	return b.BestMoveClock(game, table, b.Clock.Total)
+}
+
+// BestMoveClock is BestMove with remaining left on the clock of a bot with a Clock
+func (b *Bot) BestMoveClock(game *Game.Game, table *minimax.Storage, remaining time.Duration) (byte, byte) {
+	return b.Search(game, table, b.Budget(game, remaining), nil)
+}
+
+// Budget is the budget of the next move with remaining left on the clock of a bot with a Clock, the clock caps
+// the limits of the bot. The bot searches MoveTime, without a MoveTime MCTS searches Rounds and the alpha-beta
+// searches search up to Depth
+func (b *Bot) Budget(game *Game.Game, remaining time.Duration) *minimax.Budget {
+	var budget *minimax.Budget
+	switch {
+	case b.Clock.Total > 0:
+		control := b.Clock
+		if control.MoveTime == 0 {
+			control.MoveTime = b.MoveTime
+		}
+		budget = control.Budget(remaining, game)
+	case b.MoveTime > 0:
+		budget = minimax.NewBudget(b.MoveTime)
+	default:
+		budget = minimax.NewBudget(noTimeLimit)
+	}
+
+	if b.MCTS != nil && b.MoveTime == 0 {
+		budget.MaxNodes = uint64(b.Rounds)
+	}
+	return budget
+}
+
+// Search is BestMove until the budget set by the caller is exhausted, MCTS counts its rounds as nodes and draws
+// its playouts from the sequence of the position, so games searched in parallel do not change each other.
+// Unless it is nil report is called after every finished depth of the alpha-beta searches and once after MCTS
+func (b *Bot) Search(game *Game.Game, table *minimax.Storage, budget *minimax.Budget, report func(Info)) (byte, byte) {
+	state := game.Copy()
+	state.HeuristicScores = b.Heuristic
+
+	if b.MCTS != nil {
+		mcts := gmcts.NewMCTS(&state, *b.MCTS)
+		defer mcts.Close()
+		mcts.SeedPlayouts()
+		return b.SearchTree(mcts, budget, report)
+	}
+
+	var iteration func(minimax.Iteration)
+	if report != nil {
+		iteration = func(i minimax.Iteration) {
+			boards, moves := minimax.PrincipalVariation(table, &state, pvLength)
+			// The stored root can be a symmetric position, the move of the search is always right
+			if len(moves) == 0 || boards[0] != i.Board || moves[0] != i.Move {
+				boards, moves = []byte{i.Board}, []byte{i.Move}
+			}
+			report(Info{Depth: i.Depth, Score: i.Score, Nodes: budget.Nodes, Time: time.Since(budget.Start), PV: pv(boards, moves)})
+		}
+	}
+	if b.Algorithm == MINIMAX {
+		return minimax.IterativeDeepeningBudget(table, &state, b.Depth, budget, iteration)
+	}
+	return mtd.IterativeDeepeningBudget(table, &state, b.Depth, budget, iteration)
+}
+
+// SearchTree is Search of an MCTS bot on a tree that is kept between searches, see gmcts.MCTS.Advance
+func (b *Bot) SearchTree(mcts *gmcts.MCTS, budget *minimax.Budget, report func(Info)) (byte, byte) {
+	mcts.SearchBudget(budget)
+	if report != nil {
+		boards, moves, winRate := mcts.PrincipalVariation(pvLength)
+		report(Info{Score: float64(winRate), Nodes: budget.Nodes, Time: time.Since(budget.Start), PV: pv(boards, moves)})
+	}
+	return mcts.BestAction()
+}
+
+func pv(boards []byte, moves []byte) []Move {
+	variation := make([]Move, len(moves))
+	for i := range moves {
+		variation[i] = Move{Board: boards[i], Pos: moves[i]}
+	}
+	return variation
+}
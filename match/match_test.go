@@ -0,0 +1,133 @@
+package match
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+)
+
+func TestRandomOpeningIsSeeded(t *testing.T) {
+	first := RandomOpening(rand.New(rand.NewSource(3)), 6)
+	second := RandomOpening(rand.New(rand.NewSource(3)), 6)
+	if len(first) != 6 {
+		t.Fatalf("opening has %d moves, want 6", len(first))
+	}
+
+	game := Game.NewGame()
+	for i, move := range first {
+		if move != second[i] {
+			t.Fatalf("move %d differs for the same seed, %v and %v", i, move, second[i])
+		}
+		if move.Board >= 9 || move.Pos >= 9 || game.Board[move.Board]&(1<<move.Pos|1<<(move.Pos+9)) != 0 {
+			t.Fatalf("move %d %v is not playable", i, move)
+		}
+		game.MakeMove(move.Board, move.Pos)
+	}
+}
+
+func TestPlayFinishesGame(t *testing.T) {
+	config := gmcts.DefaultConfig()
+	bots := [2]*Bot{
+		{Heuristic: Game.DefaultHeuristic(), Depth: 2, MoveTime: time.Millisecond * 5},
+		{Heuristic: Game.DefaultHeuristic(), MCTS: &config, MoveTime: time.Millisecond * 5},
+	}
+
+	opening := RandomOpening(rand.New(rand.NewSource(1)), 3)
+	game := Play(bots, opening)
+	if !game.IsTerminal() {
+		t.Fatal("game did not finish")
+	}
+	if game.MovesMade() <= uint32(len(opening)) {
+		t.Fatalf("only %d moves were made after an opening of %d", game.MovesMade(), len(opening))
+	}
+
+	score := Score{}
+	score.Add(game, Game.Player1)
+	score.Add(game, Game.Player2)
+	if score.Games() != 2 || (game.WinningPlayer() == Game.Draw) != (score.Draws == 2) {
+		t.Fatalf("score %v does not match winner %d", score, game.WinningPlayer())
+	}
+}
+
+func TestScoreConfidenceInterval(t *testing.T) {
+	even := Score{Wins: 50, Losses: 50}
+	if mean := even.Mean(); mean != 0.5 {
+		t.Fatalf("mean %f, want 0.5", mean)
+	}
+	// The standard error of 100 games with a standard deviation of about 0.5
+	if ci := even.ConfidenceInterval(); ci < 0.09 || ci > 0.11 {
+		t.Fatalf("confidence interval %f, want about 0.1", ci)
+	}
+
+	more := Score{Wins: 200, Losses: 200}
+	if more.ConfidenceInterval() >= even.ConfidenceInterval() {
+		t.Fatal("more games did not narrow the interval")
+	}
+	if draws := (Score{Draws: 10}); draws.Mean() != 0.5 || draws.ConfidenceInterval() != 0 {
+		t.Fatalf("only draws gave %v", draws)
+	}
+}
+
+func TestPlayWithoutMoveTimeIsReproducible(t *testing.T) {
+	config := gmcts.DefaultConfig()
+	bots := [2]*Bot{
+		{Heuristic: Game.DefaultHeuristic(), Depth: 3},
+		{MCTS: &config, Rounds: 500},
+	}
+	opening := RandomOpening(rand.New(rand.NewSource(2)), 2)
+
+	Game.Seed(11)
+	first := Play(bots, opening)
+	Game.Seed(11)
+	second := Play(bots, opening)
+	if first.Board != second.Board || first.OverallBoard != second.OverallBoard {
+		t.Fatal("the same seed played two different games")
+	}
+}
+
+func TestPlayPairsInParallelIsReproducible(t *testing.T) {
+	config := gmcts.DefaultConfig()
+	bot := &Bot{MCTS: &config, Rounds: 300}
+	opponent := &Bot{Heuristic: Game.DefaultHeuristic(), Depth: 2}
+	rng := rand.New(rand.NewSource(6))
+	openings := [][]Move{RandomOpening(rng, 2), RandomOpening(rng, 2), RandomOpening(rng, 2)}
+
+	games := func(threads int) []string {
+		Game.Seed(13)
+		var played []string
+		PlayPairs(bot, opponent, openings, threads, func(record *Record, player Game.Player) bool {
+			played = append(played, fmt.Sprint(player, record.Moves))
+			return false
+		})
+		sort.Strings(played)
+		return played
+	}
+	alone, parallel := games(1), games(3)
+	for i := range alone {
+		if alone[i] != parallel[i] {
+			t.Fatalf("one at a time played %s, in parallel %s", alone[i], parallel[i])
+		}
+	}
+}
+
+func TestPlayOpeningsPlaysBothColours(t *testing.T) {
+	bot := &Bot{Heuristic: Game.DefaultHeuristic(), Depth: 2}
+	opponent := &Bot{Heuristic: Game.DefaultHeuristic(), Depth: 3}
+	rng := rand.New(rand.NewSource(4))
+	openings := [][]Move{RandomOpening(rng, 2), RandomOpening(rng, 2)}
+
+	score := PlayOpenings(bot, opponent, openings, 2)
+	if score.Games() != 4 {
+		t.Fatalf("%d games were played, want 4", score.Games())
+	}
+
+	mirrored := PlayOpenings(opponent, bot, openings, 1)
+	if mirrored.Wins != score.Losses || mirrored.Losses != score.Wins {
+		t.Fatalf("score %v is not the mirror of %v", mirrored, score)
+	}
+}
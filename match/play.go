@@ -0,0 +1,153 @@
+package match
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+)
+
+// Move is a move of a game, Board is the local board and Pos the square within it
+type Move struct {
+	Board byte
+	Pos   byte
+}
+
+// Play plays the opening and then lets the bots move, bots is indexed by Game.Player and Player2 makes the first move.
+// The finished game is returned
+func Play(bots [2]*Bot, opening []Move) *Game.Game {
+	return PlayRecord(bots, opening).Final
+}
+
+// PlayRecord is Play that keeps every move of the game, the opening included, and the time the bots searched.
+// A bot with a Clock loses once its time runs out
+func PlayRecord(bots [2]*Bot, opening []Move) *Record {
+	game := Game.NewGame()
+	record := &Record{Players: [2]string{bots[0].Name, bots[1].Name}, Opening: len(opening), Moves: make([]Move, 0, 81)}
+	for _, move := range opening {
+		game.MakeMove(move.Board, move.Pos)
+		record.Moves = append(record.Moves, move)
+	}
+
+	// Every side keeps its own table, the stored bounds depend on the heuristic
+	tables := [2]*minimax.Storage{}
+	clocks := [2]time.Duration{bots[0].Clock.Total, bots[1].Clock.Total}
+	for !game.IsTerminal() {
+		player := Game.Player(game.Board[Game.PlayerBoardIndex] & 0x1)
+		if tables[player] == nil && bots[player].MCTS == nil {
+			table := minimax.NewStorage()
+			tables[player] = &table
+		}
+
+		start := time.Now()
+		move, board := bots[player].BestMoveClock(game, tables[player], clocks[player])
+		elapsed := time.Since(start)
+		record.Time[player] += elapsed
+		record.Searches[player]++
+
+		if clock := bots[player].Clock; clock.Total > 0 {
+			if clocks[player] -= elapsed; clocks[player] < 0 {
+				record.Forfeit = TIME_FORFEIT
+				break
+			}
+			clocks[player] += clock.Increment
+		}
+
+		if !game.ValidMove(board, move) {
+			// A search that ran out of time before its first iteration has no move
+			game.GetMoves(func(b byte, m byte) bool {
+				board, move = b, m
+				return true
+			})
+		}
+		game.MakeMove(board, move)
+		record.Moves = append(record.Moves, Move{Board: board, Pos: move})
+	}
+
+	record.Final = game
+	return record
+}
+
+// RandomOpening plays random moves from the start, it stops early if the game ends
+func RandomOpening(rng *rand.Rand, plies int) []Move {
+	game := Game.NewGame()
+	opening := make([]Move, 0, plies)
+	for len(opening) < plies && !game.IsTerminal() {
+		var moves []Move
+		game.GetMoves(func(board byte, pos byte) bool {
+			moves = append(moves, Move{Board: board, Pos: pos})
+			return false
+		})
+		// The move order of GetMoves is shuffled on start, sort it so the seed decides the opening
+		sort.Slice(moves, func(i, j int) bool {
+			return moves[i].Board < moves[j].Board || moves[i].Board == moves[j].Board && moves[i].Pos < moves[j].Pos
+		})
+
+		move := moves[rng.Intn(len(moves))]
+		game.MakeMove(move.Board, move.Pos)
+		opening = append(opening, move)
+	}
+	return opening
+}
+
+// PlayPairs plays every opening twice so both bots make the first move once. Every finished game is passed to done
+// together with the player bot was, done is called for one game at a time and stops the match by returning true.
+// Up to threads games are played at the same time
+func PlayPairs(bot, opponent *Bot, openings [][]Move, threads int, done func(record *Record, player Game.Player) bool) {
+	type game struct {
+		bots   [2]*Bot
+		player Game.Player
+		moves  []Move
+	}
+
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+	stopped := false
+	games := make(chan game)
+	for i := 0; i < threads; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for g := range games {
+				record := PlayRecord(g.bots, g.moves)
+				lock.Lock()
+				if !stopped {
+					stopped = done(record, g.player)
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	isStopped := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return stopped
+	}
+	for _, opening := range openings {
+		if isStopped() {
+			break
+		}
+		games <- game{bots: [2]*Bot{bot, opponent}, player: Game.Player1, moves: opening}
+		if isStopped() {
+			break
+		}
+		games <- game{bots: [2]*Bot{opponent, bot}, player: Game.Player2, moves: opening}
+	}
+	close(games)
+	wait.Wait()
+}
+
+// PlayOpenings plays every opening twice so both bots make the first move once, the score is the one of bot.
+// Up to threads games are played at the same time
+func PlayOpenings(bot, opponent *Bot, openings [][]Move, threads int) Score {
+	score := Score{}
+	PlayPairs(bot, opponent, openings, threads, func(record *Record, player Game.Player) bool {
+		score.AddRecord(record, player)
+		return false
+	})
+	return score
+}
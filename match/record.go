@@ -0,0 +1,295 @@
+package match
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+// The notation of a move is its local board followed by the square, both are numbered
+//
+//	0 1 2
+//	7 8 3
+//	6 5 4
+//
+// so "84" is square 4 of the middle board. A sequence of moves is separated by spaces
+
+func (m Move) String() string {
+	return string([]byte{'0' + m.Board, '0' + m.Pos})
+}
+
+func ParseMove(s string) (Move, error) {
+	if len(s) != 2 || s[0] < '0' || s[0] > '8' || s[1] < '0' || s[1] > '8' {
+		return Move{}, fmt.Errorf("invalid move %q, want the board and square digits", s)
+	}
+	return Move{Board: s[0] - '0', Pos: s[1] - '0'}, nil
+}
+
+// ParseMoves reads a sequence of moves and checks that every move is legal after the ones before it
+func ParseMoves(s string) ([]Move, error) {
+	return ParseMovesFrom(Game.NewGame(), s)
+}
+
+// ParseMovesFrom is ParseMoves for the moves played from start, start is left as it is
+func ParseMovesFrom(start *Game.Game, s string) ([]Move, error) {
+	game := start.Copy()
+	var moves []Move
+	for _, field := range strings.Fields(s) {
+		move, err := ParseMove(field)
+		if err != nil {
+			return nil, err
+		}
+		if game.IsTerminal() || !game.ValidMove(move.Board, move.Pos) {
+			return nil, fmt.Errorf("illegal move %s after %d moves", move, len(moves))
+		}
+		game.MakeMove(move.Board, move.Pos)
+		moves = append(moves, move)
+	}
+	return moves, nil
+}
+
+func FormatMoves(moves []Move) string {
+	fields := make([]string, len(moves))
+	for i, move := range moves {
+		fields[i] = move.String()
+	}
+	return strings.Join(fields, " ")
+}
+
+// StartPosition is the notation of Game.NewGame, the first move is played on the middle board
+const StartPosition = "........./........./........./........./........./........./........./........./......... o 8"
+
+// FormatPosition writes the nine local boards in board order separated by slashes, every board lists its squares
+// in order as x for Player1, o for Player2 and . when empty. The player to move and the board to play follow,
+// a - allows every open board
+func FormatPosition(game *Game.Game) string {
+	var sb strings.Builder
+	for board := 0; board < 9; board++ {
+		if board > 0 {
+			sb.WriteByte('/')
+		}
+		for pos := 0; pos < 9; pos++ {
+			switch {
+			case game.Board[board]&(1<<pos) != 0:
+				sb.WriteByte('x')
+			case game.Board[board]&(1<<(pos+9)) != 0:
+				sb.WriteByte('o')
+			default:
+				sb.WriteByte('.')
+			}
+		}
+	}
+
+	if game.Board[Game.PlayerBoardIndex]&0x1 == uint32(Game.Player2) {
+		sb.WriteString(" o")
+	} else {
+		sb.WriteString(" x")
+	}
+	if currentBoard := byte(game.Board[Game.PlayerBoardIndex] >> 1); currentBoard < 9 {
+		sb.WriteString(" " + strconv.Itoa(int(currentBoard)))
+	} else {
+		sb.WriteString(" -")
+	}
+	return sb.String()
+}
+
+// ParsePosition reads a position written by FormatPosition, the finished boards follow from the squares
+func ParsePosition(notation string) (*Game.Game, error) {
+	fields := strings.Fields(notation)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid position %q, want the boards, the player to move and the board to play", notation)
+	}
+	boards := strings.Split(fields[0], "/")
+	if len(boards) != 9 {
+		return nil, fmt.Errorf("invalid position %q, want 9 boards", notation)
+	}
+
+	game := Game.NewGame()
+	for board, squares := range boards {
+		if len(squares) != 9 {
+			return nil, fmt.Errorf("board %d has %d squares, want 9", board, len(squares))
+		}
+		for pos, square := range squares {
+			switch square {
+			case 'x':
+				game.Board[board] |= 1 << pos
+			case 'o':
+				game.Board[board] |= 1 << (pos + 9)
+			case '.':
+			default:
+				return nil, fmt.Errorf("board %d has an invalid square %q", board, square)
+			}
+		}
+
+		// The same bits MakeMove sets, a full board is a draw even when it was won
+		if Game.BoardCompletedStorage[game.Board[board]&0x1FF] {
+			game.OverallBoard |= 1 << board
+		}
+		if Game.BoardCompletedStorage[(game.Board[board]>>9)&0x1FF] {
+			game.OverallBoard |= 1 << (board + 9)
+		}
+		if (game.Board[board]|game.Board[board]>>9)&0x1FF == 0x1FF {
+			game.OverallBoard |= 1 << (board + 18)
+		}
+	}
+
+	var player uint32
+	switch fields[1] {
+	case "x":
+		player = uint32(Game.Player1)
+	case "o":
+		player = uint32(Game.Player2)
+	default:
+		return nil, fmt.Errorf("invalid player to move %q, want x or o", fields[1])
+	}
+
+	switch target := fields[2]; {
+	case target == "-":
+		game.Board[Game.PlayerBoardIndex] = 0x100 | player
+	case len(target) == 1 && target[0] >= '0' && target[0] <= '8':
+		board := target[0] - '0'
+		game.Board[Game.PlayerBoardIndex] = uint32(board)<<1 | player
+		if game.IsBoardFinished(board) {
+			game.Board[Game.PlayerBoardIndex] |= 0x100
+		}
+	default:
+		return nil, fmt.Errorf("invalid board to play %q, want a digit or -", target)
+	}
+	return game, nil
+}
+
+// Forfeit is why the player to move lost before the game was over
+type Forfeit string
+
+const (
+	NO_FORFEIT   Forfeit = ""
+	TIME_FORFEIT Forfeit = "time forfeit"
+	ILLEGAL_MOVE Forfeit = "illegal move"
+)
+
+// Record is a played game, the time and searched moves are counted per Game.Player
+type Record struct {
+	Players  [2]string
+	Opening  int
+	Moves    []Move
+	Time     [2]time.Duration
+	Searches [2]int
+	Final    *Game.Game
+
+	// Start is the position the moves are played from as written by FormatPosition, empty is StartPosition
+	Start string
+
+	// Forfeit is set when the player to move in Final lost before the game was over
+	Forfeit Forfeit
+}
+
+// start returns the position the moves are played from
+func (r *Record) start() (*Game.Game, error) {
+	if r.Start == "" {
+		return Game.NewGame(), nil
+	}
+	return ParsePosition(r.Start)
+}
+
+// Winner is the player who won the game or Game.Draw
+func (r *Record) Winner() Game.Player {
+	if r.Forfeit != NO_FORFEIT {
+		return Game.Player(r.Final.Board[Game.PlayerBoardIndex]&0x1) ^ 0x1
+	}
+	return r.Final.WinningPlayer()
+}
+
+// Result is 1-0 when Player1 won, 0-1 when Player2 won and 1/2-1/2 for a draw
+func (r *Record) Result() string {
+	switch r.Winner() {
+	case Game.Player1:
+		return "1-0"
+	case Game.Player2:
+		return "0-1"
+	}
+	return "1/2-1/2"
+}
+
+// noMoves is the moves line of a record without moves, it ends the record like any other moves
+const noMoves = "*"
+
+// Write stores the record as tags in brackets followed by the moves and an empty line, a game that was lost
+// before its first move has the moves noMoves
+func (r *Record) Write(w io.Writer) error {
+	moves := FormatMoves(r.Moves)
+	if moves == "" {
+		moves = noMoves
+	}
+	tags := ""
+	if r.Start != "" {
+		tags += fmt.Sprintf("[Position %q]\n", r.Start)
+	}
+	if r.Forfeit != NO_FORFEIT {
+		tags += fmt.Sprintf("[Termination %q]\n", r.Forfeit)
+	}
+	_, err := fmt.Fprintf(w, "[Player1 %q]\n[Player2 %q]\n[Opening \"%d\"]\n[Result %q]\n%s%s\n\n",
+		r.Players[Game.Player1], r.Players[Game.Player2], r.Opening, r.Result(), tags, moves)
+	return err
+}
+
+// ReadRecords reads the records written by Write, the final positions are replayed from the moves
+func ReadRecords(reader io.Reader) ([]*Record, error) {
+	var records []*Record
+	record := &Record{}
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "":
+			continue
+
+		case strings.HasPrefix(text, "["):
+			name, value, ok := strings.Cut(strings.Trim(text, "[]"), " ")
+			unquoted, err := strconv.Unquote(value)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("line %d: invalid tag %s", line, text)
+			}
+
+			switch name {
+			case "Player1":
+				record.Players[Game.Player1] = unquoted
+			case "Player2":
+				record.Players[Game.Player2] = unquoted
+			case "Position":
+				record.Start = unquoted
+			case "Termination":
+				record.Forfeit = Forfeit(unquoted)
+			case "Opening":
+				if record.Opening, err = strconv.Atoi(unquoted); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+
+		default:
+			start, err := record.start()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			var moves []Move
+			if text != noMoves {
+				moves, err = ParseMovesFrom(start, text)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			record.Moves = moves
+			record.Final = start
+			for _, move := range moves {
+				record.Final.MakeMove(move.Board, move.Pos)
+			}
+			records = append(records, record)
+			record = &Record{}
+		}
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,184 @@
+package match
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+)
+
+func TestParseMoves(t *testing.T) {
+	moves, err := ParseMoves("84 40 08")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 3 || moves[0] != (Move{Board: 8, Pos: 4}) || FormatMoves(moves) != "84 40 08" {
+		t.Fatalf("parsed %v", moves)
+	}
+
+	// The second move must be played on board 4
+	for _, notation := range []string{"84 30", "9", "8a", "84 84"} {
+		if _, err = ParseMoves(notation); err == nil {
+			t.Fatalf("%q was accepted", notation)
+		}
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	bots := [2]*Bot{
+		{Name: "first", Heuristic: Game.DefaultHeuristic(), Depth: 2},
+		{Name: "second", Heuristic: Game.DefaultHeuristic(), Depth: 3, Algorithm: MINIMAX},
+	}
+	record := PlayRecord(bots, RandomOpening(rand.New(rand.NewSource(5)), 2))
+	if record.Opening != 2 || record.Searches[0]+record.Searches[1]+2 != len(record.Moves) {
+		t.Fatalf("record of %d moves has opening %d and %v searches", len(record.Moves), record.Opening, record.Searches)
+	}
+
+	buffer := bytes.Buffer{}
+	if err := record.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if err := record.Write(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadRecords(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("read %d records, want 2", len(records))
+	}
+	read := records[1]
+	if read.Players != record.Players || read.Opening != 2 || read.Result() != record.Result() || read.Final.Board != record.Final.Board {
+		t.Fatalf("read %+v, want %+v", read, record)
+	}
+}
+
+func TestParseBot(t *testing.T) {
+	bot, err := ParseBot("mcts:selection=ucb1-tuned,policy=secure,c=0.5,transpositions=true,minimax=2,rounds=100,time=0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := gmcts.MCTSConfig{ExplorationConst: 0.5, Selection: gmcts.UCB1_TUNED, BestAction: gmcts.SECURE_CHILD,
+		FirstPlayUrgency: gmcts.DefaultFirstPlayUrgency, Transpositions: true, MinimaxDepth: 2}
+	if *bot.MCTS != want || bot.Rounds != 100 || bot.MoveTime != 0 {
+		t.Fatalf("parsed %+v with %+v", bot, *bot.MCTS)
+	}
+
+	if bot, err = ParseBot("minimax:depth=4"); err != nil || bot.Algorithm != MINIMAX || bot.Depth != 4 || bot.Heuristic == nil {
+		t.Fatalf("parsed %+v, %v", bot, err)
+	}
+	for _, spec := range []string{"alphabeta", "mtd:depth=1", "mtd:selection=uct1", "mcts:policy=best", "mtd:heuristic=missing.txt"} {
+		if _, err = ParseBot(spec); err == nil {
+			t.Fatalf("%q was accepted", spec)
+		}
+	}
+}
+
+func TestElo(t *testing.T) {
+	if diff, _ := (Score{Wins: 10, Losses: 10}).Elo(); diff != 0 {
+		t.Fatalf("an even score is %f Elo", diff)
+	}
+	// A score of 0.75 is 3:1 odds
+	diff, margin := Score{Wins: 75, Losses: 25}.Elo()
+	if math.Abs(diff-400*math.Log10(3)) > 1e-9 || margin <= 0 || margin > 100 {
+		t.Fatalf("0.75 is %f ± %f Elo", diff, margin)
+	}
+}
+
+func TestPositionRoundTrip(t *testing.T) {
+	if position := FormatPosition(Game.NewGame()); position != StartPosition {
+		t.Fatalf("start position is %q", position)
+	}
+
+	game := Game.NewGame()
+	// The last move sends Player1 to the finished board 2
+	moves, err := ParseMoves("81 11 14 42 24 47 73 34 48 83 35 55 50 00 06 66 63 30 07 72 23 37 74 45 56 68 84 44 40 03 36 61 17 71 10 04 41 12 26 65 52 22 25 51 15 53 38 85 57 78 82")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, move := range moves {
+		game.MakeMove(move.Board, move.Pos)
+	}
+	if !game.IsBoardFinished(2) || !strings.HasSuffix(FormatPosition(game), " x -") {
+		t.Fatalf("every board can not be played in %s", FormatPosition(game))
+	}
+	parsed, err := ParsePosition(FormatPosition(game))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A move to a finished board keeps its square in the board to play, the position does not
+	if [9]uint32(parsed.Board[:9]) != [9]uint32(game.Board[:9]) || parsed.OverallBoard != game.OverallBoard || parsed.Len() != game.Len() {
+		t.Fatalf("%s parsed as %s", FormatPosition(game), FormatPosition(parsed))
+	}
+
+	for _, invalid := range []string{"", StartPosition + " 1", "......... o 8", strings.Replace(StartPosition, "o 8", "y 8", 1), strings.Replace(StartPosition, "o 8", "o 9", 1)} {
+		if _, err = ParsePosition(invalid); err == nil {
+			t.Fatalf("%q was accepted", invalid)
+		}
+	}
+}
+
+func TestForfeitRecord(t *testing.T) {
+	game := Game.NewGame()
+	game.MakeMove(8, 4)
+	record := &Record{Moves: []Move{{Board: 8, Pos: 4}}, Final: game, Forfeit: TIME_FORFEIT}
+	// Player1 is to move and lost on time
+	if record.Result() != "0-1" {
+		t.Fatalf("forfeit is %s", record.Result())
+	}
+
+	buffer := bytes.Buffer{}
+	_ = record.Write(&buffer)
+	records, err := ReadRecords(&buffer)
+	if err != nil || len(records) != 1 || records[0].Forfeit != TIME_FORFEIT || records[0].Result() != "0-1" {
+		t.Fatalf("read %v, %v", records, err)
+	}
+}
+
+func TestForfeitBeforeFirstMoveRecord(t *testing.T) {
+	forfeit := &Record{Players: [2]string{"a", "b"}, Final: Game.NewGame(), Forfeit: TIME_FORFEIT}
+	game := Game.NewGame()
+	game.MakeMove(8, 4)
+	played := &Record{Players: [2]string{"c", "d"}, Moves: []Move{{Board: 8, Pos: 4}}, Final: game}
+
+	buffer := bytes.Buffer{}
+	_ = forfeit.Write(&buffer)
+	_ = played.Write(&buffer)
+	records, err := ReadRecords(&buffer)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("read %v, %v", records, err)
+	}
+	if records[0].Players != forfeit.Players || len(records[0].Moves) != 0 || records[0].Forfeit != TIME_FORFEIT || records[0].Result() != "1-0" {
+		t.Errorf("the forfeit was read as %+v, %s", records[0], records[0].Result())
+	}
+	if records[1].Players != played.Players || records[1].Forfeit != NO_FORFEIT || len(records[1].Moves) != 1 {
+		t.Errorf("the game after the forfeit was read as %+v", records[1])
+	}
+}
+
+func TestStartPositionRecord(t *testing.T) {
+	start := strings.Replace(StartPosition, "o 8", "o -", 1)
+	game, _ := ParsePosition(start)
+	game.MakeMove(0, 0)
+	record := &Record{Start: start, Moves: []Move{{Board: 0, Pos: 0}}, Final: game, Forfeit: ILLEGAL_MOVE}
+
+	buffer := bytes.Buffer{}
+	_ = record.Write(&buffer)
+	records, err := ReadRecords(&buffer)
+	if err != nil || len(records) != 1 || records[0].Start != start || records[0].Forfeit != ILLEGAL_MOVE {
+		t.Fatalf("read %v, %v", records, err)
+	}
+	if !records[0].Final.Compare(game) || records[0].Result() != "0-1" {
+		t.Errorf("replayed %s, result %s", FormatPosition(records[0].Final), records[0].Result())
+	}
+
+	// The first move of the start position is on the middle board
+	if _, err = ReadRecords(strings.NewReader("[Result \"1/2-1/2\"]\n00\n")); err == nil {
+		t.Errorf("00 was read from the start position")
+	}
+}
@@ -0,0 +1,92 @@
+package match
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+// z95 is the normal quantile of a two sided 95% confidence interval
+const z95 = 1.96
+
+// Score counts the results of games from the view of one player
+type Score struct {
+	Wins   int
+	Draws  int
+	Losses int
+}
+
+// Add counts a finished game played as player
+func (s *Score) Add(game *Game.Game, player Game.Player) {
+	s.addWinner(game.WinningPlayer(), player)
+}
+
+// AddRecord counts a recorded game played as player, a forfeit decides the game
+func (s *Score) AddRecord(record *Record, player Game.Player) {
+	s.addWinner(record.Winner(), player)
+}
+
+func (s *Score) addWinner(winner Game.Player, player Game.Player) {
+	switch winner {
+	case player:
+		s.Wins++
+	case Game.Draw:
+		s.Draws++
+	default:
+		s.Losses++
+	}
+}
+
+// Merge counts the games of other
+func (s *Score) Merge(other Score) {
+	s.Wins += other.Wins
+	s.Draws += other.Draws
+	s.Losses += other.Losses
+}
+
+// Reversed is the score of the opponent
+func (s Score) Reversed() Score {
+	return Score{Wins: s.Losses, Draws: s.Draws, Losses: s.Wins}
+}
+
+func (s Score) Games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// Mean is the average points per game, a win is 1 and a draw is 0.5
+func (s Score) Mean() float64 {
+	if s.Games() == 0 {
+		return 0
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+// ConfidenceInterval is the half width of the 95% confidence interval of the mean
+func (s Score) ConfidenceInterval() float64 {
+	games := float64(s.Games())
+	if games < 2 {
+		return 1
+	}
+
+	mean := s.Mean()
+	variance := (float64(s.Wins)*(1-mean)*(1-mean) + float64(s.Draws)*(0.5-mean)*(0.5-mean) + float64(s.Losses)*mean*mean) / (games - 1)
+	return z95 * math.Sqrt(variance/games)
+}
+
+func (s Score) String() string {
+	return fmt.Sprintf("%.3f ± %.3f (+%d =%d -%d)", s.Mean(), s.ConfidenceInterval(), s.Wins, s.Draws, s.Losses)
+}
+
+// Elo is the rating difference the mean score implies together with the half width of its 95% confidence interval
+func (s Score) Elo() (float64, float64) {
+	mean, interval := s.Mean(), s.ConfidenceInterval()
+	diff := eloDifference(mean)
+	margin := (eloDifference(math.Min(mean+interval, 1)) - eloDifference(math.Max(mean-interval, 0))) / 2
+	return diff, margin
+}
+
+// eloDifference is the rating difference of a player expected to score mean, it is infinite for 0 and 1
+func eloDifference(mean float64) float64 {
+	return -400 * math.Log10(1/mean-1)
+}
@@ -0,0 +1,159 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+)
+
+var selectionPolicies = map[string]gmcts.SelectionPolicy{
+	"uct1":       gmcts.UCT1,
+	"uct2":       gmcts.UCT2,
+	"ucb1-tuned": gmcts.UCB1_TUNED,
+	"puct":       gmcts.PUCT,
+}
+
+var bestActionPolicies = map[string]gmcts.BestActionPolicy{
+	"max":        gmcts.MAX_CHILD_SCORE,
+	"robust":     gmcts.ROBUST_CHILD,
+	"max-robust": gmcts.MAX_ROBUST_CHILD,
+	"secure":     gmcts.SECURE_CHILD,
+}
+
+// ParseBot reads a bot from a spec of the algorithm followed by options, e.g.
+//
+//	mtd:depth=7,time=100ms,heuristic=elitePop.txt,index=-1
+//	minimax:depth=5
+//	mcts:selection=uct2,policy=robust,c=0.41,fpu=1,transpositions=true,minimax=2,rounds=20000
+//
+// The algorithms are mtd, minimax and mcts. The alpha-beta searches take depth, time, heuristic and index,
+// a heuristic file without an index uses its fittest elite. MCTS takes time, rounds and the fields of gmcts.MCTSConfig.
+// Options that are not given keep the defaults of the match package and the spec is the Name of the bot
+func ParseBot(spec string) (*Bot, error) {
+	algorithm, options, _ := strings.Cut(spec, ":")
+	bot := &Bot{Name: spec, Depth: 5, Rounds: 20000, MoveTime: time.Millisecond * 100}
+	switch algorithm {
+	case "mtd":
+		bot.Algorithm = MTD_F
+	case "minimax":
+		bot.Algorithm = MINIMAX
+	case "mcts":
+		config := gmcts.DefaultConfig()
+		bot.MCTS = &config
+	default:
+		return nil, fmt.Errorf("%s: unknown algorithm %q, want mtd, minimax or mcts", spec, algorithm)
+	}
+
+	heuristic, index := "", Game.BestHeuristic
+	for _, option := range strings.Split(options, ",") {
+		if option == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(option, "=")
+		err := bot.setOption(key, value, &heuristic, &index)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", spec, key, err)
+		}
+	}
+
+	if bot.MCTS != nil {
+		return bot, nil
+	}
+	if bot.Depth < 2 {
+		return nil, fmt.Errorf("%s: depth must be at least 2", spec)
+	}
+	if heuristic == "" {
+		bot.Heuristic = Game.DefaultHeuristic()
+		return bot, nil
+	}
+
+	var err error
+	if bot.Heuristic, err = Game.LoadHeuristic(heuristic, index); err != nil {
+		return nil, fmt.Errorf("%s: %w", spec, err)
+	}
+	return bot, nil
+}
+
+// MaxRemoteMinimaxDepth is the deepest alpha-beta search of the hybrid MCTS a remote spec may ask for,
+// the search runs on every expanded node
+const MaxRemoteMinimaxDepth = 3
+
+// ParseRemoteBot reads a spec sent by the client of a server like ParseBot. The heuristic option is refused
+// so no file of the server is opened, and MCTS searches at most MaxRemoteMinimaxDepth on every node
+func ParseRemoteBot(spec string) (*Bot, error) {
+	_, options, _ := strings.Cut(spec, ":")
+	for _, option := range strings.Split(options, ",") {
+		if key, _, _ := strings.Cut(option, "="); key == "heuristic" {
+			return nil, fmt.Errorf("%s: heuristic: files are not read for a remote spec", spec)
+		}
+	}
+
+	bot, err := ParseBot(spec)
+	if err != nil {
+		return nil, err
+	}
+	if bot.MCTS != nil && bot.MCTS.MinimaxDepth > MaxRemoteMinimaxDepth {
+		return nil, fmt.Errorf("%s: minimax: the depth is at most %d", spec, MaxRemoteMinimaxDepth)
+	}
+	return bot, nil
+}
+
+func (b *Bot) setOption(key, value string, heuristic *string, index *int) error {
+	var err error
+	var number float64
+	switch key {
+	case "time":
+		b.MoveTime, err = time.ParseDuration(value)
+	case "rounds":
+		b.Rounds, err = strconv.Atoi(value)
+	}
+	if key == "time" || key == "rounds" {
+		return err
+	}
+
+	if b.MCTS == nil {
+		switch key {
+		case "depth":
+			var depth uint64
+			depth, err = strconv.ParseUint(value, 10, 8)
+			b.Depth = byte(depth)
+		case "heuristic":
+			*heuristic = value
+		case "index":
+			*index, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown option of an alpha-beta search")
+		}
+		return err
+	}
+
+	ok := true
+	switch key {
+	case "selection":
+		b.MCTS.Selection, ok = selectionPolicies[value]
+	case "policy":
+		b.MCTS.BestAction, ok = bestActionPolicies[value]
+	case "c":
+		number, err = strconv.ParseFloat(value, 32)
+		b.MCTS.ExplorationConst = float32(number)
+	case "fpu":
+		number, err = strconv.ParseFloat(value, 32)
+		b.MCTS.FirstPlayUrgency = float32(number)
+	case "transpositions":
+		b.MCTS.Transpositions, err = strconv.ParseBool(value)
+	case "minimax":
+		var depth uint64
+		depth, err = strconv.ParseUint(value, 10, 8)
+		b.MCTS.MinimaxDepth = byte(depth)
+	default:
+		err = fmt.Errorf("unknown option of MCTS")
+	}
+	if !ok {
+		err = fmt.Errorf("unknown value %q", value)
+	}
+	return err
+}
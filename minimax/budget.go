@@ -0,0 +1,118 @@
+package minimax
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+// Budget ends a search after MaxTime, after MaxNodes searched positions unless it is 0, or once Stop is called.
+// Iterative searches start no new depth after SoftTime unless it is 0. Only Stop and PonderHit may be called
+// while the search runs
+type Budget struct {
+	Start     time.Time
+	MaxTime   time.Duration
+	SoftTime  time.Duration
+	MaxNodes  uint64
+	Nodes     uint64
+	stopped   atomic.Bool
+	pondering atomic.Bool
+	outer     *Budget
+}
+
+// NewBudget starts a budget of maxTime now
+func NewBudget(maxTime time.Duration) *Budget {
+	return &Budget{Start: time.Now(), MaxTime: maxTime}
+}
+
+// NewInnerBudget starts a budget without limits of its own for a search run inside the search of outer,
+// it is exhausted once outer is. The nodes of the inner search do not count towards outer
+func NewInnerBudget(outer *Budget) *Budget {
+	return &Budget{Start: time.Now(), MaxTime: time.Duration(math.MaxInt64), outer: outer}
+}
+
+// NewPonderBudget starts a budget for searching during the turn of the opponent, it has no limits until PonderHit
+func NewPonderBudget() *Budget {
+	b := &Budget{Start: time.Now()}
+	b.pondering.Store(true)
+	return b
+}
+
+// Stop ends the search from another goroutine
+func (b *Budget) Stop() {
+	b.stopped.Store(true)
+}
+
+// PonderHit ends the pondering from another goroutine, the search continues on the times and nodes of limits.
+// The times count from the start of limits and the nodes searched while pondering count towards MaxNodes
+func (b *Budget) PonderHit(limits *Budget) {
+	if !b.pondering.Load() {
+		return
+	}
+	// The limits are only read by the search once it sees the end of the pondering, Start is read by reports
+	// during the pondering so the times are moved instead
+	pondered := limits.Start.Sub(b.Start)
+	b.MaxTime = extend(limits.MaxTime, pondered)
+	if limits.SoftTime > 0 {
+		b.SoftTime = extend(limits.SoftTime, pondered)
+	}
+	b.MaxNodes = limits.MaxNodes
+	b.pondering.Store(false)
+}
+
+// extend adds the time before a limit, a limit beyond the longest duration has no limit
+func extend(limit time.Duration, before time.Duration) time.Duration {
+	if limit > math.MaxInt64-before {
+		return math.MaxInt64
+	}
+	return limit + before
+}
+
+// Pondering reports if the search runs during the turn of the opponent
+func (b *Budget) Pondering() bool {
+	return b.pondering.Load()
+}
+
+func (b *Budget) Exhausted() bool {
+	if b.outer != nil && b.outer.Exhausted() {
+		return true
+	}
+	if b.pondering.Load() {
+		return b.stopped.Load()
+	}
+	return b.stopped.Load() || (b.MaxNodes > 0 && b.Nodes >= b.MaxNodes) || time.Since(b.Start) > b.MaxTime
+}
+
+// Deepen reports if an iterative search may start the next depth
+func (b *Budget) Deepen() bool {
+	if b.pondering.Load() {
+		return !b.stopped.Load()
+	}
+	return !b.Exhausted() && (b.SoftTime == 0 || time.Since(b.Start) < b.SoftTime)
+}
+
+// Iteration is a finished depth of an iterative deepening search, the score is for the player to move
+type Iteration struct {
+	Depth byte
+	Score float64
+	Board byte
+	Move  byte
+}
+
+// PrincipalVariation follows the best moves stored for the positions after state, at most length moves.
+// Only positions stored in the orientation they are reached are followed
+func PrincipalVariation(table *Storage, state *Game.Game, length int) (boards []byte, moves []byte) {
+	s := state.Copy()
+	for len(moves) < length && !s.IsTerminal() {
+		n, exists := table.Get(s.Hash())
+		if !exists || !s.ValidMove(n.bestBoard, n.bestMove) {
+			break
+		}
+		boards = append(boards, n.bestBoard)
+		moves = append(moves, n.bestMove)
+		s.MakeMove(n.bestBoard, n.bestMove)
+	}
+	return boards, moves
+}
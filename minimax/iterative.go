@@ -0,0 +1,35 @@
+package minimax
+
+import (
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+// IterativeDeepeningTime runs full window searches of increasing depth below maxDepth until maxTime,
+// the move of the deepest finished search is returned
+func IterativeDeepeningTime(table *Storage, state *Game.Game, maxDepth byte, maxTime time.Duration) (byte, byte) {
+	return IterativeDeepeningBudget(table, state, maxDepth, NewBudget(maxTime), nil)
+}
+
+// IterativeDeepeningBudget is IterativeDeepeningTime until the budget is exhausted, report is called after
+// every finished depth unless it is nil
+func IterativeDeepeningBudget(table *Storage, state *Game.Game, maxDepth byte, budget *Budget, report func(Iteration)) (byte, byte) {
+	maxPlayer := Game.Player(state.Board[Game.PlayerBoardIndex] & 0x1)
+	var bestMove byte = 255
+	var bestBoard byte = 255
+
+	for d := byte(1); d < maxDepth && budget.Deepen(); d++ {
+		value, move, board := Search(table, state, -inf, inf, d, maxPlayer, budget)
+
+		// An interrupted search only keeps its move when there is nothing better
+		finished := !budget.Exhausted()
+		if board < 9 && (finished || bestBoard == 255) {
+			bestMove, bestBoard = move, board
+		}
+		if finished && report != nil {
+			report(Iteration{Depth: d, Score: value, Board: bestBoard, Move: bestMove})
+		}
+	}
+	return bestMove, bestBoard
+}
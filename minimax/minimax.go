@@ -3,7 +3,6 @@ package minimax
 import (
 	"github.com/FabianPetersen/UltimateTicTacToe/Game"
 	"math"
-	"time"
 )
 
 var TranspositionTable = NewStorage()
@@ -25,7 +24,7 @@ type Node struct {
 	flag       Flag
 }
 
-func NewNode(state *Game.Game) (*Node, bool) {
+func NewNode(table *Storage, state *Game.Game) (*Node, bool) {
 	// Rotate and invert board to check if it already exists in cache
 	var oldNode *Node = nil
 	var exists bool = false
@@ -35,7 +34,7 @@ func NewNode(state *Game.Game) (*Node, bool) {
 			for r := 0; r < 4; r++ {
 				// Check if the board exists in the cache
 				if !cacheExists && !exists {
-					if oldNode, exists = TranspositionTable.Get(state.Hash()); exists {
+					if oldNode, exists = table.Get(state.Hash()); exists {
 						cacheExists = true
 					}
 				}
@@ -50,9 +49,17 @@ func NewNode(state *Game.Game) (*Node, bool) {
 
 const inf float64 = 100000
 
-func Search(state *Game.Game, alpha float64, beta float64, depth byte, maxPlayer Game.Player, start *time.Time, maxDuration *time.Duration) (float64, byte, byte) {
+// Search is an alpha-beta search storing its bounds in table, a nil table searches without caching.
+// Every searched position counts as a node of the budget
+func Search(table *Storage, state *Game.Game, alpha float64, beta float64, depth byte, maxPlayer Game.Player, budget *Budget) (float64, byte, byte) {
+	budget.Nodes++
+
 	// Restore the values from the last node
-	n, cached := NewNode(state)
+	var n *Node
+	var cached bool
+	if table != nil {
+		n, cached = NewNode(table, state)
+	}
 	if cached && n.depth >= depth {
 		if n.flag == EXACT {
 			return n.lowerBound, n.bestMove, n.bestBoard
@@ -71,7 +78,7 @@ func Search(state *Game.Game, alpha float64, beta float64, depth byte, maxPlayer
 	var currentBestMove byte = 0
 	var currentBestBoard byte = 0
 	var prevBoard = byte(state.Board[Game.PlayerBoardIndex] >> 1)
-	if depth == 0 || state.IsTerminal() || time.Since(*start) > *maxDuration {
+	if depth == 0 || state.IsTerminal() || budget.Exhausted() {
 		return state.HeuristicPlayer(maxPlayer), 0, 0
 
 		// This is a max node
@@ -80,7 +87,7 @@ func Search(state *Game.Game, alpha float64, beta float64, depth byte, maxPlayer
 		a := alpha
 		state.GetMoves(func(boardIndex byte, move byte) bool {
 			state.MakeMove(boardIndex, move)
-			searchValue, _, _ := Search(state, a, beta, depth-1, maxPlayer, start, maxDuration)
+			searchValue, _, _ := Search(table, state, a, beta, depth-1, maxPlayer, budget)
 			state.UnMakeMove(move, boardIndex, prevBoard)
 
 			if searchValue >= value {
@@ -97,7 +104,7 @@ func Search(state *Game.Game, alpha float64, beta float64, depth byte, maxPlayer
 		b := beta
 		state.GetMoves(func(boardIndex byte, move byte) bool {
 			state.MakeMove(boardIndex, move)
-			searchValue, _, _ := Search(state, alpha, b, depth-1, maxPlayer, start, maxDuration)
+			searchValue, _, _ := Search(table, state, alpha, b, depth-1, maxPlayer, budget)
 			state.UnMakeMove(move, boardIndex, prevBoard)
 			if searchValue <= value {
 				value = searchValue
@@ -140,8 +147,8 @@ func Search(state *Game.Game, alpha float64, beta float64, depth byte, maxPlayer
 		n.flag = LOWER_BOUND
 	}
 	n.depth = depth
-	if !cached {
-		TranspositionTable.Set(state.Hash(), n)
+	if !cached && table != nil {
+		table.Set(state.Hash(), n)
 	}
 
 	return value, n.bestMove, n.bestBoard
@@ -24,5 +24,5 @@ func (storage *Storage) Reset() {
 }
 
 func NewStorage() Storage {
-	return Storage{}
+	return Storage{nodeStore: make(map[[10]uint32]*Node, 150000)}
 }
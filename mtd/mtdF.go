@@ -8,21 +8,21 @@ import (
 
 const inf float64 = 100000
 
-func mtdF(state *Game.Game, start *time.Time, maxDuration *time.Duration, f float64, d byte, maxPlayer Game.Player) (float64, byte, byte) {
+func mtdF(table *minimax.Storage, state *Game.Game, budget *minimax.Budget, f float64, d byte, maxPlayer Game.Player) (float64, byte, byte) {
 	g := f
 	lowerBound, upperBound := -inf, inf
 	beta := -inf
 	var bestMove byte = 253
 	var bestBoard byte = 253
 	var nBestMove, nBestBoard = byte(0), byte(0)
-	for lowerBound < upperBound && time.Since(*start) < *maxDuration {
+	for lowerBound < upperBound && !budget.Exhausted() {
 		if g == lowerBound {
 			beta = g + 1
 		} else {
 			beta = g
 		}
 
-		g, nBestMove, nBestBoard = minimax.Search(state, beta-1, beta, d, maxPlayer, start, maxDuration)
+		g, nBestMove, nBestBoard = minimax.Search(table, state, beta-1, beta, d, maxPlayer, budget)
 		if nBestBoard < 200 && nBestMove < 200 {
 			bestMove = nBestMove
 			bestBoard = nBestBoard
@@ -38,19 +38,33 @@ func mtdF(state *Game.Game, start *time.Time, maxDuration *time.Duration, f floa
 	return g, bestMove, bestBoard
 }
 
+// IterativeDeepeningTime searches with the shared transposition table
 func IterativeDeepeningTime(state *Game.Game, maxDepth byte, maxTime time.Duration) (byte, byte) {
+	return IterativeDeepeningTable(&minimax.TranspositionTable, state, maxDepth, maxTime)
+}
+
+// IterativeDeepeningTable searches with the given transposition table, searches in parallel need their own table
+func IterativeDeepeningTable(table *minimax.Storage, state *Game.Game, maxDepth byte, maxTime time.Duration) (byte, byte) {
+	return IterativeDeepeningBudget(table, state, maxDepth, minimax.NewBudget(maxTime), nil)
+}
+
+// IterativeDeepeningBudget searches until the budget is exhausted, report is called after every finished depth unless it is nil
+func IterativeDeepeningBudget(table *minimax.Storage, state *Game.Game, maxDepth byte, budget *minimax.Budget, report func(minimax.Iteration)) (byte, byte) {
 	// Start the guess at the current heuristic
 	var maxPlayer = Game.Player(state.Board[Game.PlayerBoardIndex] & 0x1)
 	var firstGuess = state.HeuristicPlayer(maxPlayer)
 
 	var bestMove byte = 255
 	var bestBoard byte = 255
-	var d byte = 0
+	// A depth 0 search has no move
+	var d byte = 1
 	// Game.HeuristicStorage.Reset()
 	// minimax.TranspositionTable.Reset()
-	start := time.Now()
-	for ; time.Since(start) < maxTime && d < maxDepth; d++ {
-		firstGuess, bestMove, bestBoard = mtdF(state, &start, &maxTime, firstGuess, d, maxPlayer)
+	for ; budget.Deepen() && d < maxDepth; d++ {
+		firstGuess, bestMove, bestBoard = mtdF(table, state, budget, firstGuess, d, maxPlayer)
+		if report != nil && !budget.Exhausted() {
+			report(minimax.Iteration{Depth: d, Score: firstGuess, Board: bestBoard, Move: bestMove})
+		}
 	}
 	// fmt.Fprintf(os.Stderr, "Stored nodes, %d Depth %d \n", minimax.TranspositionTable.Count(), d)
 	return bestMove, bestBoard
@@ -15,12 +15,12 @@ func TestSpeed(t *testing.T) {
 
 	var mcts *gmcts.MCTS
 	for i := 0; i < 100; i++ {
-		mcts = gmcts.NewMCTS(game)
+		mcts = gmcts.NewMCTS(game, gmcts.DefaultConfig())
 		mcts.SearchRounds(25000)
 	}
 
 	fmt.Println(mcts.BestAction())
-	fmt.Println(gmcts.NodePoolIndex)
+	fmt.Println(mcts.Nodes())
 	fmt.Println(time.Since(start))
 }
 
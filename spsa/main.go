@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+// harness plays the perturbed heuristics against each other with the same games as the genetic tuner
+type harness struct {
+	depth        byte
+	moveTime     time.Duration
+	threads      int
+	openings     int
+	openingPlies int
+	rng          *rand.Rand
+}
+
+func (h *harness) play(plus, minus *Game.HeuristicScores) float64 {
+	openings := [][]match.Move{nil}
+	if h.openingPlies > 0 {
+		openings = make([][]match.Move, h.openings)
+		for i := range openings {
+			openings[i] = match.RandomOpening(h.rng, h.openingPlies)
+		}
+	}
+
+	plusBot := &match.Bot{Heuristic: plus, Depth: h.depth, MoveTime: h.moveTime}
+	minusBot := &match.Bot{Heuristic: minus, Depth: h.depth, MoveTime: h.moveTime}
+	return match.PlayOpenings(plusBot, minusBot, openings, h.threads).Mean()
+}
+
+func writeJSON(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func main() {
+	iterations := flag.Int("iterations", 1000, "SPSA iterations, each plays the openings with both colours")
+	start := flag.String("start", "", "elite or weights file to start from, empty starts from the default heuristic")
+	startIndex := flag.Int("start-index", Game.BestHeuristic, "heuristic of the start file, -1 picks the fittest elite")
+	output := flag.String("output", "spsa_weights.json", "weights file, rewritten after every iteration")
+	logPath := flag.String("log", "spsa_log.txt", "file every iteration is appended to as a JSON line")
+	seed := flag.Int64("seed", 0, "seed of the run, 0 picks one from the clock")
+
+	s := schedule{}
+	flag.Float64Var(&s.A, "a", 0.02, "step size at the first iteration as a fraction of the parameter ranges")
+	flag.Float64Var(&s.C, "c", 0.05, "perturbation at the first iteration as a fraction of the parameter ranges")
+	flag.Float64Var(&s.Alpha, "alpha", 0.602, "decay of the step size")
+	flag.Float64Var(&s.Gamma, "gamma", 0.101, "decay of the perturbation")
+	flag.Float64Var(&s.Stability, "stability", -1, "iterations added to the step size decay, -1 uses a tenth of the iterations")
+
+	h := harness{}
+	depth := flag.Int("depth", 5, "maximum search depth per move")
+	flag.DurationVar(&h.moveTime, "move-time", time.Millisecond*250, "search time per move, 0 bounds the search by depth only to make runs reproducible")
+	flag.IntVar(&h.threads, "threads", 16, "games played at the same time")
+	flag.IntVar(&h.openings, "openings", 8, "random openings per iteration")
+	flag.IntVar(&h.openingPlies, "opening-plies", 2, "moves of the random openings, 0 plays the start position")
+	flag.Parse()
+
+	if *iterations < 1 || *depth < 2 || *depth > 255 || h.threads < 1 || h.openings < 1 || h.moveTime < 0 || s.C <= 0 {
+		log.Fatalln("invalid settings, see -help")
+	}
+	if s.Stability < 0 {
+		s.Stability = float64(*iterations) / 10
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	h.depth = byte(*depth)
+	h.rng = rand.New(rand.NewSource(*seed))
+	Game.Seed(*seed)
+
+	heuristic := Game.DefaultHeuristic()
+	if *start != "" {
+		var err error
+		if heuristic, err = Game.LoadHeuristic(*start, *startIndex); err != nil {
+			log.Fatalln("start:", err)
+		}
+	}
+
+	logFile, err := os.OpenFile(*logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer logFile.Close()
+	encoder := json.NewEncoder(logFile)
+
+	fmt.Println("seed", *seed)
+	t := newTuner(heuristic, s, rand.New(rand.NewSource(*seed+1)), h.play)
+	for k := 0; k < *iterations; k++ {
+		step := t.step(k)
+		fmt.Printf("%d\tscore %.3f\tstep %.4f\tperturbation %.4f\n", step.Iteration, step.Score, step.StepSize, step.Perturbation)
+
+		if err = encoder.Encode(step); err != nil {
+			log.Fatalln("log:", err)
+		}
+		if err = writeJSON(*output, step.Heuristic); err != nil {
+			log.Fatalln("output:", err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/tuning"
+)
+
+// schedule is the gain sequence of SPSA, in iteration k the step is A / (k + 1 + Stability)^Alpha
+// and the perturbation C / (k + 1)^Gamma, both are fractions of the parameter ranges
+type schedule struct {
+	A         float64
+	C         float64
+	Alpha     float64
+	Gamma     float64
+	Stability float64
+}
+
+func (s *schedule) gains(k int) (float64, float64) {
+	return s.A / math.Pow(float64(k)+1+s.Stability, s.Alpha), s.C / math.Pow(float64(k)+1, s.Gamma)
+}
+
+// playFunc plays plus against minus and returns the mean score of plus
+type playFunc func(plus, minus *Game.HeuristicScores) float64
+
+// tuner keeps the parameters as fractions of their range so one gain fits all of them
+type tuner struct {
+	schedule schedule
+	rng      *rand.Rand
+	play     playFunc
+	theta    []float64
+}
+
+// iteration is one step of the trajectory, the heuristic is the one after the step
+type iteration struct {
+	Iteration    int
+	StepSize     float64
+	Perturbation float64
+	Score        float64
+	Heuristic    *Game.HeuristicScores
+}
+
+func newTuner(start *Game.HeuristicScores, s schedule, rng *rand.Rand, play playFunc) *tuner {
+	t := &tuner{schedule: s, rng: rng, play: play, theta: make([]float64, len(tuning.Params))}
+	for i, param := range tuning.Params {
+		t.theta[i] = (param.Clamp(*param.Field(start)) - param.Min) / param.Range()
+	}
+	return t
+}
+
+// heuristic builds the heuristic at theta, every value is clamped to the range of its parameter
+func (t *tuner) heuristic(theta []float64) *Game.HeuristicScores {
+	h := &Game.HeuristicScores{}
+	for i, param := range tuning.Params {
+		*param.Field(h) = param.Clamp(param.Min + theta[i]*param.Range())
+	}
+	h.Normalise()
+	return h
+}
+
+// step perturbs every parameter up or down at random, plays both sides against each other and
+// moves along the estimated gradient
+func (t *tuner) step(k int) iteration {
+	a, c := t.schedule.gains(k)
+	delta := make([]float64, len(t.theta))
+	plus := make([]float64, len(t.theta))
+	minus := make([]float64, len(t.theta))
+	for i := range t.theta {
+		delta[i] = float64(t.rng.Intn(2)*2 - 1)
+		plus[i] = t.theta[i] + c*delta[i]
+		minus[i] = t.theta[i] - c*delta[i]
+	}
+
+	// The score difference of plus and minus, a win of plus in every game is 1
+	score := t.play(t.heuristic(plus), t.heuristic(minus))
+	difference := 2*score - 1
+	for i := range t.theta {
+		t.theta[i] = math.Max(0, math.Min(1, t.theta[i]+a*difference/(2*c*delta[i])))
+	}
+
+	return iteration{Iteration: k + 1, StepSize: a, Perturbation: c, Score: score, Heuristic: t.heuristic(t.theta)}
+}
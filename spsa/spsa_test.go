@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/tuning"
+)
+
+// distance is how far the heuristic is from the middle of every parameter range
+func distance(h *Game.HeuristicScores) float64 {
+	sum := 0.0
+	for _, param := range tuning.Params {
+		x := (*param.Field(h) - param.Min) / param.Range()
+		sum += (x - 0.5) * (x - 0.5)
+	}
+	return sum
+}
+
+func TestTunerClimbsTowardsTheStrongerHeuristic(t *testing.T) {
+	// The heuristic closer to the middle of the ranges wins more often
+	play := func(plus, minus *Game.HeuristicScores) float64 {
+		return 1 / (1 + math.Exp(20*(distance(plus)-distance(minus))))
+	}
+
+	start := Game.DefaultHeuristic()
+	tuner := newTuner(start, schedule{A: 0.05, C: 0.05, Alpha: 0.602, Gamma: 0.101, Stability: 10}, rand.New(rand.NewSource(1)), play)
+	var last iteration
+	for k := 0; k < 300; k++ {
+		last = tuner.step(k)
+	}
+
+	if before, after := distance(start), distance(last.Heuristic); after > before/2 {
+		t.Fatalf("distance went from %f to %f", before, after)
+	}
+	for _, param := range tuning.Params {
+		if value := *param.Field(last.Heuristic); param.Clamp(value) != value {
+			t.Errorf("%s left its range with %f", param.Name, value)
+		}
+	}
+}
+
+func TestScheduleDecays(t *testing.T) {
+	s := schedule{A: 0.1, C: 0.05, Alpha: 0.602, Gamma: 0.101, Stability: 5}
+	a0, c0 := s.gains(0)
+	a1, c1 := s.gains(100)
+	if a0 != 0.1/math.Pow(6, 0.602) || c0 != 0.05 {
+		t.Fatalf("first gains %f and %f", a0, c0)
+	}
+	if a1 >= a0 || c1 >= c0 {
+		t.Fatal("gains did not decay")
+	}
+}
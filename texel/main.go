@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+func main() {
+	positionsPath := flag.String("positions", "positions.txt", "file of positions labelled with the result of their game")
+	generate := flag.Int("generate", 0, "play this many self-play games and append their positions instead of tuning")
+	start := flag.String("start", "", "elite or weights file of the tuned and self-playing heuristic, empty uses the default heuristic")
+	startIndex := flag.Int("start-index", Game.BestHeuristic, "heuristic of the start file, -1 picks the fittest elite")
+	output := flag.String("output", "texel_weights.json", "weights file written after tuning")
+	k := flag.Float64("k", 0, "slope of the logistic curve, 0 fits it to the start heuristic")
+	passes := flag.Int("passes", 1000, "maximum passes of the local search over all parameters")
+
+	depth := flag.Int("depth", 5, "maximum search depth per self-play move")
+	moveTime := flag.Duration("move-time", time.Millisecond*100, "search time per self-play move, 0 bounds the search by depth only")
+	openingPlies := flag.Int("opening-plies", 4, "random moves at the start of every self-play game, their positions are skipped")
+	threads := flag.Int("threads", 16, "self-play games played at the same time")
+	seed := flag.Int64("seed", 0, "seed of the self-play openings, 0 picks one from the clock")
+	flag.Parse()
+
+	heuristic := Game.DefaultHeuristic()
+	if *start != "" {
+		var err error
+		if heuristic, err = Game.LoadHeuristic(*start, *startIndex); err != nil {
+			log.Fatalln("start:", err)
+		}
+	}
+
+	if *generate > 0 {
+		if *depth < 2 || *depth > 255 || *threads < 1 || *moveTime < 0 || *openingPlies < 0 {
+			log.Fatalln("invalid self-play settings, see -help")
+		}
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+		}
+		Game.Seed(*seed)
+
+		bot := &match.Bot{Heuristic: heuristic, Depth: byte(*depth), MoveTime: *moveTime}
+		positions := selfPlay(bot, *generate, *openingPlies, *threads, rand.New(rand.NewSource(*seed)))
+
+		f, err := os.OpenFile(*positionsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err = writePositions(f, positions); err == nil {
+			err = f.Close()
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("wrote", len(positions), "positions of", *generate, "games, seed", *seed)
+		return
+	}
+
+	f, err := os.Open(*positionsPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	positions, err := readPositions(f)
+	_ = f.Close()
+	if err != nil {
+		log.Fatalln(*positionsPath+":", err)
+	}
+	if len(positions) == 0 {
+		log.Fatalln(*positionsPath, "has no positions")
+	}
+
+	if *k == 0 {
+		*k = fitK(positions, heuristic)
+	}
+	fmt.Printf("%d positions, k %g, loss %f\n", len(positions), *k, loss(positions, heuristic, *k))
+
+	tuned := localSearch(positions, heuristic, *k, *passes, func(pass int, loss float64) {
+		fmt.Printf("pass %d\tloss %f\n", pass, loss)
+	})
+
+	data, err := json.MarshalIndent(tuned, "", "  ")
+	if err == nil {
+		err = os.WriteFile(*output, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+// position is a position of a finished game, Result is the score of Player1 in that game
+type position struct {
+	Board        [10]uint32
+	OverallBoard uint32
+	Result       float64
+}
+
+// String writes the board, the overall board and the result separated by spaces
+func (p position) String() string {
+	fields := make([]string, 0, len(p.Board)+2)
+	for _, b := range p.Board {
+		fields = append(fields, strconv.FormatUint(uint64(b), 10))
+	}
+	fields = append(fields, strconv.FormatUint(uint64(p.OverallBoard), 10), strconv.FormatFloat(p.Result, 'g', -1, 64))
+	return strings.Join(fields, " ")
+}
+
+func parsePosition(line string) (position, error) {
+	p := position{}
+	fields := strings.Fields(line)
+	if len(fields) != len(p.Board)+2 {
+		return p, fmt.Errorf("%d fields, want %d", len(fields), len(p.Board)+2)
+	}
+
+	for i := range p.Board {
+		b, err := strconv.ParseUint(fields[i], 10, 32)
+		if err != nil {
+			return p, err
+		}
+		p.Board[i] = uint32(b)
+	}
+	overall, err := strconv.ParseUint(fields[len(p.Board)], 10, 32)
+	if err != nil {
+		return p, err
+	}
+	p.OverallBoard = uint32(overall)
+
+	if p.Result, err = strconv.ParseFloat(fields[len(p.Board)+1], 64); err != nil {
+		return p, err
+	}
+	if p.Result != 0 && p.Result != 0.5 && p.Result != 1 {
+		return p, fmt.Errorf("result %v is not 0, 0.5 or 1", p.Result)
+	}
+	return p, nil
+}
+
+// readPositions reads one position per line, empty lines are skipped
+func readPositions(r io.Reader) ([]position, error) {
+	var positions []position
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		p, err := parsePosition(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		positions = append(positions, p)
+	}
+	return positions, scanner.Err()
+}
+
+func writePositions(w io.Writer, positions []position) error {
+	buffered := bufio.NewWriter(w)
+	for _, p := range positions {
+		if _, err := fmt.Fprintln(buffered, p); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// labelGame returns the positions after every move that was not part of the opening, the terminal one excluded
+func labelGame(moves []match.Move, openingPlies int, final *Game.Game) []position {
+	result := 0.5
+	switch final.WinningPlayer() {
+	case Game.Player1:
+		result = 1
+	case Game.Player2:
+		result = 0
+	}
+
+	var positions []position
+	game := Game.NewGame()
+	for i, move := range moves {
+		game.MakeMove(move.Board, move.Pos)
+		if i+1 >= openingPlies && !game.IsTerminal() {
+			positions = append(positions, position{Board: game.Board, OverallBoard: game.OverallBoard, Result: result})
+		}
+	}
+	return positions
+}
+
+// selfPlay plays the bot against itself from random openings, the positions keep the order of the games
+func selfPlay(bot *match.Bot, games int, openingPlies int, threads int, rng *rand.Rand) []position {
+	type game struct {
+		index   int
+		opening []match.Move
+	}
+
+	positions := make([][]position, games)
+	queue := make(chan game)
+	var wait sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for g := range queue {
+				record := match.PlayRecord([2]*match.Bot{bot, bot}, g.opening)
+				positions[g.index] = labelGame(record.Moves, record.Opening, record.Final)
+			}
+		}()
+	}
+
+	for i := 0; i < games; i++ {
+		queue <- game{index: i, opening: match.RandomOpening(rng, openingPlies)}
+	}
+	close(queue)
+	wait.Wait()
+
+	var all []position
+	for _, game := range positions {
+		all = append(all, game...)
+	}
+	return all
+}
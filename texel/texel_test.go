@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/tuning"
+)
+
+// randomPositions labels the positions of random games
+func randomPositions(games int) []position {
+	rng := rand.New(rand.NewSource(1))
+	var positions []position
+	for i := 0; i < games; i++ {
+		moves := match.RandomOpening(rng, 81)
+		final := Game.NewGame()
+		for _, move := range moves {
+			final.MakeMove(move.Board, move.Pos)
+		}
+		positions = append(positions, labelGame(moves, 6, final)...)
+	}
+	return positions
+}
+
+func TestPositionsRoundTrip(t *testing.T) {
+	positions := randomPositions(3)
+	buffer := bytes.Buffer{}
+	if err := writePositions(&buffer, positions); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := readPositions(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(positions) {
+		t.Fatalf("read %d positions, wrote %d", len(read), len(positions))
+	}
+	for i := range read {
+		if read[i] != positions[i] {
+			t.Fatalf("position %d changed from %v to %v", i, positions[i], read[i])
+		}
+	}
+
+	if _, err = parsePosition("1 2 3"); err == nil {
+		t.Fatal("a short line was accepted")
+	}
+}
+
+func TestLocalSearchLowersLoss(t *testing.T) {
+	positions := randomPositions(30)
+	start := Game.DefaultHeuristic()
+	k := fitK(positions, start)
+	if loss(positions, start, k) > loss(positions, start, k*4) || loss(positions, start, k) > loss(positions, start, k/4) {
+		t.Fatalf("k %g is not the best slope", k)
+	}
+
+	before := loss(positions, start, k)
+	tuned := localSearch(positions, start, k, 3, nil)
+	if after := loss(positions, tuned, k); after > before {
+		t.Fatalf("loss rose from %f to %f", before, after)
+	}
+	for _, param := range tuning.Params {
+		if value := *param.Field(tuned); param.Clamp(value) != value {
+			t.Errorf("%s left its range with %f", param.Name, value)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/tuning"
+)
+
+// evaluate is the static evaluation of the position for Player1
+func (p *position) evaluate(h *Game.HeuristicScores) float64 {
+	game := Game.Game{Board: p.Board, OverallBoard: p.OverallBoard, HeuristicScores: h}
+	return game.HeuristicPlayer(Game.Player1)
+}
+
+// loss is the mean squared error of the results and the evaluations mapped to [0, 1] by a logistic curve of slope k
+func loss(positions []position, h *Game.HeuristicScores, k float64) float64 {
+	sum := 0.0
+	for i := range positions {
+		predicted := 1 / (1 + math.Exp(-k*positions[i].evaluate(h)))
+		sum += (positions[i].Result - predicted) * (positions[i].Result - predicted)
+	}
+	return sum / float64(len(positions))
+}
+
+// fitK finds the slope with the least loss for the heuristic by a ternary search over log10(k) in [-6, 1]
+func fitK(positions []position, h *Game.HeuristicScores) float64 {
+	low, high := -6.0, 1.0
+	for high-low > 1e-3 {
+		a, b := low+(high-low)/3, high-(high-low)/3
+		if loss(positions, h, math.Pow(10, a)) < loss(positions, h, math.Pow(10, b)) {
+			high = b
+		} else {
+			low = a
+		}
+	}
+	return math.Pow(10, (low+high)/2)
+}
+
+// localSearch moves one parameter at a time up or down while it lowers the loss. The step starts at a sixteenth of
+// the range and is halved after a pass without improvement, the search ends when even the resolution does not help
+func localSearch(positions []position, start *Game.HeuristicScores, k float64, maxPasses int, progress func(pass int, loss float64)) *Game.HeuristicScores {
+	best := *start
+	best.Normalise()
+	bestLoss := loss(positions, &best, k)
+
+	fraction := 1.0 / 16
+	for pass := 1; pass <= maxPasses; pass++ {
+		improved, finest := false, true
+		for _, param := range tuning.Params {
+			step := math.Max(param.Resolution, param.Range()*fraction)
+			finest = finest && step == param.Resolution
+
+			for _, direction := range []float64{1, -1} {
+				candidate := best
+				value := param.Clamp(*param.Field(&candidate) + direction*step)
+				if value == *param.Field(&candidate) {
+					continue
+				}
+				*param.Field(&candidate) = value
+				candidate.Normalise()
+
+				if candidateLoss := loss(positions, &candidate, k); candidateLoss < bestLoss {
+					best, bestLoss, improved = candidate, candidateLoss, true
+					break
+				}
+			}
+		}
+
+		if progress != nil {
+			progress(pass, bestLoss)
+		}
+		if !improved {
+			if finest {
+				break
+			}
+			fraction /= 2
+		}
+	}
+	return &best
+}
@@ -0,0 +1,133 @@
+// Package timing allocates the time of the moves of a game under a time control
+package timing
+
+import (
+	"math"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+)
+
+const (
+	// NoTimeLimit is the allocation without a time control
+	NoTimeLimit = time.Duration(math.MaxInt64)
+
+	// MinTime is the least time of a move, a move with a single legal reply gets no more
+	MinTime = time.Millisecond
+
+	// expectedPlies is the length of a typical game, the moves left are estimated from it
+	expectedPlies = 50
+
+	// minMovesLeft keeps time back for the end of a game that takes longer than expected
+	minMovesLeft = 8
+
+	// maxStretch is how far a search may go beyond its target to finish a depth, in targets
+	maxStretch = 3
+)
+
+// Control is the time a player has for a game. Total is the time for all moves and Increment is added after every
+// move, MoveTime caps every move. Without a Total every move may take MoveTime, without both a move has no limit
+type Control struct {
+	Total     time.Duration
+	Increment time.Duration
+	MoveTime  time.Duration
+
+	// Overhead is kept back from every move for the time it takes the move to reach the opponent
+	Overhead time.Duration
+}
+
+// Complexity is what the allocation of a move looks at besides the clock
+type Complexity struct {
+	// Moves are the legal moves
+	Moves int
+
+	// SendsAnywhere counts the moves after which the opponent may play any open board
+	SendsAnywhere int
+}
+
+// Measure returns the complexity of the position for the player to move
+func Measure(game *Game.Game) Complexity {
+	c := Complexity{}
+	game.GetMoves(func(board byte, pos byte) bool {
+		c.Moves++
+		// The square decides the board of the opponent, it may also be the board just finished
+		state := game.Copy()
+		state.MakeMove(board, pos)
+		if state.IsBoardFinished(pos) {
+			c.SendsAnywhere++
+		}
+		return false
+	})
+	return c
+}
+
+// factor scales the time of a move by its complexity, an average position with nine moves takes 1.
+// A board holds at most nine moves, so the choice of any open board shows in the moves
+func (c Complexity) factor() float64 {
+	moves := c.Moves
+	if moves > 27 {
+		moves = 27
+	}
+	f := 0.5 + float64(moves)/18
+	if c.SendsAnywhere > 0 {
+		// A mistake gives the opponent the choice of every board
+		f *= 1.25
+	}
+	return f
+}
+
+// Allocate returns the time of the next move with remaining on the clock. Iterative searches start no new depth
+// after soft and every search ends at hard
+func (c Control) Allocate(remaining time.Duration, game *Game.Game) (soft time.Duration, hard time.Duration) {
+	complexity := Measure(game)
+	if complexity.Moves <= 1 {
+		return MinTime, MinTime
+	}
+
+	if c.Total == 0 {
+		if c.MoveTime == 0 {
+			return NoTimeLimit, NoTimeLimit
+		}
+		hard = atLeast(c.MoveTime - c.Overhead)
+		return hard, hard
+	}
+
+	available := remaining - c.Overhead
+	movesLeft := (expectedPlies - int(game.MovesMade()) + 1) / 2
+	if movesLeft < minMovesLeft {
+		movesLeft = minMovesLeft
+	}
+	target := available/time.Duration(movesLeft) + c.Increment*3/4
+	soft = time.Duration(float64(target) * complexity.factor())
+
+	// The clock must survive the hard limit even when the next moves take as long
+	hard = maxStretch * soft
+	if hard > available/4 {
+		hard = available / 4
+	}
+	if c.MoveTime > 0 && hard > c.MoveTime-c.Overhead {
+		hard = c.MoveTime - c.Overhead
+	}
+	hard = atLeast(hard)
+	if soft > hard {
+		soft = hard
+	}
+	return atLeast(soft), hard
+}
+
+func atLeast(d time.Duration) time.Duration {
+	if d < MinTime {
+		return MinTime
+	}
+	return d
+}
+
+// Budget allocates the next move and returns the budget of its search, the move starts now.
+// Stop of the budget is the emergency stop, the search returns its best move at once
+func (c Control) Budget(remaining time.Duration, game *Game.Game) *minimax.Budget {
+	soft, hard := c.Allocate(remaining, game)
+	budget := minimax.NewBudget(hard)
+	budget.SoftTime = soft
+	return budget
+}
@@ -0,0 +1,64 @@
+package timing
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+func TestAllocate(t *testing.T) {
+	game := Game.NewGame()
+
+	if soft, hard := (Control{}).Allocate(0, game); soft != NoTimeLimit || hard != NoTimeLimit {
+		t.Errorf("no time control allocates %s %s", soft, hard)
+	}
+	moveTime := Control{MoveTime: 100 * time.Millisecond, Overhead: 10 * time.Millisecond}
+	if soft, hard := moveTime.Allocate(0, game); soft != 90*time.Millisecond || hard != 90*time.Millisecond {
+		t.Errorf("a move time allocates %s %s", soft, hard)
+	}
+
+	clock := Control{Total: 10 * time.Second, Increment: 100 * time.Millisecond}
+	soft, hard := clock.Allocate(clock.Total, game)
+	if soft <= 0 || soft > hard || hard > clock.Total/4 {
+		t.Errorf("the start of a game allocates %s %s", soft, hard)
+	}
+	if _, short := clock.Allocate(time.Second, game); short > time.Second/4 {
+		t.Errorf("a short clock allocates up to %s", short)
+	}
+	if soft, hard := clock.Allocate(0, game); soft != MinTime || hard != MinTime {
+		t.Errorf("an empty clock allocates %s %s", soft, hard)
+	}
+
+	// Any open board may be played, which takes longer than the nine squares of a board
+	free := Game.NewGame()
+	free.Board[Game.PlayerBoardIndex] |= 0x100
+	if freeSoft, _ := clock.Allocate(clock.Total, free); freeSoft <= soft {
+		t.Errorf("%s for any board, %s for one board", freeSoft, soft)
+	}
+}
+
+func TestSingleMove(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	clock := Control{Total: 10 * time.Second}
+	for i := 0; i < 1000; i++ {
+		game := Game.NewGame()
+		for !game.IsTerminal() {
+			moves := [][2]byte{}
+			game.GetMoves(func(board byte, pos byte) bool {
+				moves = append(moves, [2]byte{board, pos})
+				return false
+			})
+			if len(moves) == 1 {
+				if soft, hard := clock.Allocate(clock.Total, game); soft != MinTime || hard != MinTime {
+					t.Errorf("a single move allocates %s %s", soft, hard)
+				}
+				return
+			}
+			move := moves[random.Intn(len(moves))]
+			game.MakeMove(move[0], move[1])
+		}
+	}
+	t.Fatal("no position with a single move")
+}
@@ -11,8 +11,8 @@ func TestTiming(t *testing.T) {
 	game.MakeMove(8, 8)
 
 	start := time.Now()
-	mcts := gmcts.NewMCTS(game)
+	mcts := gmcts.NewMCTS(game, gmcts.DefaultConfig())
 	mcts.SearchTime(97 * time.Millisecond)
 	fmt.Println(time.Since(start))
-	fmt.Println(gmcts.NodePoolIndex)
+	fmt.Println(mcts.Nodes())
 }
@@ -0,0 +1,52 @@
+package tuning
+
+import (
+	"math"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+// Param is a tunable field of HeuristicScores, values are kept within [Min, Max]
+// and Resolution is the smallest change worth making
+type Param struct {
+	Name       string
+	Min        float64
+	Max        float64
+	Resolution float64
+	Field      func(h *Game.HeuristicScores) *float64
+}
+
+// Params are the tuned fields, the derived BoardRating and PosRating arrays are rebuilt by Normalise
+var Params = []Param{
+	{"BoardCornerRating", 0, 5.11, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.BoardCornerRating }},
+	{"BoardSideRating", 0, 5.11, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.BoardSideRating }},
+	{"BoardMiddleRating", 0, 5.11, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.BoardMiddleRating }},
+	{"PosCornerRating", 0, 5.11, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.PosCornerRating }},
+	{"PosSideRating", 0, 5.11, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.PosSideRating }},
+	{"PosMiddleRating", 0, 5.11, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.PosMiddleRating }},
+	{"OverallWinLossRating", 0, 8191, 1, func(h *Game.HeuristicScores) *float64 { return &h.OverallWinLossRating }},
+	{"OverallAlmostDrawWinLossRating", 0, 8191, 1, func(h *Game.HeuristicScores) *float64 { return &h.OverallAlmostDrawWinLossRating }},
+	{"GlobalStateRating", 0, 204.75, 0.05, func(h *Game.HeuristicScores) *float64 { return &h.GlobalStateRating }},
+	{"OverallBoardMultiplierRating", 0, 409.5, 0.1, func(h *Game.HeuristicScores) *float64 { return &h.OverallBoardMultiplierRating }},
+	{"WinMovesMadeLossRating", 0, 40.95, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.WinMovesMadeLossRating }},
+	{"LossMovesMadeAdvantageRating", 0, 40.95, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.LossMovesMadeAdvantageRating }},
+	{"TwoInARowAdvantageRating", 0, 40.95, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.TwoInARowAdvantageRating }},
+	{"EnemyTwoInARowLossRating", 0, 40.95, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.EnemyTwoInARowLossRating }},
+	{"EnemyWonBoardLossRating", 0, 40.95, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.EnemyWonBoardLossRating }},
+	{"EnemyWonBoardDiscountRating", 0, 2.55, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.EnemyWonBoardDiscountRating }},
+	{"WonBoardRating", 0, 40.95, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.WonBoardRating }},
+	{"DrawBoardScoreEnemyDiscountRating", 0, 2.55, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.DrawBoardScoreEnemyDiscountRating }},
+	{"DrawBoardScorePlayerDiscountRating", 0, 2.55, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.DrawBoardScorePlayerDiscountRating }},
+	{"LocalBoardWinPlayedMovesDiscountRating", 0, 2.55, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.LocalBoardWinPlayedMovesDiscountRating }},
+	{"OverallBoardWinPlayedMovesDiscountRating", 0, 2.55, 0.01, func(h *Game.HeuristicScores) *float64 { return &h.OverallBoardWinPlayedMovesDiscountRating }},
+}
+
+// Clamp moves f into the range of the parameter
+func (p *Param) Clamp(f float64) float64 {
+	return math.Max(p.Min, math.Min(p.Max, f))
+}
+
+// Range is the width of the parameter range
+func (p *Param) Range() float64 {
+	return p.Max - p.Min
+}
@@ -0,0 +1,40 @@
+package tuning
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+)
+
+func TestParamsCoverHeuristicScores(t *testing.T) {
+	h := Game.HeuristicScores{}
+	value := reflect.ValueOf(&h).Elem()
+	seen := map[string]bool{}
+	for _, param := range Params {
+		if seen[param.Name] {
+			t.Errorf("%s is tuned twice", param.Name)
+		}
+		seen[param.Name] = true
+
+		field := value.FieldByName(param.Name)
+		if !field.IsValid() || field.Addr().Interface().(*float64) != param.Field(&h) {
+			t.Errorf("%s does not point to the field of the same name", param.Name)
+		}
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		if name := value.Type().Field(i).Name; value.Field(i).Kind() == reflect.Float64 && !seen[name] {
+			t.Errorf("%s is not tuned", name)
+		}
+	}
+}
+
+func TestDefaultHeuristicWithinRange(t *testing.T) {
+	h := Game.DefaultHeuristic()
+	for _, param := range Params {
+		if value := *param.Field(h); param.Clamp(value) != value {
+			t.Errorf("%s default %f is outside [%f, %f]", param.Name, value, param.Min, param.Max)
+		}
+	}
+}
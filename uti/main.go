@@ -0,0 +1,26 @@
+package main
+
+import "os"
+
+// uti serves the engine over a line protocol on stdin and stdout, modelled after UCI:
+//
+//	uti                                  identifies the engine and lists the options, answered by utiok
+//	isready                              answered by readyok
+//	setoption name Engine value <spec>   a bot spec as the arena takes it, e.g. mcts:rounds=20000,time=0s
+//	utinewgame                           clears the transposition table and the MCTS tree
+//	position startpos|<position> [moves <move>...]
+//	go [ponder] [movetime <ms>] [xtime <ms>] [otime <ms>] [xinc <ms>] [oinc <ms>] [depth <plies>] [nodes <n>] [infinite]
+//	ponderhit                            the opponent played the ponder move, the search continues on the limits of go
+//	stop                                 ends the search, the best move is sent as bestmove <move> [ponder <move>]
+//	quit
+//
+// With the clock of the player to move the time of the move is allocated from it, see timing.Control.
+// The ponder move of bestmove is the expected reply, go ponder searches a position ending with it until ponderhit or
+// stop. After a stop the next search reuses the transposition table, the MCTS tree is kept while the positions of
+// the searches follow each other by one move.
+// A move is its board and square digit, e.g. 84, and a position is written as by match.FormatPosition.
+// While searching the engine sends info depth <d> score value <v> nodes <n> time <ms> pv <move>...,
+// MCTS sends info score winrate <w> once at the end of the search
+func main() {
+	newEngine(os.Stdout).run(os.Stdin)
+}
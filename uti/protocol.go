@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/Game"
+	"github.com/FabianPetersen/UltimateTicTacToe/gmcts"
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+	"github.com/FabianPetersen/UltimateTicTacToe/minimax"
+	"github.com/FabianPetersen/UltimateTicTacToe/timing"
+)
+
+// noTimeLimit bounds the searches of go infinite and of go with only a depth or nodes
+const noTimeLimit = time.Duration(math.MaxInt64)
+
+const defaultEngine = "mtd:time=1s,depth=64"
+
+// engine answers the commands of the protocol, one search runs at a time in its own goroutine.
+// Commands that change the position or the bot stop a running search first
+type engine struct {
+	out  io.Writer
+	lock sync.Mutex
+
+	bot   *match.Bot
+	game  *Game.Game
+	table minimax.Storage
+
+	// previous is the position before the last move of the position command, nil without moves
+	previous *Game.Game
+	last     match.Move
+
+	// tree is the MCTS search of treeGame, it follows the positions of the searches while they are one move apart
+	tree     *gmcts.MCTS
+	treeGame Game.Game
+
+	budget *minimax.Budget
+	done   chan struct{}
+
+	// While pondering hit continues with the search of the move and release holds back the best move.
+	// treePonder is set while MCTS searches the tree of the position before the ponder move
+	hit        func()
+	release    chan struct{}
+	treePonder bool
+}
+
+func newEngine(out io.Writer) *engine {
+	bot, _ := match.ParseBot(defaultEngine)
+	return &engine{out: out, bot: bot, game: Game.NewGame(), table: minimax.NewStorage()}
+}
+
+// send writes a line, the search goroutine and the command loop share the output
+func (e *engine) send(format string, args ...any) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+// run reads commands until quit or the end of the input, a running search is stopped before returning
+func (e *engine) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" {
+			break
+		}
+		if err := e.command(fields[0], fields[1:]); err != nil {
+			e.send("info string %s: %s", fields[0], err)
+		}
+	}
+	e.stop()
+}
+
+func (e *engine) command(name string, args []string) error {
+	switch name {
+	case "uti":
+		e.send("id name UltimateTicTacToe")
+		e.send("id author Fabian Petersen")
+		e.send("option name Engine type string default %s", defaultEngine)
+		e.send("utiok")
+
+	case "isready":
+		e.send("readyok")
+
+	case "setoption":
+		e.stop()
+		return e.setOption(args)
+
+	case "utinewgame":
+		e.stop()
+		e.game, e.previous = Game.NewGame(), nil
+		e.table.Reset()
+		e.closeTree()
+
+	case "position":
+		e.stop()
+		return e.position(args)
+
+	case "go":
+		e.stop()
+		return e.search(args)
+
+	case "ponderhit":
+		if e.hit == nil {
+			return fmt.Errorf("not pondering")
+		}
+		hit := e.hit
+		e.hit = nil
+		hit()
+
+	case "stop":
+		e.stop()
+
+	default:
+		return fmt.Errorf("unknown command")
+	}
+	return nil
+}
+
+// setOption reads setoption name <name> value <value>, the Engine option takes a spec of match.ParseBot
+func (e *engine) setOption(args []string) error {
+	if len(args) < 4 || args[0] != "name" || args[2] != "value" {
+		return fmt.Errorf("want name <name> value <value>")
+	}
+	if args[1] != "Engine" {
+		return fmt.Errorf("unknown option %s", args[1])
+	}
+
+	bot, err := match.ParseBot(strings.Join(args[3:], " "))
+	if err != nil {
+		return err
+	}
+	// The stored bounds depend on the heuristic
+	e.bot = bot
+	e.table.Reset()
+	e.closeTree()
+	return nil
+}
+
+// position reads position startpos|<position> [moves <move>...], a position is written as by match.FormatPosition
+func (e *engine) position(args []string) error {
+	notation := args
+	moves := []string(nil)
+	for i, arg := range args {
+		if arg == "moves" {
+			notation, moves = args[:i], args[i+1:]
+			break
+		}
+	}
+
+	var game *Game.Game
+	if len(notation) == 1 && notation[0] == "startpos" {
+		game = Game.NewGame()
+	} else {
+		var err error
+		if game, err = match.ParsePosition(strings.Join(notation, " ")); err != nil {
+			return err
+		}
+	}
+
+	var previous *Game.Game
+	var last match.Move
+	for _, field := range moves {
+		move, err := match.ParseMove(field)
+		if err != nil {
+			return err
+		}
+		if game.IsTerminal() || !game.ValidMove(move.Board, move.Pos) {
+			return fmt.Errorf("illegal move %s", move)
+		}
+		state := game.Copy()
+		previous, last = &state, move
+		game.MakeMove(move.Board, move.Pos)
+	}
+	e.game, e.previous, e.last = game, previous, last
+	return nil
+}
+
+// search reads go [ponder] [movetime <ms>] [xtime <ms>] [otime <ms>] [xinc <ms>] [oinc <ms>] [depth <plies>] [nodes <n>]
+// [infinite], without limits the bot settings are used. The time of the move is allocated from the clock of the player
+// to move when it is given, only movetime then caps it. The best move is sent once the search ends, a depth or nodes
+// without a time search without a time limit.
+// With ponder the last move of the position is the expected reply of the opponent and the search runs without limits
+// until ponderhit, the limits then apply from the ponderhit. MCTS searches the tree of the position before the reply
+func (e *engine) search(args []string) error {
+	bot := *e.bot
+	moveTime := bot.MoveTime
+	var nodes uint64
+	if bot.MCTS != nil && moveTime == 0 {
+		nodes = uint64(bot.Rounds)
+	}
+
+	var clocks, increments [2]time.Duration
+	limited, timed, moveTimed, ponder := false, false, false, false
+	for i := 0; i < len(args); i++ {
+		if args[i] == "ponder" {
+			ponder = true
+			continue
+		}
+		limited = true
+		if args[i] == "infinite" {
+			nodes, bot.Depth = 0, 255
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("%s has no value", args[i])
+		}
+
+		value, err := strconv.ParseUint(args[i+1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[i], err)
+		}
+		i++
+		switch args[i-1] {
+		case "movetime":
+			moveTime, timed, moveTimed = time.Duration(value)*time.Millisecond, true, true
+		case "xtime":
+			clocks[Game.Player1], timed = time.Duration(value)*time.Millisecond, true
+		case "otime":
+			clocks[Game.Player2], timed = time.Duration(value)*time.Millisecond, true
+		case "xinc":
+			increments[Game.Player1] = time.Duration(value) * time.Millisecond
+		case "oinc":
+			increments[Game.Player2] = time.Duration(value) * time.Millisecond
+		case "depth":
+			// The searches stop below the depth of the bot
+			bot.Depth = 255
+			if value < 255 {
+				bot.Depth = byte(value + 1)
+			}
+		case "nodes":
+			nodes = value
+		default:
+			return fmt.Errorf("unknown limit %s", args[i-1])
+		}
+	}
+	if moveTime == 0 || (limited && !timed) {
+		moveTime = noTimeLimit
+	}
+
+	if e.game.IsTerminal() {
+		e.send("bestmove none")
+		return nil
+	}
+
+	game := e.game.Copy()
+	player := Game.Player(game.Board[Game.PlayerBoardIndex] & 0x1)
+	limits := func() *minimax.Budget {
+		var budget *minimax.Budget
+		if clock := clocks[player]; clock > 0 {
+			control := timing.Control{Total: clock, Increment: increments[player]}
+			if moveTimed {
+				control.MoveTime = moveTime
+			}
+			budget = control.Budget(clock, &game)
+		} else {
+			budget = minimax.NewBudget(moveTime)
+		}
+		budget.MaxNodes = nodes
+		return budget
+	}
+
+	if !ponder {
+		e.budget = limits()
+		e.start(bot, game)
+		return nil
+	}
+
+	e.budget = minimax.NewPonderBudget()
+	if bot.MCTS == nil || e.previous == nil {
+		// The expected reply is searched, on ponderhit the search continues on the limits
+		e.release = make(chan struct{})
+		e.hit = func() {
+			e.budget.PonderHit(limits())
+			close(e.release)
+			e.release = nil
+		}
+		e.start(bot, game)
+		return nil
+	}
+
+	// The tree is searched for every reply, on ponderhit the search continues below the reply
+	tree := e.treeAt(e.previous, *bot.MCTS)
+	e.treePonder = true
+	e.done = make(chan struct{})
+	go func(budget *minimax.Budget, done chan struct{}) {
+		defer close(done)
+		bot.SearchTree(tree, budget, nil)
+	}(e.budget, e.done)
+	e.hit = func() {
+		e.budget.Stop()
+		<-e.done
+		e.treePonder = false
+		e.budget = limits()
+		e.start(bot, game)
+	}
+	return nil
+}
+
+// start searches the position on the budget in its own goroutine, the best move is sent once the search ends and
+// the pondering is released. It is followed by the expected reply of the principal variation
+func (e *engine) start(bot match.Bot, game Game.Game) {
+	var tree *gmcts.MCTS
+	if bot.MCTS != nil {
+		tree = e.treeAt(&game, *bot.MCTS)
+	}
+	e.done = make(chan struct{})
+	go func(budget *minimax.Budget, release chan struct{}, done chan struct{}) {
+		defer close(done)
+		var variation []match.Move
+		report := func(info match.Info) {
+			variation = info.PV
+			e.info(info)
+		}
+
+		var move, board byte
+		if tree != nil {
+			move, board = bot.SearchTree(tree, budget, report)
+		} else {
+			move, board = bot.Search(&game, &e.table, budget, report)
+		}
+		if release != nil {
+			<-release
+		}
+
+		best := match.Move{Board: board, Pos: move}
+		switch {
+		case !game.ValidMove(board, move):
+			e.send("bestmove none")
+		case len(variation) > 1 && variation[0] == best:
+			e.send("bestmove %s ponder %s", best, variation[1])
+		default:
+			e.send("bestmove %s", best)
+		}
+	}(e.budget, e.release, e.done)
+}
+
+// treeAt returns the MCTS tree of the position, the kept tree is advanced when the position follows it by one move
+// and replaced otherwise
+func (e *engine) treeAt(game *Game.Game, config gmcts.MCTSConfig) *gmcts.MCTS {
+	if e.tree != nil && e.treeGame.Compare(game) {
+		return e.tree
+	}
+	if e.tree != nil && !e.treeGame.IsTerminal() {
+		advanced := false
+		e.treeGame.GetMoves(func(board byte, pos byte) bool {
+			next := e.treeGame.Copy()
+			next.MakeMove(board, pos)
+			if next.Compare(game) {
+				e.tree.Advance(board, pos)
+				advanced = true
+			}
+			return advanced
+		})
+		if advanced {
+			e.treeGame = game.Copy()
+			return e.tree
+		}
+	}
+
+	e.closeTree()
+	e.tree, e.treeGame = gmcts.NewMCTS(game, config), game.Copy()
+	return e.tree
+}
+
+func (e *engine) closeTree() {
+	if e.tree != nil {
+		e.tree.Close()
+		e.tree = nil
+	}
+}
+
+func (e *engine) info(info match.Info) {
+	pv := make([]string, len(info.PV))
+	for i, move := range info.PV {
+		pv[i] = move.String()
+	}
+
+	ms := info.Time.Milliseconds()
+	if info.Depth == 0 {
+		e.send("info score winrate %.3f nodes %d time %d pv %s", info.Score, info.Nodes, ms, strings.Join(pv, " "))
+		return
+	}
+	e.send("info depth %d score value %.3f nodes %d time %d pv %s", info.Depth, info.Score, info.Nodes, ms, strings.Join(pv, " "))
+}
+
+// stop ends the running search and waits for its best move, a search that ponders sends it as well
+func (e *engine) stop() {
+	if e.budget == nil {
+		return
+	}
+	e.budget.Stop()
+	if e.release != nil {
+		close(e.release)
+	}
+	<-e.done
+	if e.treePonder {
+		// The tree of the position before the ponder move answers with the best move after it
+		if move, board, ok := e.tree.BestReply(e.last.Board, e.last.Pos); ok {
+			e.send("bestmove %s", match.Move{Board: board, Pos: move})
+		} else {
+			e.send("bestmove none")
+		}
+	}
+	e.budget, e.done, e.hit, e.release, e.treePonder = nil, nil, nil, nil, false
+}
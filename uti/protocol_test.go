@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FabianPetersen/UltimateTicTacToe/match"
+)
+
+// output is written by the search goroutine and read by the test
+type output struct {
+	lock sync.Mutex
+	sb   strings.Builder
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.sb.Write(p)
+}
+
+func (o *output) lines() []string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return strings.Split(strings.TrimSpace(o.sb.String()), "\n")
+}
+
+func TestHandshake(t *testing.T) {
+	out := &output{}
+	newEngine(out).run(strings.NewReader("uti\nisready\nbogus\nquit\nisready\n"))
+
+	lines := out.lines()
+	if lines[len(lines)-3] != "utiok" || lines[len(lines)-2] != "readyok" || !strings.HasPrefix(lines[len(lines)-1], "info string bogus") {
+		t.Fatalf("answered %q", lines)
+	}
+}
+
+func TestSearchDepth(t *testing.T) {
+	out := &output{}
+	e := newEngine(out)
+	for _, command := range []string{"setoption name Engine value minimax:depth=9", "position startpos moves 84 40", "go depth 3"} {
+		fields := strings.Fields(command)
+		if err := e.command(fields[0], fields[1:]); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+	}
+	<-e.done
+
+	lines := out.lines()
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "info depth 3 score value ") || !strings.Contains(lines[2], " pv ") {
+		t.Fatalf("searched %q", lines)
+	}
+
+	move, err := match.ParseMove(strings.Fields(lines[3])[1])
+	if err != nil || !e.game.ValidMove(move.Board, move.Pos) {
+		t.Fatalf("best move %q is not legal", lines[3])
+	}
+}
+
+func TestStop(t *testing.T) {
+	out := &output{}
+	e := newEngine(out)
+	if err := e.command("go", []string{"infinite"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 50)
+	start := time.Now()
+	_ = e.command("stop", nil)
+	if time.Since(start) > time.Second {
+		t.Fatal("the search did not stop")
+	}
+	if lines := out.lines(); !strings.HasPrefix(lines[len(lines)-1], "bestmove 8") {
+		t.Fatalf("infinite search answered %q", lines)
+	}
+}
+
+func TestClock(t *testing.T) {
+	out := &output{}
+	e := newEngine(out)
+	_ = e.command("setoption", strings.Fields("name Engine value mtd:depth=60"))
+	start := time.Now()
+	// Only the clock of o, the player to move, is used
+	if err := e.command("go", strings.Fields("xtime 10 otime 2000 oinc 100")); err != nil {
+		t.Fatal(err)
+	}
+	<-e.done
+
+	// A 25th of the clock and most of the increment, at most three times as long to finish a depth
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("searched %s of a 2s clock", elapsed)
+	}
+	if lines := out.lines(); !strings.HasPrefix(lines[len(lines)-1], "bestmove 8") {
+		t.Fatalf("searched %q", lines)
+	}
+}
+
+func TestMCTSNodes(t *testing.T) {
+	out := &output{}
+	e := newEngine(out)
+	_ = e.command("setoption", strings.Fields("name Engine value mcts"))
+	if err := e.command("go", []string{"nodes", "500"}); err != nil {
+		t.Fatal(err)
+	}
+	<-e.done
+
+	lines := out.lines()
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "info score winrate ") || !strings.Contains(lines[0], "nodes 500 ") {
+		t.Fatalf("searched %q", lines)
+	}
+}
+
+func TestPonder(t *testing.T) {
+	for _, engine := range []string{"mtd:depth=60", "mcts"} {
+		out := &output{}
+		e := newEngine(out)
+		for _, command := range []string{"setoption name Engine value " + engine, "position startpos moves 84 40", "go ponder movetime 100"} {
+			fields := strings.Fields(command)
+			if err := e.command(fields[0], fields[1:]); err != nil {
+				t.Fatalf("%s: %s: %v", engine, command, err)
+			}
+		}
+		time.Sleep(200 * time.Millisecond)
+		if lines := out.lines(); strings.HasPrefix(lines[len(lines)-1], "bestmove") {
+			t.Fatalf("%s: answered %q while pondering", engine, lines)
+		}
+
+		// The move time starts with the ponderhit
+		start := time.Now()
+		if err := e.command("ponderhit", nil); err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		<-e.done
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+			t.Errorf("%s: searched %s after the ponderhit", engine, elapsed)
+		}
+		lines := out.lines()
+		fields := strings.Fields(lines[len(lines)-1])
+		if len(fields) < 2 || fields[0] != "bestmove" {
+			t.Fatalf("%s: answered %q", engine, lines)
+		}
+		if move, err := match.ParseMove(fields[1]); err != nil || !e.game.ValidMove(move.Board, move.Pos) {
+			t.Fatalf("%s: answered %q", engine, lines)
+		}
+		if e.command("ponderhit", nil) == nil {
+			t.Errorf("%s: a second ponderhit was accepted", engine)
+		}
+	}
+}
+
+func TestPonderStop(t *testing.T) {
+	for _, engine := range []string{"mtd:depth=60", "mcts"} {
+		out := &output{}
+		e := newEngine(out)
+		for _, command := range []string{"setoption name Engine value " + engine, "position startpos moves 84 40", "go ponder movetime 100"} {
+			fields := strings.Fields(command)
+			_ = e.command(fields[0], fields[1:])
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		// A miss stops the pondering, the next search starts from what the pondering left
+		_ = e.command("stop", nil)
+		lines := out.lines()
+		if !strings.HasPrefix(lines[len(lines)-1], "bestmove 0") {
+			t.Fatalf("%s: stopped pondering with %q", engine, lines)
+		}
+		for _, command := range []string{"position startpos moves 84 41", "go movetime 50"} {
+			fields := strings.Fields(command)
+			if err := e.command(fields[0], fields[1:]); err != nil {
+				t.Fatalf("%s: %s: %v", engine, command, err)
+			}
+		}
+		<-e.done
+		lines = out.lines()
+		if !strings.HasPrefix(lines[len(lines)-1], "bestmove 1") {
+			t.Fatalf("%s: searched the reply with %q", engine, lines)
+		}
+	}
+}
+
+func TestInvalidCommands(t *testing.T) {
+	e := newEngine(&output{})
+	for _, command := range []string{"position startpos moves 00", "position x", "go depth", "go nodes x", "go ply 3", "ponderhit", "setoption name Hash value 3", "setoption name Engine value alphabeta"} {
+		fields := strings.Fields(command)
+		if e.command(fields[0], fields[1:]) == nil {
+			t.Fatalf("%q was accepted", command)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package websocket is the part of RFC 6455 the live game server needs, unfragmented text messages
+// are written and fragmented messages, pings and closes are understood when reading.
+// Frames breaking the protocol end the connection
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// MaxMessageSize is the longest message ReadMessage accepts
+const MaxMessageSize = 1 << 20
+
+const (
+	CONTINUATION byte = 0x0
+	TEXT         byte = 0x1
+	BINARY       byte = 0x2
+	CLOSE        byte = 0x8
+	PING         byte = 0x9
+	PONG         byte = 0xA
+)
+
+// The status codes of the close frames
+const (
+	closeNormal        uint16 = 1000
+	closeProtocolError uint16 = 1002
+)
+
+// maxControlSize is the longest payload of a PING, PONG or CLOSE
+const maxControlSize = 125
+
+var ErrMessageTooLarge = errors.New("websocket: message too large")
+
+// ErrProtocol is a frame RFC 6455 does not allow, the connection is closed with a protocol error
+var ErrProtocol = errors.New("websocket: protocol error")
+
+// Conn is a WebSocket connection, one goroutine may read while others write
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// client masks the frames it writes as the RFC requires
+	client bool
+
+	writeLock sync.Mutex
+	closed    bool
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func headerContains(header http.Header, name string, value string) bool {
+	for _, field := range strings.Split(header.Get(name), ",") {
+		if strings.EqualFold(strings.TrimSpace(field), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Upgrade answers the opening handshake of a client and takes over the connection of the request
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, fmt.Errorf("websocket: not a handshake")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "the connection can not be upgraded", http.StatusInternalServerError)
+		return nil, fmt.Errorf("websocket: the response writer can not be hijacked")
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(buffer, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err == nil {
+		err = buffer.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: buffer.Reader}, nil
+}
+
+// Dial opens a connection to a ws:// URL
+func Dial(rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	_, err = fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", u.RequestURI(), u.Host, key)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		_ = conn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed with %s", response.Status)
+	}
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+// writeFrame writes a single final frame
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *Conn) writeFrameLocked(opcode byte, payload []byte) error {
+
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+	switch length := len(payload); {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if c.client {
+		header[1] |= 0x80
+		mask := make([]byte, 4)
+		_, _ = rand.Read(mask)
+		header = append(header, mask...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// readFrame reads the next frame and unmasks its payload. Frames of a client must be masked and frames of a
+// server not, a control frame is final and short, and no extension is negotiated
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(c.reader, header); err != nil {
+		return
+	}
+	fin, opcode = header[0]&0x80 != 0, header[0]&0x0F
+	masked := header[1]&0x80 != 0
+	control := opcode >= CLOSE
+	switch {
+	case header[0]&0x70 != 0:
+		err = fmt.Errorf("%w: reserved bits are set", ErrProtocol)
+	case opcode > BINARY && opcode != CLOSE && opcode != PING && opcode != PONG:
+		err = fmt.Errorf("%w: unknown opcode %#x", ErrProtocol, opcode)
+	case masked == c.client:
+		err = fmt.Errorf("%w: masked is %t", ErrProtocol, masked)
+	case control && (!fin || header[1]&0x7F > maxControlSize):
+		err = fmt.Errorf("%w: fragmented or long control frame", ErrProtocol)
+	}
+	if err != nil {
+		return
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err = io.ReadFull(c.reader, extended); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err = io.ReadFull(c.reader, extended); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if length > MaxMessageSize {
+		err = ErrMessageTooLarge
+		return
+	}
+
+	mask := make([]byte, 4)
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// ReadMessage returns the next text or binary message, pings are answered on the way.
+// A close from the other side is answered and returned as io.EOF. A frame breaking the protocol closes
+// the connection with a protocol error and returns an ErrProtocol
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	fragmented := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				_ = c.closeWith(closeProtocolError)
+			}
+			return nil, err
+		}
+
+		switch opcode {
+		case PING:
+			if err = c.writeFrame(PONG, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case PONG:
+			continue
+		case CLOSE:
+			_ = c.Close()
+			return nil, io.EOF
+		}
+
+		// A message starts with a text or binary frame and goes on with continuations
+		if (opcode == CONTINUATION) != fragmented {
+			_ = c.closeWith(closeProtocolError)
+			return nil, fmt.Errorf("%w: opcode %#x out of sequence", ErrProtocol, opcode)
+		}
+		fragmented = !fin
+
+		message = append(message, payload...)
+		if len(message) > MaxMessageSize {
+			return nil, ErrMessageTooLarge
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (c *Conn) WriteMessage(message []byte) error {
+	return c.writeFrame(TEXT, message)
+}
+
+func (c *Conn) ReadJSON(value any) error {
+	message, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(message, value)
+}
+
+func (c *Conn) WriteJSON(value any) error {
+	message, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(message)
+}
+
+// Close sends a normal close frame and closes the connection, closing twice does nothing
+func (c *Conn) Close() error {
+	return c.closeWith(closeNormal)
+}
+
+// closeWith sends a close frame with the status code and closes the connection
+func (c *Conn) closeWith(code uint16) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	_ = c.writeFrameLocked(CLOSE, binary.BigEndian.AppendUint16(nil, code))
+	return c.conn.Close()
+}
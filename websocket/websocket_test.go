@@ -0,0 +1,154 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echo answers every message with the same message
+func echo(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455 section 1.3
+	if key := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("accept key %s", key)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	server := echo(t)
+	conn, err := Dial("ws" + strings.TrimPrefix(server.URL, "http") + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The lengths cover the three length encodings
+	for _, length := range []int{5, 300, 70000} {
+		message := strings.Repeat("x", length)
+		if err = conn.WriteMessage([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+		answer, err := conn.ReadMessage()
+		if err != nil || string(answer) != message {
+			t.Fatalf("echo of %d bytes returned %d bytes, %v", length, len(answer), err)
+		}
+	}
+
+	if err = conn.writeFrame(PING, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	value := map[string]int{}
+	if err = conn.WriteJSON(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	// The pong is skipped on the way to the message
+	if err = conn.ReadJSON(&value); err != nil || value["a"] != 1 {
+		t.Fatalf("read %v, %v", value, err)
+	}
+
+	if err = conn.writeFrame(CLOSE, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.ReadMessage(); err != io.EOF {
+		t.Fatalf("close was answered with %v", err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	server := echo(t)
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("plain GET answered %d", res.StatusCode)
+	}
+}
+
+// frame is a frame of a client with the first header byte and the mask bit given
+func frame(first byte, payload []byte, masked bool) []byte {
+	data := []byte{first, byte(len(payload))}
+	if len(payload) > 125 {
+		data = binary.BigEndian.AppendUint16([]byte{first, 126}, uint16(len(payload)))
+	}
+	if !masked {
+		return append(data, payload...)
+	}
+	// The mask of zeros keeps the payload as it is
+	data[1] |= 0x80
+	return append(append(data, 0, 0, 0, 0), payload...)
+}
+
+func TestServerRejectsProtocolErrors(t *testing.T) {
+	server := echo(t)
+	for name, frames := range map[string][][]byte{
+		"unmasked":             {frame(0x80|TEXT, []byte("hi"), false)},
+		"fragmented ping":      {frame(PING, []byte("hi"), true)},
+		"long ping":            {frame(0x80|PING, bytes.Repeat([]byte("x"), 126), true)},
+		"reserved bit":         {frame(0xC0|TEXT, []byte("hi"), true)},
+		"unknown opcode":       {frame(0x83, []byte("hi"), true)},
+		"lone continuation":    {frame(0x80|CONTINUATION, []byte("hi"), true)},
+		"text inside fragment": {frame(TEXT, []byte("h"), true), frame(0x80|TEXT, []byte("i"), true)},
+	} {
+		conn, err := Dial("ws" + strings.TrimPrefix(server.URL, "http") + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range frames {
+			if _, err = conn.conn.Write(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		fin, opcode, payload, err := conn.readFrame()
+		if err != nil || !fin || opcode != CLOSE || len(payload) != 2 || binary.BigEndian.Uint16(payload) != closeProtocolError {
+			t.Errorf("%s was answered with %t %#x %v, %v", name, fin, opcode, payload, err)
+		}
+		_ = conn.conn.Close()
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	server := echo(t)
+	conn, err := Dial("ws" + strings.TrimPrefix(server.URL, "http") + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// A ping may come between the fragments of a message
+	for _, data := range [][]byte{frame(TEXT, []byte("h"), true), frame(0x80|PING, nil, true), frame(0x80|CONTINUATION, []byte("i"), true)} {
+		if _, err = conn.conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if message, err := conn.ReadMessage(); err != nil || string(message) != "hi" {
+		t.Fatalf("read %q, %v", message, err)
+	}
+}